@@ -2,6 +2,7 @@ package ecspresso
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 )
@@ -21,6 +22,7 @@ type CLIOptions struct {
 	Deploy     *DeployOption     `cmd:"" help:"deploy service"`
 	Deregister *DeregisterOption `cmd:"" help:"deregister task definition"`
 	Diff       *DiffOption       `cmd:"" help:"show diff between task definition, service definition with current running service and task definition"`
+	Drift      *DriftOption      `cmd:"" help:"detect drift of running services from the definitions"`
 	Exec       *ExecOption       `cmd:"" help:"execute command on task"`
 	Init       *InitOption       `cmd:"" help:"create configuration files from existing ECS service"`
 	Refresh    *RefreshOption    `cmd:"" help:"refresh service. equivalent to deploy --skip-task-definition --force-new-deployment --no-update-service"`
@@ -49,6 +51,8 @@ func (opts *CLIOptions) ForSubCommand(sub string) interface{} {
 		return opts.Deregister
 	case "diff":
 		return opts.Diff
+	case "drift":
+		return opts.Drift
 	case "exec":
 		return opts.Exec
 	case "init":
@@ -121,6 +125,8 @@ func dispatchCLI(ctx context.Context, sub string, usage func(), opts *CLIOptions
 		return app.Init(ctx, *opts.Init)
 	case "diff":
 		return app.Diff(ctx, *opts.Diff)
+	case "drift":
+		return app.Drift(ctx, *opts.Drift)
 	case "appspec":
 		return app.AppSpec(ctx, *opts.Appspec)
 	case "verify":
@@ -145,7 +151,21 @@ func CLI(ctx context.Context, parse CLIParseFunc) (int, error) {
 		return 1, err
 	}
 	if err := dispatchCLI(ctx, sub, usage, opts); err != nil {
-		return 1, err
+		return cliExitCode(err)
 	}
 	return 0, nil
 }
+
+// cliExitCode returns the exit code of the process and the error to print for the error of a command.
+func cliExitCode(err error) (int, error) {
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		return 1, err
+	}
+	if err == error(exitErr) && exitErr.Err == nil {
+		// exit with the code without any error messages
+		return exitErr.Code, nil
+	}
+	// keep the context wrapping the ExitError
+	return exitErr.Code, err
+}
@@ -1,6 +1,8 @@
 package ecspresso_test
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -386,6 +388,28 @@ var cliTests = []struct {
 			RollbackEvents:           "",
 		},
 	},
+	{
+		args: []string{"rollback", "--revision", "10", "--dry-run"},
+		sub:  "rollback",
+		subOption: &ecspresso.RollbackOption{
+			DryRun:                   true,
+			DeregisterTaskDefinition: true,
+			Wait:                     true,
+			RollbackEvents:           "",
+			Revision:                 10,
+		},
+	},
+	{
+		args: []string{"rollback", "--with-service"},
+		sub:  "rollback",
+		subOption: &ecspresso.RollbackOption{
+			DryRun:                   false,
+			DeregisterTaskDefinition: true,
+			Wait:                     true,
+			RollbackEvents:           "",
+			WithService:              true,
+		},
+	},
 	{
 		args: []string{"delete"},
 		sub:  "delete",
@@ -425,6 +449,7 @@ var cliTests = []struct {
 			PropagateTags:        "",
 			TaskOverrideStr:      "",
 			TaskOverrideFile:     "",
+			TaskOverridesList:    "",
 			SkipTaskDefinition:   false,
 			LatestTaskDefinition: false,
 			Tags:                 "",
@@ -444,6 +469,7 @@ var cliTests = []struct {
 			PropagateTags:        "",
 			TaskOverrideStr:      "",
 			TaskOverrideFile:     "",
+			TaskOverridesList:    "",
 			SkipTaskDefinition:   false,
 			LatestTaskDefinition: false,
 			Tags:                 "",
@@ -469,6 +495,7 @@ var cliTests = []struct {
 			PropagateTags:        "SERVICE",
 			TaskOverrideStr:      `{"foo":"bar"}`,
 			TaskOverrideFile:     "overrides.json",
+			TaskOverridesList:    "",
 			SkipTaskDefinition:   false,
 			LatestTaskDefinition: true,
 			Tags:                 "KeyFoo=ValueFoo,KeyBar=ValueBar",
@@ -476,6 +503,139 @@ var cliTests = []struct {
 			Revision:             ptr(int64(1)),
 		},
 	},
+	{
+		args: []string{"run", "--overrides-list-file", "overrides-list.json", "--propagate-exit-code"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:               false,
+			TaskDefinition:       "",
+			Wait:                 true,
+			Count:                int32(1),
+			WatchContainer:       "",
+			PropagateTags:        "",
+			TaskOverrideStr:      "",
+			TaskOverrideFile:     "",
+			TaskOverridesList:    "overrides-list.json",
+			SkipTaskDefinition:   false,
+			LatestTaskDefinition: false,
+			Tags:                 "",
+			WaitUntil:            "stopped",
+			Revision:             ptr(int64(0)),
+			PropagateExitCode:    true,
+		},
+	},
+	{
+		args: []string{"run", "--command", "echo 'hello world'", "--env", "FOO=foo", "--env", "BAR=bar",
+			"--env-file", "run.env", "--cpu", "1 vCPU", "--memory", "2GB", "--container", "app",
+			"--task-role-arn", "arn:aws:iam::123456789012:role/app", "--ephemeral-storage", "30",
+		},
+		sub: "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:               false,
+			TaskDefinition:       "",
+			Wait:                 true,
+			Count:                int32(1),
+			WatchContainer:       "",
+			PropagateTags:        "",
+			TaskOverrideStr:      "",
+			TaskOverrideFile:     "",
+			TaskOverridesList:    "",
+			SkipTaskDefinition:   false,
+			LatestTaskDefinition: false,
+			Tags:                 "",
+			WaitUntil:            "stopped",
+			Revision:             ptr(int64(0)),
+			Command:              "echo 'hello world'",
+			Env:                  []string{"FOO=foo", "BAR=bar"},
+			EnvFile:              "run.env",
+			Cpu:                  "1 vCPU",
+			Memory:               "2GB",
+			Container:            "app",
+			TaskRoleArn:          "arn:aws:iam::123456789012:role/app",
+			EphemeralStorage:     30,
+		},
+	},
+	{
+		args: []string{"run", "--subnets", "subnet-1,subnet-2", "--security-groups", "sg-1",
+			"--assign-public-ip", "ENABLED", "--capacity-provider", "FARGATE_SPOT:3",
+			"--capacity-provider", "FARGATE:1:1", "--platform-version", "LATEST",
+			"--started-by", "batch", "--group", "family:batch",
+		},
+		sub: "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:               false,
+			TaskDefinition:       "",
+			Wait:                 true,
+			Count:                int32(1),
+			WatchContainer:       "",
+			PropagateTags:        "",
+			TaskOverrideStr:      "",
+			TaskOverrideFile:     "",
+			TaskOverridesList:    "",
+			SkipTaskDefinition:   false,
+			LatestTaskDefinition: false,
+			Tags:                 "",
+			WaitUntil:            "stopped",
+			Revision:             ptr(int64(0)),
+			Subnets:              []string{"subnet-1", "subnet-2"},
+			SecurityGroups:       []string{"sg-1"},
+			AssignPublicIP:       "ENABLED",
+			CapacityProvider:     []string{"FARGATE_SPOT:3", "FARGATE:1:1"},
+			PlatformVersion:      "LATEST",
+			StartedBy:            "batch",
+			Group:                "family:batch",
+		},
+	},
+	{
+		args: []string{"run",
+			"--capacity-provider", "FARGATE_SPOT",
+			"--retry-max-attempts", "5",
+			"--fallback-capacity-provider", "FARGATE",
+		},
+		sub: "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                   false,
+			TaskDefinition:           "",
+			Wait:                     true,
+			Count:                    int32(1),
+			WatchContainer:           "",
+			PropagateTags:            "",
+			TaskOverrideStr:          "",
+			TaskOverrideFile:         "",
+			TaskOverridesList:        "",
+			SkipTaskDefinition:       false,
+			LatestTaskDefinition:     false,
+			Tags:                     "",
+			WaitUntil:                "stopped",
+			Revision:                 ptr(int64(0)),
+			CapacityProvider:         []string{"FARGATE_SPOT"},
+			RetryMaxAttempts:         5,
+			FallbackCapacityProvider: []string{"FARGATE"},
+		},
+	},
+	{
+		args: []string{"run", "--exec", "--command", "bash", "--container", "app"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:               false,
+			TaskDefinition:       "",
+			Wait:                 true,
+			Count:                int32(1),
+			WatchContainer:       "",
+			PropagateTags:        "",
+			TaskOverrideStr:      "",
+			TaskOverrideFile:     "",
+			TaskOverridesList:    "",
+			SkipTaskDefinition:   false,
+			LatestTaskDefinition: false,
+			Tags:                 "",
+			WaitUntil:            "stopped",
+			Revision:             ptr(int64(0)),
+			Command:              "bash",
+			Container:            "app",
+			Exec:                 true,
+		},
+	},
 	{
 		args: []string{"register"},
 		sub:  "register",
@@ -586,6 +746,7 @@ var cliTests = []struct {
 			Service:               "myservice",
 			TaskDefinitionPath:    "ecs-task-def.json",
 			ServiceDefinitionPath: "ecs-service-def.json",
+			ScalingDefinitionPath: "ecs-scaling-def.json",
 			ForceOverwrite:        false,
 			Jsonnet:               false,
 		},
@@ -601,6 +762,7 @@ var cliTests = []struct {
 				Service:               "myservice",
 				TaskDefinitionPath:    "ecs-task-def.json",
 				ServiceDefinitionPath: "ecs-service-def.json",
+				ScalingDefinitionPath: "ecs-scaling-def.json",
 				ForceOverwrite:        false,
 				Jsonnet:               false,
 			},
@@ -616,6 +778,7 @@ var cliTests = []struct {
 			Service:               "myservice",
 			TaskDefinitionPath:    "ecs-task-def.json",
 			ServiceDefinitionPath: "ecs-service-def.json",
+			ScalingDefinitionPath: "ecs-scaling-def.json",
 			ForceOverwrite:        false,
 			Jsonnet:               false,
 		},
@@ -635,6 +798,7 @@ var cliTests = []struct {
 			Service:               "myservice",
 			TaskDefinitionPath:    "taskdef.jsonnet",
 			ServiceDefinitionPath: "servicedef.jsonnet",
+			ScalingDefinitionPath: "ecs-scaling-def.json",
 			ForceOverwrite:        true,
 			Jsonnet:               true,
 		},
@@ -643,14 +807,86 @@ var cliTests = []struct {
 		args: []string{"diff"},
 		sub:  "diff",
 		subOption: &ecspresso.DiffOption{
-			Unified: true,
+			Unified:   true,
+			ExitCode:  false,
+			Output:    "text",
+			From:      "remote",
+			To:        "local",
+			Normalize: true,
 		},
 	},
 	{
 		args: []string{"diff", "--no-unified"},
 		sub:  "diff",
 		subOption: &ecspresso.DiffOption{
-			Unified: false,
+			Unified:   false,
+			ExitCode:  false,
+			Output:    "text",
+			From:      "remote",
+			To:        "local",
+			Normalize: true,
+		},
+	},
+	{
+		args: []string{"diff", "--exit-code", "--output", "json"},
+		sub:  "diff",
+		subOption: &ecspresso.DiffOption{
+			Unified:   true,
+			ExitCode:  true,
+			Output:    "json",
+			From:      "remote",
+			To:        "local",
+			Normalize: true,
+		},
+	},
+	{
+		args: []string{"diff", "--from", "app:12", "--to", "app:15"},
+		sub:  "diff",
+		subOption: &ecspresso.DiffOption{
+			Unified:   true,
+			Output:    "text",
+			From:      "app:12",
+			To:        "app:15",
+			Normalize: true,
+		},
+	},
+	{
+		args: []string{"diff", "--config-a", "staging.yml", "--config-b", "production.yml"},
+		sub:  "diff",
+		subOption: &ecspresso.DiffOption{
+			Unified:   true,
+			Output:    "text",
+			From:      "remote",
+			To:        "local",
+			ConfigA:   "staging.yml",
+			ConfigB:   "production.yml",
+			Normalize: true,
+		},
+	},
+	{
+		args: []string{"diff", "--no-normalize"},
+		sub:  "diff",
+		subOption: &ecspresso.DiffOption{
+			Unified:   true,
+			Output:    "text",
+			From:      "remote",
+			To:        "local",
+			Normalize: false,
+		},
+	},
+	{
+		args: []string{"drift"},
+		sub:  "drift",
+		subOption: &ecspresso.DriftOption{
+			Output: "text",
+		},
+	},
+	{
+		args: []string{"drift", "--output", "json", "a.yml", "b.yml"},
+		sub:  "drift",
+		subOption: &ecspresso.DriftOption{
+			Output:  "json",
+			Configs: []string{"a.yml", "b.yml"},
 		},
 	},
 	{
@@ -673,27 +909,57 @@ var cliTests = []struct {
 		args: []string{"verify"},
 		sub:  "verify",
 		subOption: &ecspresso.VerifyOption{
-			GetSecrets: true,
-			PutLogs:    true,
-			Cache:      true,
+			GetSecrets:  true,
+			PutLogs:     true,
+			Cache:       true,
+			Concurrency: 8,
+			Output:      "text",
 		},
 	},
 	{
 		args: []string{"verify", "--no-get-secrets", "--no-put-logs"},
 		sub:  "verify",
 		subOption: &ecspresso.VerifyOption{
-			GetSecrets: false,
-			PutLogs:    false,
-			Cache:      true,
+			GetSecrets:  false,
+			PutLogs:     false,
+			Cache:       true,
+			Concurrency: 8,
+			Output:      "text",
 		},
 	},
 	{
 		args: []string{"verify", "--no-get-secrets", "--no-put-logs", "--no-cache"},
 		sub:  "verify",
 		subOption: &ecspresso.VerifyOption{
-			GetSecrets: false,
-			PutLogs:    false,
-			Cache:      false,
+			GetSecrets:  false,
+			PutLogs:     false,
+			Cache:       false,
+			Concurrency: 8,
+			Output:      "text",
+		},
+	},
+	{
+		args: []string{"verify", "--output", "junit", "--fail-fast", "--concurrency", "2"},
+		sub:  "verify",
+		subOption: &ecspresso.VerifyOption{
+			GetSecrets:  true,
+			PutLogs:     true,
+			Cache:       true,
+			Concurrency: 2,
+			Output:      "junit",
+			FailFast:    true,
+		},
+	},
+	{
+		args: []string{"verify", "--offline"},
+		sub:  "verify",
+		subOption: &ecspresso.VerifyOption{
+			GetSecrets:  true,
+			PutLogs:     true,
+			Cache:       true,
+			Concurrency: 8,
+			Output:      "text",
+			Offline:     true,
 		},
 	},
 	{
@@ -793,3 +1059,35 @@ func TestParseCLIv2(t *testing.T) {
 		})
 	}
 }
+
+func TestCLIExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		code int
+		msg  string
+	}{
+		{name: "error", err: errors.New("failed"), code: 1, msg: "failed"},
+		{name: "exit code only", err: &ecspresso.ExitError{Code: 2}, code: 2, msg: ""},
+		{name: "exit error", err: &ecspresso.ExitError{Code: 125, Err: errors.New("task failed to start")}, code: 125, msg: "task failed to start"},
+		{
+			name: "wrapped exit error",
+			err:  fmt.Errorf("failed to run: %w", &ecspresso.ExitError{Code: 3, Err: errors.New("exit code: 3")}),
+			code: 3,
+			msg:  "failed to run: exit code: 3",
+		},
+	}
+	for _, tc := range testCases {
+		code, err := ecspresso.CLIExitCode(tc.err)
+		if code != tc.code {
+			t.Errorf("%s: expected exit code %d, got %d", tc.name, tc.code, code)
+		}
+		var msg string
+		if err != nil {
+			msg = err.Error()
+		}
+		if msg != tc.msg {
+			t.Errorf("%s: expected error %q, got %q", tc.name, tc.msg, msg)
+		}
+	}
+}
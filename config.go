@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"text/template"
 	"time"
 
@@ -28,6 +29,9 @@ var awsv2ConfigLoadOptionsFunc []func(*awsConfig.LoadOptions) error
 type configLoader struct {
 	*goConfig.Loader
 	VM *jsonnet.VM
+
+	// mu serializes reading definition files because jsonnet.VM is not goroutine-safe.
+	mu sync.Mutex
 }
 
 func newConfigLoader(extStr, extCode map[string]string) *configLoader {
@@ -52,11 +56,15 @@ type Config struct {
 	Service               string            `yaml:"service" json:"service"`
 	ServiceDefinitionPath string            `yaml:"service_definition" json:"service_definition"`
 	TaskDefinitionPath    string            `yaml:"task_definition" json:"task_definition"`
+	ScalingDefinitionPath string            `yaml:"scaling_definition,omitempty" json:"scaling_definition,omitempty"`
 	Plugins               []ConfigPlugin    `yaml:"plugins,omitempty" json:"plugins,omitempty"`
 	AppSpec               *appspec.AppSpec  `yaml:"appspec,omitempty" json:"appspec,omitempty"`
 	FilterCommand         string            `yaml:"filter_command,omitempty" json:"filter_command,omitempty"`
 	Timeout               *Duration         `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	CodeDeploy            *ConfigCodeDeploy `yaml:"codedeploy,omitempty" json:"codedeploy,omitempty"`
+	Lint                  *ConfigLint       `yaml:"lint,omitempty" json:"lint,omitempty"`
+	ImageScan             *ConfigImageScan  `yaml:"image_scan,omitempty" json:"image_scan,omitempty"`
+	Run                   *ConfigRun        `yaml:"run,omitempty" json:"run,omitempty"`
 
 	path               string
 	templateFuncs      []template.FuncMap
@@ -126,6 +134,9 @@ func (c *Config) Restrict(ctx context.Context) error {
 	if c.TaskDefinitionPath != "" && !filepath.IsAbs(c.TaskDefinitionPath) {
 		c.TaskDefinitionPath = filepath.Join(c.dir, c.TaskDefinitionPath)
 	}
+	if c.ScalingDefinitionPath != "" && !filepath.IsAbs(c.ScalingDefinitionPath) {
+		c.ScalingDefinitionPath = filepath.Join(c.dir, c.ScalingDefinitionPath)
+	}
 	if c.RequiredVersion != "" {
 		constraints, err := goVersion.NewConstraint(c.RequiredVersion)
 		if err != nil {
@@ -136,6 +147,21 @@ func (c *Config) Restrict(ctx context.Context) error {
 	if c.Timeout == nil {
 		c.Timeout = &Duration{Duration: DefaultTimeout}
 	}
+	if c.Lint != nil {
+		if err := c.Lint.validate(); err != nil {
+			return fmt.Errorf("invalid lint config: %w", err)
+		}
+	}
+	if c.ImageScan != nil {
+		if err := c.ImageScan.validate(); err != nil {
+			return fmt.Errorf("invalid image_scan config: %w", err)
+		}
+	}
+	if c.Run != nil {
+		if err := c.Run.validate(); err != nil {
+			return fmt.Errorf("invalid run config: %w", err)
+		}
+	}
 	if c.Region == "" {
 		c.Region = os.Getenv("AWS_REGION")
 	}
@@ -33,6 +33,14 @@ func (d *App) createService(ctx context.Context, opt DeployOption) error {
 		d.OutputJSONForAPI(os.Stderr, td)
 		d.Log("service definition:")
 		d.OutputJSONForAPI(os.Stderr, svd)
+		if d.config.ScalingDefinitionPath != "" {
+			sd, err := d.LoadScalingDefinition(d.config.ScalingDefinitionPath)
+			if err != nil {
+				return err
+			}
+			d.Log("scaling definition:")
+			d.OutputJSONForAPI(os.Stderr, sd)
+		}
 		d.Log("DRY RUN OK")
 		return nil
 	}
@@ -46,6 +54,12 @@ func (d *App) createService(ctx context.Context, opt DeployOption) error {
 		}
 		d.Log("Using latest task definition %s", tdArn)
 	} else {
+		if err := d.checkPlaintextSecrets(td); err != nil {
+			return err
+		}
+		if err := d.checkImageScanFindings(ctx, td); err != nil {
+			return err
+		}
 		newTd, err := d.RegisterTaskDefinition(ctx, td)
 		if err != nil {
 			return err
@@ -76,10 +90,19 @@ func (d *App) createService(ctx context.Context, opt DeployOption) error {
 		Tags:                          svd.Tags,
 		TaskDefinition:                aws.String(tdArn),
 	}
-	if _, err := d.ecs.CreateService(ctx, createServiceInput); err != nil {
+	out, err := d.ecs.CreateService(ctx, createServiceInput)
+	if err != nil {
 		return fmt.Errorf("failed to create service: %w", err)
 	}
 	d.Log("Service is created")
+	if err := d.recordDeployHistory(ctx, out.Service.ServiceArn, tdArn); err != nil {
+		d.Log("[WARNING] %s", err)
+	}
+	if d.config.ScalingDefinitionPath != "" {
+		if err := d.applyScalingDefinition(ctx, opt); err != nil {
+			return err
+		}
+	}
 
 	if !opt.Wait {
 		return nil
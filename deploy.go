@@ -90,6 +90,7 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 		return err
 	}
 
+	currentSv := sv
 	doDeploy, err := d.DeployFunc(sv)
 	if err != nil {
 		return err
@@ -114,6 +115,12 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 		if err != nil {
 			return err
 		}
+		if err := d.checkPlaintextSecrets(td); err != nil {
+			return err
+		}
+		if err := d.checkImageScanFindings(ctx, td); err != nil {
+			return err
+		}
 		if opt.DryRun {
 			d.Log("[INFO] task definition:")
 			d.OutputJSONForAPI(os.Stderr, td)
@@ -132,7 +139,7 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 		if err != nil {
 			return err
 		}
-		addedTags, updatedTags, deletedTags := CompareTags(sv.Tags, newSv.Tags)
+		addedTags, updatedTags, deletedTags := CompareTags(excludeReservedTags(sv.Tags), newSv.Tags)
 		ds, err := diffServices(newSv, sv, "", d.config.ServiceDefinitionPath, true)
 		if err != nil {
 			return fmt.Errorf("failed to diff of service definitions: %w", err)
@@ -159,6 +166,11 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 	}
 
 	// manage auto scaling
+	if d.config.ScalingDefinitionPath != "" && opt.UpdateService {
+		if err := d.applyScalingDefinition(ctx, opt); err != nil {
+			return err
+		}
+	}
 	if err := d.modifyAutoScaling(ctx, opt); err != nil {
 		return err
 	}
@@ -168,9 +180,18 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 		return nil
 	}
 
+	if tdArn != aws.ToString(currentSv.TaskDefinition) {
+		// save the service definition before the deployment for rollback --with-service
+		if err := d.saveServiceSnapshot(ctx, currentSv); err != nil {
+			d.Log("[WARNING] %s", err)
+		}
+	}
 	if err := doDeploy(ctx, tdArn, count, sv, opt); err != nil {
 		return err
 	}
+	if err := d.recordDeployHistory(ctx, sv.ServiceArn, tdArn); err != nil {
+		d.Log("[WARNING] %s", err)
+	}
 
 	if !opt.Wait {
 		d.Log("Service is deployed.")
@@ -19,57 +19,273 @@ import (
 )
 
 type DiffOption struct {
-	Unified bool `help:"unified diff format" default:"true" negatable:""`
+	Unified   bool   `help:"unified diff format" default:"true" negatable:""`
+	ExitCode  bool   `help:"exit with code 2 when there are differences" default:"false"`
+	Output    string `help:"output format (text, json). json outputs JSON Patch (RFC 6902) operations for each resource" default:"text" enum:"text,json"`
+	From      string `help:"diff from (remote, local, or task definition family:revision, revision number, ARN)" default:"remote"`
+	To        string `help:"diff to (remote, local, or task definition family:revision, revision number, ARN)" default:"local"`
+	ConfigA   string `help:"config file to diff from. compare definitions rendered by --config-a and --config-b" default:""`
+	ConfigB   string `help:"config file to diff to. compare definitions rendered by --config-a and --config-b" default:""`
+	Normalize bool   `help:"normalize default values filled by ECS in task definitions" default:"true" negatable:""`
+}
+
+const (
+	diffSourceLocal  = "local"
+	diffSourceRemote = "remote"
+)
+
+// ExitCodeDiffFound is the exit code of diff --exit-code when there are differences.
+const ExitCodeDiffFound = 2
+
+// ResourceDiff represents differences of a resource as JSON Patch operations.
+type ResourceDiff struct {
+	Resource string               `json:"resource"`
+	From     string               `json:"from"`
+	To       string               `json:"to"`
+	Patch    []JSONPatchOperation `json:"patch"`
+}
+
+// diffSource represents definitions to be compared.
+// Service is nil when the source has no service definition (e.g. a task definition revision).
+// ScalingDefinition is nil when scaling_definition is not defined in the config.
+type diffSource struct {
+	Service               *Service
+	ServiceName           string
+	TaskDefinition        *TaskDefinitionInput
+	TaskDefinitionName    string
+	ScalingDefinition     *ScalingDefinition
+	ScalingDefinitionName string
 }
 
 func (d *App) Diff(ctx context.Context, opt DiffOption) error {
 	ctx, cancel := d.Start(ctx)
 	defer cancel()
 
-	var taskDefArn string
-	// diff for services only when service defined
-	if d.config.Service != "" {
-		newSv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+	var from, to *diffSource
+	var err error
+	if opt.ConfigA != "" || opt.ConfigB != "" {
+		if opt.ConfigA == "" || opt.ConfigB == "" {
+			return ErrConflictOptions("--config-a and --config-b must be specified together")
+		}
+		if from, err = d.diffSourceOfConfig(ctx, opt.ConfigA); err != nil {
+			return err
+		}
+		if to, err = d.diffSourceOfConfig(ctx, opt.ConfigB); err != nil {
+			return err
+		}
+	} else {
+		// load local definitions at first to fail fast for invalid local files
+		if to, err = d.diffSource(ctx, opt.To); err != nil {
+			return err
+		}
+		if from, err = d.diffSource(ctx, opt.From); err != nil {
+			return err
+		}
+	}
+
+	var changed bool
+	var resourceDiffs []ResourceDiff
+	// diff for services only when both have service definitions
+	if from.Service != nil && to.Service != nil {
+		if opt.Output == "json" {
+			toJSON, fromJSON, err := servicesJSONForDiff(to.Service, from.Service)
+			if err != nil {
+				return err
+			}
+			rds, err := newResourceDiffs("service", fromJSON, toJSON, from.ServiceName, to.ServiceName)
+			if err != nil {
+				return err
+			}
+			resourceDiffs = append(resourceDiffs, rds...)
+		} else if ds, err := diffServices(to.Service, from.Service, from.ServiceName, to.ServiceName, opt.Unified); err != nil {
+			return err
+		} else if ds != "" {
+			changed = true
+			fmt.Print(coloredDiff(ds))
+		}
+	}
+
+	// task definition
+	if opt.Output == "json" {
+		toJSON, fromJSON, err := taskDefsJSONForDiff(to.TaskDefinition, from.TaskDefinition, opt.Normalize)
 		if err != nil {
-			return fmt.Errorf("failed to load service definition: %w", err)
+			return err
 		}
-		remoteSv, err := d.DescribeService(ctx)
+		rds, err := newResourceDiffs("taskDefinition", fromJSON, toJSON, from.TaskDefinitionName, to.TaskDefinitionName)
 		if err != nil {
-			return fmt.Errorf("failed to describe service: %w", err)
+			return err
 		}
+		resourceDiffs = append(resourceDiffs, rds...)
+	} else if ds, err := diffTaskDefs(to.TaskDefinition, from.TaskDefinition, from.TaskDefinitionName, to.TaskDefinitionName, opt.Unified, opt.Normalize); err != nil {
+		return err
+	} else if ds != "" {
+		changed = true
+		fmt.Print(coloredDiff(ds))
+	}
 
-		if ds, err := diffServices(newSv, remoteSv, *remoteSv.ServiceArn, d.config.ServiceDefinitionPath, opt.Unified); err != nil {
+	// diff for scaling definitions only when both have scaling definitions
+	if from.ScalingDefinition != nil && to.ScalingDefinition != nil {
+		if opt.Output == "json" {
+			toJSON, fromJSON, err := scalingDefinitionsJSONForDiff(to.ScalingDefinition, from.ScalingDefinition)
+			if err != nil {
+				return err
+			}
+			patch, err := JSONPatch(fromJSON, toJSON)
+			if err != nil {
+				return err
+			}
+			if patch == nil {
+				patch = []JSONPatchOperation{}
+			}
+			resourceDiffs = append(resourceDiffs, ResourceDiff{
+				Resource: "scalingDefinition",
+				From:     from.ScalingDefinitionName,
+				To:       to.ScalingDefinitionName,
+				Patch:    patch,
+			})
+		} else if ds, err := diffScalingDefinitions(to.ScalingDefinition, from.ScalingDefinition, from.ScalingDefinitionName, to.ScalingDefinitionName, opt.Unified); err != nil {
 			return err
 		} else if ds != "" {
+			changed = true
 			fmt.Print(coloredDiff(ds))
 		}
-		taskDefArn = *remoteSv.TaskDefinition
 	}
 
-	// task definition
-	newTd, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+	if opt.Output == "json" {
+		for _, rd := range resourceDiffs {
+			if len(rd.Patch) > 0 {
+				changed = true
+			}
+		}
+		b, err := json.MarshalIndent(resourceDiffs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff: %w", err)
+		}
+		fmt.Println(string(b))
+	}
+
+	if opt.ExitCode && changed {
+		return &ExitError{Code: ExitCodeDiffFound}
+	}
+	return nil
+}
+
+// diffSource loads definitions specified by src.
+func (d *App) diffSource(ctx context.Context, src string) (*diffSource, error) {
+	switch src {
+	case diffSourceLocal:
+		return d.localDiffSource()
+	case diffSourceRemote, "":
+		return d.remoteDiffSource(ctx)
+	default:
+		name, err := d.taskDefinitionNameForDiff(src)
+		if err != nil {
+			return nil, err
+		}
+		td, err := d.DescribeTaskDefinition(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return &diffSource{
+			TaskDefinition:     td,
+			TaskDefinitionName: name,
+		}, nil
+	}
+}
+
+// taskDefinitionNameForDiff resolves a revision number to family:revision by the local task definition.
+func (d *App) taskDefinitionNameForDiff(src string) (string, error) {
+	if _, err := strconv.ParseInt(src, 10, 64); err != nil {
+		return src, nil // family:revision or ARN
+	}
+	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 	if err != nil {
-		return err
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", aws.ToString(td.Family), src), nil
+}
+
+func (d *App) localDiffSource() (*diffSource, error) {
+	src := &diffSource{}
+	if d.config.Service != "" {
+		sv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load service definition: %w", err)
+		}
+		src.Service = sv
+		src.ServiceName = d.config.ServiceDefinitionPath
+	}
+	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+	if err != nil {
+		return nil, err
+	}
+	src.TaskDefinition = td
+	src.TaskDefinitionName = d.config.TaskDefinitionPath
+	if d.config.ScalingDefinitionPath != "" {
+		sd, err := d.LoadScalingDefinition(d.config.ScalingDefinitionPath)
+		if err != nil {
+			return nil, err
+		}
+		src.ScalingDefinition = sd
+		src.ScalingDefinitionName = d.config.ScalingDefinitionPath
+	}
+	return src, nil
+}
+
+func (d *App) remoteDiffSource(ctx context.Context) (*diffSource, error) {
+	src := &diffSource{}
+	var taskDefArn string
+	if d.config.Service != "" {
+		sv, err := d.DescribeService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe service: %w", err)
+		}
+		src.Service = sv
+		src.ServiceName = *sv.ServiceArn
+		taskDefArn = *sv.TaskDefinition
 	}
 	if taskDefArn == "" {
-		arn, err := d.findLatestTaskDefinitionArn(ctx, *newTd.Family)
+		td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		arn, err := d.findLatestTaskDefinitionArn(ctx, *td.Family)
+		if err != nil {
+			return nil, err
 		}
 		taskDefArn = arn
 	}
-	remoteTd, err := d.DescribeTaskDefinition(ctx, taskDefArn)
+	td, err := d.DescribeTaskDefinition(ctx, taskDefArn)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if ds, err := diffTaskDefs(newTd, remoteTd, taskDefArn, d.config.TaskDefinitionPath, opt.Unified); err != nil {
-		return err
-	} else if ds != "" {
-		fmt.Print(coloredDiff(ds))
+	src.TaskDefinition = td
+	src.TaskDefinitionName = taskDefArn
+	if d.config.Service != "" && d.config.ScalingDefinitionPath != "" {
+		sd, err := d.describeScalingDefinition(ctx)
+		if err != nil {
+			return nil, err
+		}
+		src.ScalingDefinition = sd
+		src.ScalingDefinitionName = d.scalingResourceId()
 	}
+	return src, nil
+}
 
-	return nil
+// diffSourceOfConfig renders definitions by the other config file.
+func (d *App) diffSourceOfConfig(ctx context.Context, path string) (*diffSource, error) {
+	app, err := d.newAppWithConfig(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := app.localDiffSource()
+	if err != nil {
+		return nil, err
+	}
+	src.ServiceName = path + ":" + src.ServiceName
+	src.TaskDefinitionName = path + ":" + src.TaskDefinitionName
+	src.ScalingDefinitionName = path + ":" + src.ScalingDefinitionName
+	return src, nil
 }
 
 type ServiceForDiff struct {
@@ -77,65 +293,133 @@ type ServiceForDiff struct {
 	Tags []types.Tag
 }
 
-func diffServices(local, remote *Service, remoteArn string, localPath string, unified bool) (string, error) {
+func servicesJSONForDiff(local, remote *Service) (localJSON, remoteJSON []byte, err error) {
 	localSvForDiff := ServiceDefinitionForDiff(local)
 	remoteSvForDiff := ServiceDefinitionForDiff(remote)
 
-	newSvBytes, err := MarshalJSONForAPI(localSvForDiff)
+	localJSON, err = MarshalJSONForAPI(localSvForDiff)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal new service definition: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal new service definition: %w", err)
 	}
 	if local.DesiredCount == nil {
 		// ignore DesiredCount when it in local is not defined.
-		remote.DesiredCount = nil
+		remoteSvForDiff.DesiredCount = nil
 	}
-	remoteSvBytes, err := MarshalJSONForAPI(remoteSvForDiff)
+	remoteJSON, err = MarshalJSONForAPI(remoteSvForDiff)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal remote service definition: %w", err)
-	}
-
-	remoteSv := string(remoteSvBytes)
-	newSv := string(newSvBytes)
-
-	if unified {
-		edits := myers.ComputeEdits(span.URIFromPath(remoteArn), remoteSv, newSv)
-		return fmt.Sprint(gotextdiff.ToUnified(remoteArn, localPath, remoteSv, edits)), nil
+		return nil, nil, fmt.Errorf("failed to marshal remote service definition: %w", err)
 	}
+	return localJSON, remoteJSON, nil
+}
 
-	ds := diff.Diff(remoteSv, newSv)
-	if ds == "" {
-		return ds, nil
+func diffServices(local, remote *Service, remoteArn string, localPath string, unified bool) (string, error) {
+	newSvBytes, remoteSvBytes, err := servicesJSONForDiff(local, remote)
+	if err != nil {
+		return "", err
 	}
-	return fmt.Sprintf("--- %s\n+++ %s\n%s", remoteArn, localPath, ds), nil
+	return diffJSONText(string(remoteSvBytes), string(newSvBytes), remoteArn, localPath, unified), nil
 }
 
-func diffTaskDefs(local, remote *TaskDefinitionInput, remoteArn string, localPath string, unified bool) (string, error) {
+func taskDefsJSONForDiff(local, remote *TaskDefinitionInput, normalize bool) (localJSON, remoteJSON []byte, err error) {
+	if normalize {
+		normalizeTaskDefinitionForDiff(local)
+		normalizeTaskDefinitionForDiff(remote)
+	}
 	sortTaskDefinitionForDiff(local)
 	sortTaskDefinitionForDiff(remote)
 
-	newTdBytes, err := MarshalJSONForAPI(local)
+	localJSON, err = MarshalJSONForAPI(local)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal new task definition: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal new task definition: %w", err)
 	}
 
-	remoteTdBytes, err := MarshalJSONForAPI(remote)
+	remoteJSON, err = MarshalJSONForAPI(remote)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal remote task definition: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal remote task definition: %w", err)
 	}
+	return localJSON, remoteJSON, nil
+}
 
-	remoteTd := string(remoteTdBytes)
-	newTd := string(newTdBytes)
+func diffTaskDefs(local, remote *TaskDefinitionInput, remoteArn string, localPath string, unified, normalize bool) (string, error) {
+	newTdBytes, remoteTdBytes, err := taskDefsJSONForDiff(local, remote, normalize)
+	if err != nil {
+		return "", err
+	}
+	return diffJSONText(string(remoteTdBytes), string(newTdBytes), remoteArn, localPath, unified), nil
+}
 
+func diffJSONText(remote, local string, remoteArn string, localPath string, unified bool) string {
 	if unified {
-		edits := myers.ComputeEdits(span.URIFromPath(remoteArn), remoteTd, newTd)
-		return fmt.Sprint(gotextdiff.ToUnified(remoteArn, localPath, remoteTd, edits)), nil
+		edits := myers.ComputeEdits(span.URIFromPath(remoteArn), remote, local)
+		return fmt.Sprint(gotextdiff.ToUnified(remoteArn, localPath, remote, edits))
 	}
 
-	ds := diff.Diff(remoteTd, newTd)
+	ds := diff.Diff(remote, local)
 	if ds == "" {
-		return ds, nil
+		return ds
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n%s", remoteArn, localPath, ds)
+}
+
+// newResourceDiffs computes JSON Patch operations from remote to local.
+// Tags are separated into the resource named {resource}Tags as a map of key and value.
+func newResourceDiffs(resource string, remoteJSON, localJSON []byte, remoteArn, localPath string) ([]ResourceDiff, error) {
+	remote, remoteTags, err := splitTagsForDiff(remoteJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", remoteArn, err)
+	}
+	local, localTags, err := splitTagsForDiff(localJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", localPath, err)
+	}
+	rds := make([]ResourceDiff, 0, 2)
+	for _, r := range []struct {
+		name          string
+		remote, local []byte
+	}{
+		{name: resource, remote: remote, local: local},
+		{name: resource + "Tags", remote: remoteTags, local: localTags},
+	} {
+		patch, err := JSONPatch(r.remote, r.local)
+		if err != nil {
+			return nil, err
+		}
+		if patch == nil {
+			patch = []JSONPatchOperation{}
+		}
+		rds = append(rds, ResourceDiff{
+			Resource: r.name,
+			From:     remoteArn,
+			To:       localPath,
+			Patch:    patch,
+		})
+	}
+	return rds, nil
+}
+
+func splitTagsForDiff(src []byte) (doc []byte, tags []byte, err error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(src, &m); err != nil {
+		return nil, nil, err
+	}
+	tagMap := map[string]string{}
+	if ts, ok := m["tags"].([]interface{}); ok {
+		for _, t := range ts {
+			if kv, ok := t.(map[string]interface{}); ok {
+				k, _ := kv["key"].(string)
+				v, _ := kv["value"].(string)
+				tagMap[k] = v
+			}
+		}
+	}
+	delete(m, "tags")
+	if doc, err = json.Marshal(m); err != nil {
+		return nil, nil, err
+	}
+	if tags, err = json.Marshal(tagMap); err != nil {
+		return nil, nil, err
 	}
-	return fmt.Sprintf("--- %s\n+++ %s\n%s", remoteArn, localPath, ds), nil
+	return doc, tags, nil
 }
 
 func coloredDiff(src string) string {
@@ -186,8 +470,10 @@ func ServiceDefinitionForDiff(sv *Service) *ServiceForDiff {
 	sort.SliceStable(sv.PlacementStrategy, func(i, j int) bool {
 		return jsonStr(sv.PlacementStrategy[i]) < jsonStr(sv.PlacementStrategy[j])
 	})
-	sort.SliceStable(sv.Tags, func(i, j int) bool {
-		return aws.ToString(sv.Tags[i].Key) < aws.ToString(sv.Tags[j].Key)
+	// reserved tags of sv are kept for the caller, e.g. chunks of a service snapshot
+	tags := excludeReservedTags(sv.Tags)
+	sort.SliceStable(tags, func(i, j int) bool {
+		return aws.ToString(tags[i].Key) < aws.ToString(tags[j].Key)
 	})
 	if sv.LaunchType == types.LaunchTypeFargate && sv.PlatformVersion == nil {
 		sv.PlatformVersion = aws.String("LATEST")
@@ -231,7 +517,7 @@ func ServiceDefinitionForDiff(sv *Service) *ServiceForDiff {
 	}
 	return &ServiceForDiff{
 		UpdateServiceInput: svToUpdateServiceInput(sv),
-		Tags:               sv.Tags,
+		Tags:               tags,
 	}
 }
 
@@ -0,0 +1,50 @@
+package ecspresso_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.Diff(ctx, ecspresso.DiffOption{
+		ConfigA:  "tests/diff-a.yml",
+		ConfigB:  "tests/diff-a.yml",
+		ExitCode: true,
+		Unified:  true,
+		Output:   "text",
+	})
+	if err != nil {
+		t.Errorf("unexpected error for the same configs: %s", err)
+	}
+
+	for _, output := range []string{"text", "json"} {
+		err = app.Diff(ctx, ecspresso.DiffOption{
+			ConfigA:  "tests/diff-a.yml",
+			ConfigB:  "tests/diff-b.yml",
+			ExitCode: true,
+			Unified:  true,
+			Output:   output,
+		})
+		var exitErr *ecspresso.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("ExitError is expected for different configs: %s", err)
+		} else if exitErr.Code != ecspresso.ExitCodeDiffFound {
+			t.Errorf("unexpected exit code %d", exitErr.Code)
+		}
+	}
+
+	err = app.Diff(ctx, ecspresso.DiffOption{ConfigA: "tests/diff-a.yml"})
+	var conflict ecspresso.ErrConflictOptions
+	if !errors.As(err, &conflict) {
+		t.Errorf("ErrConflictOptions is expected: %s", err)
+	}
+}
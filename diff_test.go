@@ -212,3 +212,30 @@ func TestServiceDefinitionDiffer(t *testing.T) {
 		t.Error("failed to SortTaskDefinitionForDiff", diff)
 	}
 }
+
+func TestServicesJSONForDiffDesiredCount(t *testing.T) {
+	testCases := []struct {
+		name   string
+		local  *int32
+		remote *int32
+		same   bool
+	}{
+		{name: "not defined in local", local: nil, remote: aws.Int32(3), same: true},
+		{name: "same", local: aws.Int32(3), remote: aws.Int32(3), same: true},
+		{name: "changed", local: aws.Int32(1), remote: aws.Int32(3), same: false},
+	}
+	for _, tc := range testCases {
+		local := &ecspresso.Service{DesiredCount: tc.local}
+		remote := &ecspresso.Service{DesiredCount: tc.remote}
+		localJSON, remoteJSON, err := ecspresso.ServicesJSONForDiff(local, remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := string(localJSON) == string(remoteJSON); same != tc.same {
+			t.Errorf("%s: expected same %v, got local %s remote %s", tc.name, tc.same, localJSON, remoteJSON)
+		}
+		if aws.ToInt32(remote.DesiredCount) != aws.ToInt32(tc.remote) {
+			t.Errorf("%s: remote service must not be modified", tc.name)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+type DriftOption struct {
+	Output  string   `help:"output format (text, json)" default:"text" enum:"text,json"`
+	Configs []string `arg:"" optional:"" help:"config files of services to check. the config file specified by --config is used when omitted"`
+}
+
+// Exit codes of drift.
+const (
+	ExitCodeDriftDetected = 2
+	ExitCodeDriftError    = 3
+)
+
+// Drift statuses of a service.
+const (
+	DriftStatusInSync  = "in_sync"
+	DriftStatusDrifted = "drifted"
+	DriftStatusError   = "error"
+)
+
+// DriftReport represents a result of drift detection for a service.
+type DriftReport struct {
+	Config                 string         `json:"config"`
+	Service                string         `json:"service"`
+	Status                 string         `json:"status"`
+	ServiceDrifted         bool           `json:"serviceDrifted"`
+	TaskDefinitionDrifted  bool           `json:"taskDefinitionDrifted"`
+	ScalingDrifted         bool           `json:"scalingDrifted"`
+	RunningTaskDefinition  string         `json:"runningTaskDefinition,omitempty"`
+	LatestTaskDefinition   string         `json:"latestTaskDefinition,omitempty"`
+	TaskDefinitionOutdated bool           `json:"taskDefinitionOutdated"`
+	Diffs                  []ResourceDiff `json:"diffs,omitempty"`
+	Error                  string         `json:"error,omitempty"`
+
+	textDiffs []string
+}
+
+func (d *App) Drift(ctx context.Context, opt DriftOption) error {
+	ctx, cancel := d.Start(ctx)
+	defer cancel()
+
+	var reports []*DriftReport
+	if len(opt.Configs) == 0 {
+		reports = append(reports, d.driftReport(ctx, d.option.ConfigFilePath))
+	}
+	for _, path := range opt.Configs {
+		app, err := d.newAppWithConfig(ctx, path)
+		if err != nil {
+			reports = append(reports, &DriftReport{
+				Config: path,
+				Status: DriftStatusError,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		reports = append(reports, app.driftReport(ctx, path))
+	}
+
+	switch opt.Output {
+	case "json":
+		b, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal drift reports: %w", err)
+		}
+		fmt.Println(string(b))
+	default:
+		printDriftReports(os.Stdout, reports)
+	}
+
+	if code := driftExitCode(reports); code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// driftReport detects drift of the service in the config.
+func (d *App) driftReport(ctx context.Context, configPath string) *DriftReport {
+	report, err := d.detectDrift(ctx)
+	if err != nil {
+		d.Log("[WARNING] failed to detect drift of %s: %s", d.Name(), err)
+		report = &DriftReport{
+			Status: DriftStatusError,
+			Error:  err.Error(),
+		}
+	}
+	report.Config = configPath
+	report.Service = d.Name()
+	return report
+}
+
+func (d *App) detectDrift(ctx context.Context) (*DriftReport, error) {
+	if d.config.Service == "" {
+		return nil, fmt.Errorf("service is not defined in the config")
+	}
+	local, err := d.localDiffSource()
+	if err != nil {
+		return nil, err
+	}
+	remote, err := d.remoteDiffSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	family, _, _ := strings.Cut(arnToName(remote.TaskDefinitionName), ":")
+	latest, err := d.findLatestTaskDefinitionArn(ctx, family)
+	if err != nil {
+		return nil, err
+	}
+	return detectDrift(local, remote, latest)
+}
+
+// detectDrift compares the local definitions with the remote ones.
+// remote.TaskDefinitionName must be the ARN of the running task definition.
+func detectDrift(local, remote *diffSource, latestTdArn string) (*DriftReport, error) {
+	report := &DriftReport{
+		RunningTaskDefinition: remote.TaskDefinitionName,
+		LatestTaskDefinition:  latestTdArn,
+	}
+
+	localJSON, remoteJSON, err := servicesJSONForDiff(local.Service, remote.Service)
+	if err != nil {
+		return nil, err
+	}
+	rds, err := newResourceDiffs("service", remoteJSON, localJSON, remote.ServiceName, local.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	if ds := diffJSONText(string(remoteJSON), string(localJSON), remote.ServiceName, local.ServiceName, true); ds != "" {
+		report.ServiceDrifted = true
+		report.textDiffs = append(report.textDiffs, ds)
+	}
+	report.Diffs = append(report.Diffs, rds...)
+
+	localJSON, remoteJSON, err = taskDefsJSONForDiff(local.TaskDefinition, remote.TaskDefinition, true)
+	if err != nil {
+		return nil, err
+	}
+	rds, err = newResourceDiffs("taskDefinition", remoteJSON, localJSON, remote.TaskDefinitionName, local.TaskDefinitionName)
+	if err != nil {
+		return nil, err
+	}
+	if ds := diffJSONText(string(remoteJSON), string(localJSON), remote.TaskDefinitionName, local.TaskDefinitionName, true); ds != "" {
+		report.TaskDefinitionDrifted = true
+		report.textDiffs = append(report.textDiffs, ds)
+	}
+	report.Diffs = append(report.Diffs, rds...)
+
+	if local.ScalingDefinition != nil && remote.ScalingDefinition != nil {
+		localJSON, remoteJSON, err = scalingDefinitionsJSONForDiff(local.ScalingDefinition, remote.ScalingDefinition)
+		if err != nil {
+			return nil, err
+		}
+		patch, err := JSONPatch(remoteJSON, localJSON)
+		if err != nil {
+			return nil, err
+		}
+		if patch == nil {
+			patch = []JSONPatchOperation{}
+		}
+		if ds := diffJSONText(string(remoteJSON), string(localJSON), remote.ScalingDefinitionName, local.ScalingDefinitionName, true); ds != "" {
+			report.ScalingDrifted = true
+			report.textDiffs = append(report.textDiffs, ds)
+		}
+		report.Diffs = append(report.Diffs, ResourceDiff{
+			Resource: "scalingDefinition",
+			From:     remote.ScalingDefinitionName,
+			To:       local.ScalingDefinitionName,
+			Patch:    patch,
+		})
+	}
+
+	report.TaskDefinitionOutdated = arnToName(remote.TaskDefinitionName) != arnToName(latestTdArn)
+	if report.ServiceDrifted || report.TaskDefinitionDrifted || report.ScalingDrifted || report.TaskDefinitionOutdated {
+		report.Status = DriftStatusDrifted
+	} else {
+		report.Status = DriftStatusInSync
+	}
+	return report, nil
+}
+
+// driftExitCode returns the exit code for the reports. error takes precedence over drifted.
+func driftExitCode(reports []*DriftReport) int {
+	code := 0
+	for _, r := range reports {
+		switch r.Status {
+		case DriftStatusError:
+			return ExitCodeDriftError
+		case DriftStatusDrifted:
+			code = ExitCodeDriftDetected
+		}
+	}
+	return code
+}
+
+func printDriftReports(w io.Writer, reports []*DriftReport) {
+	counts := map[string]int{}
+	for _, r := range reports {
+		counts[r.Status]++
+		switch r.Status {
+		case DriftStatusInSync:
+			fmt.Fprintf(w, "%s %s (%s)\n", color.GreenString("[in sync]"), r.Service, r.Config)
+		case DriftStatusDrifted:
+			fmt.Fprintf(w, "%s %s (%s)\n", color.YellowString("[drifted]"), r.Service, r.Config)
+			if r.ServiceDrifted {
+				fmt.Fprintln(w, "  service definition is drifted")
+			}
+			if r.TaskDefinitionDrifted {
+				fmt.Fprintln(w, "  task definition is drifted")
+			}
+			if r.ScalingDrifted {
+				fmt.Fprintln(w, "  scaling definition is drifted")
+			}
+			if r.TaskDefinitionOutdated {
+				fmt.Fprintf(w, "  running task definition %s is not the latest revision %s\n",
+					arnToName(r.RunningTaskDefinition), arnToName(r.LatestTaskDefinition))
+			}
+			for _, ds := range r.textDiffs {
+				fmt.Fprint(w, coloredDiff(ds))
+			}
+		default:
+			fmt.Fprintf(w, "%s %s (%s): %s\n", color.RedString("[error]"), r.Service, r.Config, r.Error)
+		}
+	}
+	fmt.Fprintf(w, "%d services: %d in sync, %d drifted, %d error\n",
+		len(reports), counts[DriftStatusInSync], counts[DriftStatusDrifted], counts[DriftStatusError])
+}
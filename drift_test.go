@@ -0,0 +1,130 @@
+package ecspresso_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/kayac/ecspresso/v2"
+)
+
+const (
+	driftTestRunningTdArn = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:3"
+	driftTestLatestTdArn  = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:4"
+)
+
+func TestDetectDrift(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/scaling-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(t *testing.T) (*ecspresso.Service, *ecspresso.TaskDefinitionInput) {
+		t.Helper()
+		sv, err := app.LoadServiceDefinition(app.Config().ServiceDefinitionPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		td, err := app.LoadTaskDefinition(app.Config().TaskDefinitionPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sv, td
+	}
+
+	testCases := []struct {
+		name     string
+		modify   func(localSv, remoteSv *ecspresso.Service, remoteTd *ecspresso.TaskDefinitionInput)
+		latest   string
+		status   string
+		service  bool
+		taskDef  bool
+		outdated bool
+	}{
+		{
+			name:   "in sync",
+			modify: func(localSv, remoteSv *ecspresso.Service, remoteTd *ecspresso.TaskDefinitionInput) {},
+			latest: driftTestRunningTdArn,
+			status: ecspresso.DriftStatusInSync,
+		},
+		{
+			name: "desired count is not defined in local",
+			modify: func(localSv, remoteSv *ecspresso.Service, remoteTd *ecspresso.TaskDefinitionInput) {
+				localSv.DesiredCount = nil
+				remoteSv.DesiredCount = aws.Int32(10)
+			},
+			latest: driftTestRunningTdArn,
+			status: ecspresso.DriftStatusInSync,
+		},
+		{
+			name: "service drifted",
+			modify: func(localSv, remoteSv *ecspresso.Service, remoteTd *ecspresso.TaskDefinitionInput) {
+				remoteSv.DesiredCount = aws.Int32(10)
+			},
+			latest:  driftTestRunningTdArn,
+			status:  ecspresso.DriftStatusDrifted,
+			service: true,
+		},
+		{
+			name: "task definition drifted",
+			modify: func(localSv, remoteSv *ecspresso.Service, remoteTd *ecspresso.TaskDefinitionInput) {
+				remoteTd.Cpu = aws.String("2048")
+			},
+			latest:  driftTestRunningTdArn,
+			status:  ecspresso.DriftStatusDrifted,
+			taskDef: true,
+		},
+		{
+			name:     "task definition outdated",
+			modify:   func(localSv, remoteSv *ecspresso.Service, remoteTd *ecspresso.TaskDefinitionInput) {},
+			latest:   driftTestLatestTdArn,
+			status:   ecspresso.DriftStatusDrifted,
+			outdated: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			localSv, localTd := load(t)
+			remoteSv, remoteTd := load(t)
+			tc.modify(localSv, remoteSv, remoteTd)
+			report, err := ecspresso.DetectDrift(localSv, remoteSv, localTd, remoteTd, driftTestRunningTdArn, tc.latest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Status != tc.status {
+				t.Errorf("unexpected status %s expected %s", report.Status, tc.status)
+			}
+			if report.ServiceDrifted != tc.service {
+				t.Errorf("unexpected ServiceDrifted %v", report.ServiceDrifted)
+			}
+			if report.TaskDefinitionDrifted != tc.taskDef {
+				t.Errorf("unexpected TaskDefinitionDrifted %v", report.TaskDefinitionDrifted)
+			}
+			if report.TaskDefinitionOutdated != tc.outdated {
+				t.Errorf("unexpected TaskDefinitionOutdated %v", report.TaskDefinitionOutdated)
+			}
+		})
+	}
+}
+
+func TestDriftExitCode(t *testing.T) {
+	testCases := []struct {
+		statuses []string
+		expected int
+	}{
+		{statuses: nil, expected: 0},
+		{statuses: []string{ecspresso.DriftStatusInSync, ecspresso.DriftStatusInSync}, expected: 0},
+		{statuses: []string{ecspresso.DriftStatusInSync, ecspresso.DriftStatusDrifted}, expected: ecspresso.ExitCodeDriftDetected},
+		{statuses: []string{ecspresso.DriftStatusError, ecspresso.DriftStatusDrifted}, expected: ecspresso.ExitCodeDriftError},
+		{statuses: []string{ecspresso.DriftStatusDrifted, ecspresso.DriftStatusError}, expected: ecspresso.ExitCodeDriftError},
+	}
+	for _, tc := range testCases {
+		var reports []*ecspresso.DriftReport
+		for _, s := range tc.statuses {
+			reports = append(reports, &ecspresso.DriftReport{Status: s})
+		}
+		if code := ecspresso.DriftExitCode(reports); code != tc.expected {
+			t.Errorf("unexpected exit code %d for %v expected %d", code, tc.statuses, tc.expected)
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"log"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +17,7 @@ import (
 	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
@@ -100,12 +100,14 @@ type App struct {
 	autoScaling *applicationautoscaling.Client
 	codedeploy  *codedeploy.Client
 	cwl         *cloudwatchlogs.Client
+	ecr         *ecr.Client
 	iam         *iam.Client
 	elbv2       *elasticloadbalancingv2.Client
 	sd          *servicediscovery.Client
 	verifier    *verifier
 
 	config *Config
+	option *Option
 	loader *configLoader
 	logger *log.Logger
 }
@@ -144,11 +146,13 @@ func New(ctx context.Context, opt *Option) (*App, error) {
 		autoScaling: applicationautoscaling.NewFromConfig(conf.awsv2Config),
 		codedeploy:  codedeploy.NewFromConfig(conf.awsv2Config),
 		cwl:         cloudwatchlogs.NewFromConfig(conf.awsv2Config),
+		ecr:         ecr.NewFromConfig(conf.awsv2Config),
 		iam:         iam.NewFromConfig(conf.awsv2Config),
 		elbv2:       elasticloadbalancingv2.NewFromConfig(conf.awsv2Config),
 		sd:          servicediscovery.NewFromConfig(conf.awsv2Config),
 
 		config: conf,
+		option: opt,
 		loader: loader,
 		logger: logger,
 	}
@@ -160,6 +164,14 @@ func (d *App) Config() *Config {
 	return d.config
 }
 
+// newAppWithConfig creates a new App with the other config file and the same options.
+func (d *App) newAppWithConfig(ctx context.Context, path string) (*App, error) {
+	opt := *d.option
+	opt.InitOption = nil
+	opt.ConfigFilePath = path
+	return New(ctx, &opt)
+}
+
 func (d *App) Timeout() time.Duration {
 	return d.config.Timeout.Duration
 }
@@ -342,14 +354,23 @@ func (d *App) DescribeTaskStatus(ctx context.Context, task *types.Task, watchCon
 		return fmt.Errorf(*f.Reason)
 	}
 
-	ts := out.Tasks[0]
+	_, err = taskStatus(&out.Tasks[0], watchContainer)
+	return err
+}
+
+// taskStatus returns the container for watching and an error when the task failed to start or the container exited abnormally.
+func taskStatus(ts *types.Task, watchContainer *types.ContainerDefinition) (*types.Container, error) {
 	if ts.StopCode == types.TaskStopCodeTaskFailedToStart {
-		return fmt.Errorf("task failed to start: %s", aws.ToString(ts.StoppedReason))
+		return nil, &taskStoppedError{failedToStart: true, reason: aws.ToString(ts.StoppedReason)}
+	}
+	if len(ts.Containers) == 0 {
+		return nil, fmt.Errorf("task has no containers: %s", aws.ToString(ts.StoppedReason))
 	}
 
 	var container *types.Container
 	for _, c := range ts.Containers {
 		if *c.Name == *watchContainer.Name {
+			c := c
 			container = &c
 			break
 		}
@@ -359,15 +380,15 @@ func (d *App) DescribeTaskStatus(ctx context.Context, task *types.Task, watchCon
 	}
 
 	if container.ExitCode != nil && *container.ExitCode != 0 {
-		msg := fmt.Sprintf("container: %s, exit code: %s", *container.Name, strconv.FormatInt(int64(*container.ExitCode), 10))
-		if container.Reason != nil {
-			msg += ", reason: " + *container.Reason
+		return container, &taskStoppedError{
+			container: *container.Name,
+			exitCode:  container.ExitCode,
+			reason:    aws.ToString(container.Reason),
 		}
-		return fmt.Errorf(msg)
 	} else if container.Reason != nil {
-		return fmt.Errorf("container: %s, reason: %s", *container.Name, *container.Reason)
+		return container, &taskStoppedError{container: *container.Name, reason: *container.Reason}
 	}
-	return nil
+	return container, nil
 }
 
 func (d *App) DescribeTaskDefinition(ctx context.Context, tdArn string) (*TaskDefinitionInput, error) {
@@ -382,6 +403,11 @@ func (d *App) DescribeTaskDefinition(ctx context.Context, tdArn string) (*TaskDe
 }
 
 func (d *App) GetLogEvents(ctx context.Context, logGroup string, logStream string, startedAt time.Time, nextToken *string) (*string, error) {
+	return d.getLogEvents(ctx, logGroup, logStream, startedAt, nextToken, "")
+}
+
+// getLogEvents prints log events with the prefix.
+func (d *App) getLogEvents(ctx context.Context, logGroup string, logStream string, startedAt time.Time, nextToken *string, prefix string) (*string, error) {
 	ms := startedAt.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
 	out, err := d.cwl.GetLogEvents(ctx, d.GetLogEventsInput(logGroup, logStream, ms, nextToken))
 	if err != nil {
@@ -391,7 +417,7 @@ func (d *App) GetLogEvents(ctx context.Context, logGroup string, logStream strin
 		return nextToken, nil
 	}
 	for _, event := range out.Events {
-		fmt.Println(formatLogEvent(event))
+		fmt.Println(prefix + formatLogEvent(event))
 	}
 	return out.NextForwardToken, nil
 }
@@ -518,6 +544,13 @@ func (d *App) LoadServiceDefinition(path string) (*Service, error) {
 }
 
 func (d *App) GetLogInfo(task *types.Task, c *types.ContainerDefinition) (string, string) {
+	logGroup, logStream := logInfo(task, c)
+	d.Log("logGroup: %s", logGroup)
+	d.Log("logStream: %s", logStream)
+	return logGroup, logStream
+}
+
+func logInfo(task *types.Task, c *types.ContainerDefinition) (string, string) {
 	p := strings.Split(*task.TaskArn, "/")
 	taskID := p[len(p)-1]
 	lc := c.LogConfiguration
@@ -525,10 +558,6 @@ func (d *App) GetLogInfo(task *types.Task, c *types.ContainerDefinition) (string
 
 	logStream := strings.Join([]string{logStreamPrefix, *c.Name, taskID}, "/")
 	logGroup := lc.Options["awslogs-group"]
-
-	d.Log("logGroup: %s", logGroup)
-	d.Log("logStream: %s", logStream)
-
 	return logGroup, logStream
 }
 
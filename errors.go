@@ -1,5 +1,7 @@
 package ecspresso
 
+import "fmt"
+
 type ErrSkipVerify string
 
 func (e ErrSkipVerify) Error() string {
@@ -18,6 +20,24 @@ func (e ErrConflictOptions) Error() string {
 	return string(e)
 }
 
+// ExitError represents an error with an exit code of the process.
+// Err may be nil when the process exits with the code without any error messages.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit code %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 var (
 	errNotFound   = ErrNotFound("not found")
 	errSkipVerify = ErrSkipVerify("skip verify")
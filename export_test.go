@@ -3,12 +3,19 @@ package ecspresso
 import (
 	"context"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
 var (
 	SortTaskDefinitionForDiff = sortTaskDefinitionForDiff
+	ServicesJSONForDiff       = servicesJSONForDiff
 	ToNumberCPU               = toNumberCPU
 	ToNumberMemory            = toNumberMemory
 	CalcDesiredCount          = calcDesiredCount
@@ -21,12 +28,33 @@ var (
 	NewConfigLoader           = newConfigLoader
 	NewVerifier               = newVerifier
 	ArnToName                 = arnToName
-	InitVerifyState           = initVerifyState
+	NewVerifyRunner           = newVerifyRunner
+	NewECRCredentials         = newECRCredentials
 	VerifyResource            = verifyResource
+	VerifyResourceWithKey     = verifyResourceWithKey
+	VerifyWarning             = verifyWarning
 	Map2str                   = map2str
+	ParseDeployHistory        = parseDeployHistory
+	FormatDeployHistory       = formatDeployHistory
+	DeployHistoryFromTags     = deployHistoryFromTags
+	ExcludeReservedTags       = excludeReservedTags
+	NewServiceSnapshot        = newServiceSnapshot
+	EncodeServiceSnapshot     = encodeServiceSnapshot
+	DecodeServiceSnapshot     = decodeServiceSnapshot
+	StaleServiceSnapshotKeys  = staleServiceSnapshotKeys
+	NewResourceDiffs          = newResourceDiffs
+	DiffTaskDefs              = diffTaskDefs
+	TdToTaskDefinitionInput   = tdToTaskDefinitionInput
+	NewScalingPlan            = newScalingPlan
+	DriftExitCode             = driftExitCode
+	ExecutionRolePermissions  = executionRolePermissions
+	TaskRolePermissions       = taskRolePermissions
 )
 
 type ModifyAutoScalingParams = modifyAutoScalingParams
+type ScalingPlan = scalingPlan
+type RolePermission = rolePermission
+type RolePermissions = rolePermissions
 
 func (d *App) SetLogger(logger *log.Logger) {
 	d.logger = logger
@@ -47,3 +75,237 @@ func ResetAWSV2ConfigLoadOptionsFunc() {
 func (d *App) TaskDefinitionArnForRun(ctx context.Context, opt RunOption) (string, error) {
 	return d.taskDefinitionArnForRun(ctx, opt)
 }
+
+func DetectDrift(localSv, remoteSv *Service, localTd, remoteTd *TaskDefinitionInput, runningTdArn, latestTdArn string) (*DriftReport, error) {
+	local := &diffSource{
+		Service:            localSv,
+		ServiceName:        "local",
+		TaskDefinition:     localTd,
+		TaskDefinitionName: "local",
+	}
+	remote := &diffSource{
+		Service:            remoteSv,
+		ServiceName:        "remote",
+		TaskDefinition:     remoteTd,
+		TaskDefinitionName: runningTdArn,
+	}
+	return detectDrift(local, remote, latestTdArn)
+}
+
+// RunCheck runs a check and renders the result.
+func (r *verifyRunner) RunCheck(ctx context.Context, name string, fn func(context.Context) error) error {
+	return r.Run(ctx, []verifyCheck{{name: name, fn: fn}})
+}
+
+func (r *verifyRunner) SetOutput(output string) {
+	r.output = output
+}
+
+func (r *verifyRunner) SetFailFast(failFast bool) {
+	r.failFast = failFast
+}
+
+type SubnetEgress = subnetEgress
+
+var (
+	RouteTableOfSubnet         = routeTableOfSubnet
+	SubnetEgressOf             = subnetEgressOf
+	PublicRegistryImages       = publicRegistryImages
+	SubnetReachabilityWarnings = subnetReachabilityWarnings
+)
+
+var (
+	ValidateServiceConnectServices = validateServiceConnectServices
+	ServiceConnectNamespace        = serviceConnectNamespace
+	ValidateServiceRegistry        = validateServiceRegistry
+)
+
+var (
+	ValidateLaunchConfiguration = validateLaunchConfiguration
+	MissingCapacityProviders    = missingCapacityProviders
+	PlacementConstraintsFilter  = placementConstraintsFilter
+	UsesContainerInstances      = usesContainerInstances
+)
+
+func Lint(td *TaskDefinitionInput, sv *Service, conf *ConfigLint) []*LintFinding {
+	return lint(&lintTarget{TaskDefinition: td, Service: sv, Config: conf})
+}
+
+func (c *ConfigLint) Validate() error {
+	return c.validate()
+}
+
+var ShannonEntropy = shannonEntropy
+
+// DetectSecrets returns kinds and masked values of secrets detected in the value.
+func DetectSecrets(value string) (kinds []string, masked []string) {
+	for _, f := range detectSecrets(value) {
+		kinds = append(kinds, f.kind)
+		masked = append(masked, f.masked)
+	}
+	return
+}
+
+type ImageScanFinding = imageScanFinding
+
+var (
+	ParseECRImage             = parseECRImage
+	EvaluateImageScanFindings = evaluateImageScanFindings
+)
+
+func FetchImageScanFindings(ctx context.Context, client ecr.DescribeImageScanFindingsAPIClient, image string, timeout, interval time.Duration) ([]imageScanFinding, error) {
+	img, _ := parseECRImage(image)
+	return fetchImageScanFindings(ctx, client, img.describeImageScanFindingsInput(), timeout, interval)
+}
+
+func (c *ConfigImageScan) Validate() error {
+	return c.validate()
+}
+
+func (r *imageScanResult) Summary() string {
+	return r.summary()
+}
+
+func (r *imageScanResult) Warning(severity string) string {
+	return r.warning(severity)
+}
+
+func (d *App) VerifyImageScan(ctx context.Context, client ecr.DescribeImageScanFindingsAPIClient, conf *ConfigImageScan, image string) error {
+	d.config.ImageScan = conf
+	return d.verifyImageScan(ctx, client, "app", image)
+}
+
+var ErrImageScanNotFound = errImageScanNotFound
+
+type ECRRegistry = ecrRegistry
+
+var (
+	ParseECRRegistry              = parseECRRegistry
+	PullThroughCacheUpstreamImage = pullThroughCacheUpstreamImage
+)
+
+type RunTaskRequest struct {
+	Overrides *types.TaskOverride
+	Count     int32
+}
+
+func RunTaskRequests(ovs []types.TaskOverride, count int32) []RunTaskRequest {
+	var reqs []RunTaskRequest
+	for _, r := range runTaskRequests(ovs, count) {
+		reqs = append(reqs, RunTaskRequest{Overrides: r.overrides, Count: r.count})
+	}
+	return reqs
+}
+
+// RunTaskResults returns the number of failed tasks and the rendered table of results.
+func RunTaskResults(tasks []types.Task, watchContainer *types.ContainerDefinition) (int, string) {
+	rs := newRunTaskResults(tasks, watchContainer)
+	var b strings.Builder
+	rs.OutputTable(&b)
+	return rs.failed(), b.String()
+}
+
+var (
+	TaskStatus        = taskStatus
+	PropagateExitCode = propagateExitCode
+)
+
+func NewRunTaskFailureError(reason string) error {
+	return &runTaskFailureError{failure: types.Failure{Reason: &reason}}
+}
+
+var (
+	ParseCommand         = parseCommand
+	ValidateTaskOverride = validateTaskOverride
+)
+
+func (d *App) TaskOverridesForRun(opt RunOption) ([]types.TaskOverride, error) {
+	return d.taskOverridesForRun(opt)
+}
+
+// ApplyOverrideFlags applies overrides by flags of opt to ov.
+func ApplyOverrideFlags(opt RunOption, ov *types.TaskOverride, defaultContainer string) error {
+	f, err := opt.overrideFlags()
+	if err != nil {
+		return err
+	}
+	f.apply(ov, defaultContainer)
+	return nil
+}
+
+var ParseCapacityProvider = parseCapacityProvider
+
+func (c *ConfigRun) Validate() error {
+	return c.validate()
+}
+
+func (c *ConfigRun) Apply(in *ecs.RunTaskInput) {
+	c.apply(in)
+}
+
+func (opt RunOption) RunConfig() (*ConfigRun, error) {
+	return opt.runConfig()
+}
+
+var IsRetryableRunError = isRetryableRunError
+
+func NewTaskFailedToStartError(reason string) error {
+	return &taskStoppedError{failedToStart: true, reason: reason}
+}
+
+func (opt RunOption) RunRetryConfig(conf *ConfigRun) (*ConfigRunRetry, error) {
+	return opt.runRetryConfig(conf)
+}
+
+// RetryRun calls f with the input of RunTask API for each attempt, which has the fallback capacity provider strategy applied after falling back.
+func (d *App) RetryRun(ctx context.Context, c *ConfigRunRetry, base *ecs.RunTaskInput, f func(attempt int, in *ecs.RunTaskInput) error) error {
+	r := newRunRetry(c)
+	return d.retryRun(ctx, r, func(attempt int) error {
+		in := *base
+		r.applyFallback(&in)
+		return f(attempt, &in)
+	})
+}
+
+var ExecuteCommandAgentStatus = executeCommandAgentStatus
+
+// ExecOption returns the command and the container to execute by run --exec, and opt without them.
+func (opt RunOption) ExecOption() (string, string, RunOption, error) {
+	eo, opt, err := opt.execOption()
+	if err != nil {
+		return "", "", opt, err
+	}
+	return eo.command, eo.container, opt, nil
+}
+
+var CLIExitCode = cliExitCode
+
+func (d *App) VerifyLint(ctx context.Context) error {
+	return d.verifyLint(ctx)
+}
+
+func (d *App) CheckPlaintextSecrets(td *TaskDefinitionInput, conf *ConfigLint) error {
+	d.config.Lint = conf
+	return d.checkPlaintextSecrets(td)
+}
+
+func (c *ecrCredentials) Do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	return c.do(ctx, key, fn)
+}
+
+func (d *App) RunTasksWithInput(ctx context.Context, in *ecs.RunTaskInput, ovs []types.TaskOverride, count int32) ([]types.Task, error) {
+	return d.runTasks(ctx, in, ovs, count, newRunRetry(nil))
+}
+
+func (d *App) RunAndWaitTask(ctx context.Context, c *ConfigRunRetry, in *ecs.RunTaskInput, watchContainer *types.ContainerDefinition, untilRunning bool) (*types.Task, error) {
+	return d.runAndWaitTask(ctx, in, &types.TaskOverride{}, watchContainer, untilRunning, newRunRetry(c))
+}
+
+func (d *App) RunAndWaitTasks(ctx context.Context, c *ConfigRunRetry, in *ecs.RunTaskInput, ovs []types.TaskOverride, count int32, watchContainer *types.ContainerDefinition, untilRunning bool) error {
+	return d.runAndWaitTasks(ctx, in, ovs, count, watchContainer, untilRunning, newRunRetry(c))
+}
+
+func (d *App) KMSKeyPermissions(ctx context.Context, getSecrets bool, ps rolePermissions, role arn.ARN) rolePermissions {
+	d.verifier = newVerifier(&d.config.awsv2Config, &d.config.awsv2Config, &VerifyOption{GetSecrets: getSecrets})
+	return d.kmsKeyPermissions(ctx, ps, role)
+}
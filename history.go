@@ -0,0 +1,122 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	// reservedTagPrefix is a prefix of tag keys managed by ecspresso.
+	// These tags are ignored when comparing tags in service definitions.
+	reservedTagPrefix = "ecspresso:"
+
+	// deployHistoryTagKey is a tag key of the service which holds the task definitions deployed recently.
+	deployHistoryTagKey = reservedTagPrefix + "deploy-history"
+
+	// maxTagValueLength is the maximum length of a tag value for ECS resources.
+	maxTagValueLength = 256
+)
+
+// parseDeployHistory parses a deploy history tag value to task definition names (family:revision).
+// The newest one is first.
+func parseDeployHistory(s string) []string {
+	return strings.Fields(s)
+}
+
+// formatDeployHistory formats task definition names to a deploy history tag value.
+// Older names are dropped to fit the value in the maximum length of a tag value.
+func formatDeployHistory(names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		n := len(name)
+		if b.Len() > 0 {
+			n++
+		}
+		if b.Len()+n > maxTagValueLength {
+			break
+		}
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(name)
+	}
+	return b.String()
+}
+
+// deployHistoryFromTags returns the task definition names in the deploy history tag.
+func deployHistoryFromTags(tags []types.Tag) []string {
+	for _, t := range tags {
+		if aws.ToString(t.Key) == deployHistoryTagKey {
+			return parseDeployHistory(aws.ToString(t.Value))
+		}
+	}
+	return nil
+}
+
+// excludeReservedTags returns tags without tags managed by ecspresso.
+func excludeReservedTags(tags []types.Tag) []types.Tag {
+	if tags == nil {
+		return nil
+	}
+	ts := make([]types.Tag, 0, len(tags))
+	for _, t := range tags {
+		if strings.HasPrefix(aws.ToString(t.Key), reservedTagPrefix) {
+			continue
+		}
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// recordDeployHistory records the deployed task definition at the top of the deploy history tag of the service.
+// The task definitions in removes are removed from the history.
+func (d *App) recordDeployHistory(ctx context.Context, serviceArn *string, tdArn string, removes ...string) error {
+	if serviceArn == nil {
+		d.Log("[DEBUG] service arn is unknown. skip recording deploy history")
+		return nil
+	}
+	if long, _ := isLongArnFormat(*serviceArn); !long {
+		d.Log("[WARNING] service %s is not a long arn format. deploy history can not be recorded", *serviceArn)
+		return nil
+	}
+	out, err := d.ecs.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{
+		ResourceArn: serviceArn,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tags for service: %w", err)
+	}
+
+	deployed := arnToName(tdArn)
+	history := []string{deployed}
+	for _, name := range deployHistoryFromTags(out.Tags) {
+		if name == deployed {
+			continue
+		}
+		removed := false
+		for _, r := range removes {
+			if name == arnToName(r) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			history = append(history, name)
+		}
+	}
+	value := formatDeployHistory(history)
+	d.Log("[DEBUG] recording deploy history: %s", value)
+	if _, err := d.ecs.TagResource(ctx, &ecs.TagResourceInput{
+		ResourceArn: serviceArn,
+		Tags: []types.Tag{
+			{Key: aws.String(deployHistoryTagKey), Value: aws.String(value)},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to record deploy history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package ecspresso_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestDeployHistory(t *testing.T) {
+	names := []string{"app:12", "app:11", "app:8"}
+	s := ecspresso.FormatDeployHistory(names)
+	if s != "app:12 app:11 app:8" {
+		t.Errorf("unexpected formatted history: %s", s)
+	}
+	if diff := cmp.Diff(names, ecspresso.ParseDeployHistory(s)); diff != "" {
+		t.Errorf("unexpected parsed history (-want +got):\n%s", diff)
+	}
+	if got := ecspresso.ParseDeployHistory(""); len(got) != 0 {
+		t.Errorf("unexpected parsed empty history: %v", got)
+	}
+}
+
+func TestFormatDeployHistoryTruncate(t *testing.T) {
+	var names []string
+	for i := 0; i < 100; i++ {
+		names = append(names, "my-long-family-name-of-task-definition:1234")
+	}
+	s := ecspresso.FormatDeployHistory(names)
+	if len(s) > 256 {
+		t.Errorf("formatted history is too long: %d", len(s))
+	}
+	for _, name := range strings.Fields(s) {
+		if name != names[0] {
+			t.Errorf("unexpected name in history: %s", name)
+		}
+	}
+}
+
+func TestDeployHistoryFromTags(t *testing.T) {
+	tags := []types.Tag{
+		{Key: aws.String("Env"), Value: aws.String("prod")},
+		{Key: aws.String("ecspresso:deploy-history"), Value: aws.String("app:3 app:1")},
+	}
+	if diff := cmp.Diff([]string{"app:3", "app:1"}, ecspresso.DeployHistoryFromTags(tags)); diff != "" {
+		t.Errorf("unexpected history (-want +got):\n%s", diff)
+	}
+	excluded := ecspresso.ExcludeReservedTags(tags)
+	if len(excluded) != 1 || aws.ToString(excluded[0].Key) != "Env" {
+		t.Errorf("unexpected excluded tags: %v", excluded)
+	}
+	if ecspresso.DeployHistoryFromTags(excluded) != nil {
+		t.Errorf("history must not be found")
+	}
+}
@@ -23,6 +23,7 @@ type InitOption struct {
 	Service               string `help:"ECS service name" required:""`
 	TaskDefinitionPath    string `help:"path to output task definition file" default:"ecs-task-def.json"`
 	ServiceDefinitionPath string `help:"path to output service definition file" default:"ecs-service-def.json"`
+	ScalingDefinitionPath string `help:"path to output scaling definition file. output only when the service has a scalable target" default:"ecs-scaling-def.json"`
 	ConfigFilePath        string
 	ForceOverwrite        bool `help:"overwrite existing files" default:"false"`
 	Jsonnet               bool `help:"output files as jsonnet format" default:"false"`
@@ -92,7 +93,7 @@ func (d *App) Init(ctx context.Context, opt InitOption) error {
 		if err != nil {
 			return fmt.Errorf("failed to list tags for service: %w", err)
 		}
-		sv.Tags = lt.Tags
+		sv.Tags = excludeReservedTags(lt.Tags)
 	}
 
 	// service-def
@@ -130,6 +131,33 @@ func (d *App) Init(ctx context.Context, opt InitOption) error {
 		}
 	}
 
+	// scaling-def
+	if sd, err := d.describeScalingDefinition(ctx); err != nil {
+		Log("[WARNING] failed to describe auto scaling settings: %s", err)
+	} else if sd.ScalableTarget != nil {
+		path := opt.ScalingDefinitionPath
+		if ext := filepath.Ext(path); opt.Jsonnet && ext == jsonExt {
+			path = strings.TrimSuffix(path, ext) + jsonnetExt
+		}
+		normalizeScalingDefinitionForDiff(sd)
+		b, err := MarshalJSONForAPI(sd)
+		if err != nil {
+			return fmt.Errorf("unable to marshal scaling definition to JSON: %w", err)
+		}
+		if opt.Jsonnet {
+			out, err := formatter.Format(path, string(b), formatter.DefaultOptions())
+			if err != nil {
+				return fmt.Errorf("unable to format scaling definition as Jsonnet: %w", err)
+			}
+			b = []byte(out)
+		}
+		d.Log("save scaling definition to %s", path)
+		if err := d.saveFile(path, b, CreateFileMode, opt.ForceOverwrite); err != nil {
+			return err
+		}
+		conf.ScalingDefinitionPath = path
+	}
+
 	// config
 	if sv.isCodeDeploy() {
 		info, err := d.findDeploymentInfo(ctx)
@@ -0,0 +1,112 @@
+package ecspresso
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPatchOperation represents an operation of JSON Patch (RFC 6902).
+type JSONPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON emits the value member for add, replace and test operations even if the value is null.
+func (op JSONPatchOperation) MarshalJSON() ([]byte, error) {
+	switch op.Op {
+	case "add", "replace", "test":
+		return json.Marshal(struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}{op.Op, op.Path, op.Value})
+	default:
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+}
+
+// JSONPatch computes JSON Patch operations to convert from into to.
+// from and to must be JSON documents.
+func JSONPatch(from, to []byte) ([]JSONPatchOperation, error) {
+	var f, t interface{}
+	if err := json.Unmarshal(from, &f); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &t); err != nil {
+		return nil, err
+	}
+	return jsonPatchValue(nil, "", f, t), nil
+}
+
+func jsonPatchValue(ops []JSONPatchOperation, path string, from, to interface{}) []JSONPatchOperation {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		if t, ok := to.(map[string]interface{}); ok {
+			return jsonPatchObject(ops, path, f, t)
+		}
+	case []interface{}:
+		if t, ok := to.([]interface{}); ok {
+			return jsonPatchArray(ops, path, f, t)
+		}
+	}
+	if reflect.DeepEqual(from, to) {
+		return ops
+	}
+	return append(ops, JSONPatchOperation{Op: "replace", Path: path, Value: to})
+}
+
+func jsonPatchObject(ops []JSONPatchOperation, path string, from, to map[string]interface{}) []JSONPatchOperation {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := path + "/" + escapeJSONPointer(k)
+		fv, inFrom := from[k]
+		tv, inTo := to[k]
+		switch {
+		case inFrom && !inTo:
+			ops = append(ops, JSONPatchOperation{Op: "remove", Path: p})
+		case !inFrom && inTo:
+			ops = append(ops, JSONPatchOperation{Op: "add", Path: p, Value: tv})
+		default:
+			ops = jsonPatchValue(ops, p, fv, tv)
+		}
+	}
+	return ops
+}
+
+func jsonPatchArray(ops []JSONPatchOperation, path string, from, to []interface{}) []JSONPatchOperation {
+	n := len(from)
+	if len(to) < n {
+		n = len(to)
+	}
+	for i := 0; i < n; i++ {
+		ops = jsonPatchValue(ops, path+"/"+strconv.Itoa(i), from[i], to[i])
+	}
+	// remove from the tail to keep indexes of the preceding elements
+	for i := len(from) - 1; i >= n; i-- {
+		ops = append(ops, JSONPatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+	for i := n; i < len(to); i++ {
+		ops = append(ops, JSONPatchOperation{Op: "add", Path: path + "/-", Value: to[i]})
+	}
+	return ops
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
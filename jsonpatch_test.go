@@ -0,0 +1,143 @@
+package ecspresso_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var testJSONPatches = []struct {
+	name string
+	from string
+	to   string
+	want []ecspresso.JSONPatchOperation
+}{
+	{
+		name: "same",
+		from: `{"a":1,"b":[1,2]}`,
+		to:   `{"b":[1,2],"a":1}`,
+		want: nil,
+	},
+	{
+		name: "object",
+		from: `{"a":1,"b":{"c":"x","d":true},"e":"removed"}`,
+		to:   `{"a":2,"b":{"c":"x","d":false},"f":"added"}`,
+		want: []ecspresso.JSONPatchOperation{
+			{Op: "replace", Path: "/a", Value: float64(2)},
+			{Op: "replace", Path: "/b/d", Value: false},
+			{Op: "remove", Path: "/e"},
+			{Op: "add", Path: "/f", Value: "added"},
+		},
+	},
+	{
+		name: "array shrink",
+		from: `{"a":[1,2,3,4]}`,
+		to:   `{"a":[1,5]}`,
+		want: []ecspresso.JSONPatchOperation{
+			{Op: "replace", Path: "/a/1", Value: float64(5)},
+			{Op: "remove", Path: "/a/3"},
+			{Op: "remove", Path: "/a/2"},
+		},
+	},
+	{
+		name: "array grow",
+		from: `{"a":[{"name":"x"}]}`,
+		to:   `{"a":[{"name":"y"},{"name":"z"}]}`,
+		want: []ecspresso.JSONPatchOperation{
+			{Op: "replace", Path: "/a/0/name", Value: "y"},
+			{Op: "add", Path: "/a/-", Value: map[string]interface{}{"name": "z"}},
+		},
+	},
+	{
+		name: "type changed",
+		from: `{"a":{"b":1}}`,
+		to:   `{"a":[1]}`,
+		want: []ecspresso.JSONPatchOperation{
+			{Op: "replace", Path: "/a", Value: []interface{}{float64(1)}},
+		},
+	},
+	{
+		name: "null value",
+		from: `{"a":1,"b":[1]}`,
+		to:   `{"a":null,"b":[1,null],"c":null}`,
+		want: []ecspresso.JSONPatchOperation{
+			{Op: "replace", Path: "/a", Value: nil},
+			{Op: "add", Path: "/b/-", Value: nil},
+			{Op: "add", Path: "/c", Value: nil},
+		},
+	},
+	{
+		name: "escape",
+		from: `{"a/b":1,"c~d":1}`,
+		to:   `{"a/b":2,"c~d":2}`,
+		want: []ecspresso.JSONPatchOperation{
+			{Op: "replace", Path: "/a~1b", Value: float64(2)},
+			{Op: "replace", Path: "/c~0d", Value: float64(2)},
+		},
+	},
+}
+
+func TestJSONPatch(t *testing.T) {
+	for _, tt := range testJSONPatches {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ecspresso.JSONPatch([]byte(tt.from), []byte(tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected patch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONPatchOperationMarshalJSON(t *testing.T) {
+	ops := []ecspresso.JSONPatchOperation{
+		{Op: "add", Path: "/a", Value: nil},
+		{Op: "replace", Path: "/b", Value: nil},
+		{Op: "test", Path: "/c", Value: nil},
+		{Op: "remove", Path: "/d"},
+		{Op: "replace", Path: "/e", Value: "x"},
+	}
+	b, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"op":"add","path":"/a","value":null},{"op":"replace","path":"/b","value":null},{"op":"test","path":"/c","value":null},{"op":"remove","path":"/d"},{"op":"replace","path":"/e","value":"x"}]`
+	if string(b) != want {
+		t.Errorf("unexpected JSON Patch %s", b)
+	}
+}
+
+func TestNewResourceDiffs(t *testing.T) {
+	remote := `{"cpu":"256","tags":[{"key":"Env","value":"dev"},{"key":"Removed","value":"x"}]}`
+	local := `{"cpu":"512","tags":[{"key":"Env","value":"prod"}]}`
+	rds, err := ecspresso.NewResourceDiffs("taskDefinition", []byte(remote), []byte(local), "arn", "td.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ecspresso.ResourceDiff{
+		{
+			Resource: "taskDefinition",
+			From:     "arn",
+			To:       "td.json",
+			Patch: []ecspresso.JSONPatchOperation{
+				{Op: "replace", Path: "/cpu", Value: "512"},
+			},
+		},
+		{
+			Resource: "taskDefinitionTags",
+			From:     "arn",
+			To:       "td.json",
+			Patch: []ecspresso.JSONPatchOperation{
+				{Op: "replace", Path: "/Env", Value: "prod"},
+				{Op: "remove", Path: "/Removed"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, rds); diff != "" {
+		t.Errorf("unexpected resource diffs (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,529 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Severities of lint rules.
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityOff     = "off"
+)
+
+// maxTaskDefinitionSize is the limit of the size of a task definition in bytes.
+const maxTaskDefinitionSize = 64 * 1024
+
+// ConfigLint represents a configuration of lint rules.
+type ConfigLint struct {
+	// Rules overrides severities of rules by rule ID. A rule is disabled by "off".
+	Rules map[string]string `yaml:"rules,omitempty" json:"rules,omitempty"`
+	// Secrets configures detecting plaintext secrets.
+	Secrets *ConfigLintSecrets `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+}
+
+func (c *ConfigLint) validate() error {
+	for id, severity := range c.Rules {
+		if findLintRule(id) == nil {
+			return fmt.Errorf("unknown lint rule %s", id)
+		}
+		switch severity {
+		case LintSeverityError, LintSeverityWarning, LintSeverityOff:
+		default:
+			return fmt.Errorf("invalid severity %s of lint rule %s", severity, id)
+		}
+	}
+	if c.Secrets != nil {
+		if err := c.Secrets.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lintTarget is a set of definitions to be linted. Service and Config may be nil.
+type lintTarget struct {
+	TaskDefinition *TaskDefinitionInput
+	Service        *Service
+	Config         *ConfigLint
+}
+
+// lintRule is a static check of definitions which needs no AWS API calls.
+type lintRule struct {
+	ID          string
+	Name        string
+	Severity    string
+	Description string
+	check       func(*lintTarget) []string
+}
+
+// LintFinding represents a violation of a lint rule.
+type LintFinding struct {
+	RuleID   string `json:"ruleId"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (f *LintFinding) String() string {
+	return fmt.Sprintf("[%s] %s(%s): %s", f.Severity, f.RuleID, f.Rule, f.Message)
+}
+
+var lintRules = []*lintRule{
+	{
+		ID:          "ECS001",
+		Name:        "fargate-cpu-memory",
+		Severity:    LintSeverityError,
+		Description: "cpu and memory of Fargate tasks must be a supported combination",
+		check:       lintFargateCPUMemory,
+	},
+	{
+		ID:          "ECS002",
+		Name:        "awsvpc-host-port",
+		Severity:    LintSeverityError,
+		Description: "hostPort must be same as containerPort for awsvpc networkMode",
+		check:       lintAwsvpcHostPort,
+	},
+	{
+		ID:          "ECS003",
+		Name:        "essential-container",
+		Severity:    LintSeverityError,
+		Description: "at least one container must be essential",
+		check:       lintEssentialContainer,
+	},
+	{
+		ID:          "ECS004",
+		Name:        "depends-on",
+		Severity:    LintSeverityError,
+		Description: "dependsOn must refer to defined containers without cycles",
+		check:       lintDependsOn,
+	},
+	{
+		ID:          "ECS005",
+		Name:        "duplicate-container",
+		Severity:    LintSeverityError,
+		Description: "container names must be unique",
+		check:       lintDuplicateContainer,
+	},
+	{
+		ID:          "ECS006",
+		Name:        "duplicate-port",
+		Severity:    LintSeverityError,
+		Description: "container ports and port mapping names must be unique",
+		check:       lintDuplicatePort,
+	},
+	{
+		ID:          "ECS007",
+		Name:        "health-check",
+		Severity:    LintSeverityWarning,
+		Description: "health checks must have a valid command and timings",
+		check:       lintHealthCheck,
+	},
+	{
+		ID:          "ECS008",
+		Name:        "load-balancer-container",
+		Severity:    LintSeverityError,
+		Description: "load balancers must refer to a container name and port defined in the task definition",
+		check:       lintLoadBalancerContainer,
+	},
+	{
+		ID:          "ECS009",
+		Name:        "task-definition-size",
+		Severity:    LintSeverityError,
+		Description: "task definition must not exceed 64KiB",
+		check:       lintTaskDefinitionSize,
+	},
+	{
+		ID:          "ECS010",
+		Name:        "plaintext-secret",
+		Severity:    LintSeverityWarning,
+		Description: "environment, command and entryPoint must not contain plaintext secrets",
+		check:       lintPlaintextSecret,
+	},
+}
+
+func findLintRule(id string) *lintRule {
+	for _, r := range lintRules {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// severity returns the severity of the rule overridden by the config.
+func (r *lintRule) severity(conf *ConfigLint) string {
+	if conf != nil {
+		if s, ok := conf.Rules[r.ID]; ok {
+			return s
+		}
+	}
+	return r.Severity
+}
+
+// run runs the rule and returns findings.
+func (r *lintRule) run(t *lintTarget) []*LintFinding {
+	severity := r.severity(t.Config)
+	if severity == LintSeverityOff {
+		return nil
+	}
+	var findings []*LintFinding
+	for _, msg := range r.check(t) {
+		findings = append(findings, &LintFinding{
+			RuleID:   r.ID,
+			Rule:     r.Name,
+			Severity: severity,
+			Message:  msg,
+		})
+	}
+	return findings
+}
+
+// lint runs all rules and returns findings.
+func lint(t *lintTarget) []*LintFinding {
+	var findings []*LintFinding
+	for _, r := range lintRules {
+		findings = append(findings, r.run(t)...)
+	}
+	return findings
+}
+
+// verifyLint adds checks of the lint rules.
+// Findings of warning severity are reported as warnings and don't fail the check.
+func (d *App) verifyLint(ctx context.Context) error {
+	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+	if err != nil {
+		return err
+	}
+	t := &lintTarget{TaskDefinition: td, Config: d.config.Lint}
+	if d.config.ServiceDefinitionPath != "" {
+		if t.Service, err = d.LoadServiceDefinition(d.config.ServiceDefinitionPath); err != nil {
+			return err
+		}
+	}
+	for _, r := range lintRules {
+		r := r
+		if r.severity(d.config.Lint) == LintSeverityOff {
+			continue
+		}
+		name := fmt.Sprintf("Rule[%s %s]", r.ID, r.Name)
+		verifyResource(ctx, name, func(ctx context.Context) error {
+			var errs []string
+			for _, f := range r.run(t) {
+				if f.Severity == LintSeverityWarning {
+					verifyWarning(ctx, "%s", f)
+					continue
+				}
+				errs = append(errs, f.Message)
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("%s", strings.Join(errs, ", "))
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+func isFargateDefinition(t *lintTarget) bool {
+	td := t.TaskDefinition
+	if len(td.RequiresCompatibilities) == 1 && td.RequiresCompatibilities[0] == types.CompatibilityFargate {
+		return true
+	}
+	sv := t.Service
+	if sv == nil {
+		return false
+	}
+	if sv.LaunchType == types.LaunchTypeFargate || aws.ToString(sv.PlatformVersion) != "" {
+		return true
+	}
+	for _, s := range sv.CapacityProviderStrategy {
+		switch aws.ToString(s.CapacityProvider) {
+		case "FARGATE", "FARGATE_SPOT":
+			return true
+		}
+	}
+	return false
+}
+
+// fargateMemoryRanges are supported memory ranges (min, max and step in MiB) for each cpu units.
+var fargateMemoryRanges = map[int][3]int{
+	256:   {1024, 2048, 1024}, // and 512
+	512:   {1024, 4096, 1024},
+	1024:  {2048, 8192, 1024},
+	2048:  {4096, 16384, 1024},
+	4096:  {8192, 30720, 1024},
+	8192:  {16384, 61440, 4096},
+	16384: {32768, 122880, 8192},
+}
+
+func lintFargateCPUMemory(t *lintTarget) []string {
+	if !isFargateDefinition(t) {
+		return nil
+	}
+	td := t.TaskDefinition
+	if td.Cpu == nil || td.Memory == nil {
+		return []string{"cpu and memory are required for Fargate tasks"}
+	}
+	cpu, err := strconv.Atoi(aws.ToString(toNumberCPU(*td.Cpu)))
+	if err != nil {
+		return []string{fmt.Sprintf("invalid cpu %s", *td.Cpu)}
+	}
+	mem, err := strconv.Atoi(aws.ToString(toNumberMemory(*td.Memory)))
+	if err != nil {
+		return []string{fmt.Sprintf("invalid memory %s", *td.Memory)}
+	}
+	r, ok := fargateMemoryRanges[cpu]
+	if !ok {
+		return []string{fmt.Sprintf("cpu %s is not supported by Fargate", *td.Cpu)}
+	}
+	if cpu == 256 && mem == 512 {
+		return nil
+	}
+	if mem < r[0] || mem > r[1] || (mem-r[0])%r[2] != 0 {
+		return []string{fmt.Sprintf("memory %s is not supported with cpu %s by Fargate", *td.Memory, *td.Cpu)}
+	}
+	return nil
+}
+
+func lintAwsvpcHostPort(t *lintTarget) []string {
+	td := t.TaskDefinition
+	if td.NetworkMode != types.NetworkModeAwsvpc {
+		return nil
+	}
+	var msgs []string
+	for _, c := range td.ContainerDefinitions {
+		for _, pm := range c.PortMappings {
+			if pm.HostPort != nil && aws.ToInt32(pm.ContainerPort) != aws.ToInt32(pm.HostPort) {
+				msgs = append(msgs, fmt.Sprintf(
+					"hostPort %d must be same as containerPort %d of container %s for awsvpc networkMode",
+					aws.ToInt32(pm.HostPort), aws.ToInt32(pm.ContainerPort), aws.ToString(c.Name),
+				))
+			}
+		}
+	}
+	return msgs
+}
+
+func lintEssentialContainer(t *lintTarget) []string {
+	for _, c := range t.TaskDefinition.ContainerDefinitions {
+		// essential is true by default
+		if c.Essential == nil || *c.Essential {
+			return nil
+		}
+	}
+	return []string{"no essential containers are defined"}
+}
+
+func lintDependsOn(t *lintTarget) []string {
+	var msgs []string
+	containers := map[string]types.ContainerDefinition{}
+	for _, c := range t.TaskDefinition.ContainerDefinitions {
+		containers[aws.ToString(c.Name)] = c
+	}
+	graph := map[string][]string{}
+	for _, c := range t.TaskDefinition.ContainerDefinitions {
+		name := aws.ToString(c.Name)
+		for _, dep := range c.DependsOn {
+			depName := aws.ToString(dep.ContainerName)
+			target, ok := containers[depName]
+			switch {
+			case !ok:
+				msgs = append(msgs, fmt.Sprintf("container %s depends on undefined container %s", name, depName))
+				continue
+			case depName == name:
+				msgs = append(msgs, fmt.Sprintf("container %s depends on itself", name))
+				continue
+			case dep.Condition == types.ContainerConditionHealthy && target.HealthCheck == nil:
+				msgs = append(msgs, fmt.Sprintf("container %s depends on %s to be HEALTHY, but %s has no healthCheck", name, depName, depName))
+			}
+			graph[name] = append(graph[name], depName)
+		}
+	}
+	if cycle := findDependencyCycle(graph); cycle != nil {
+		msgs = append(msgs, fmt.Sprintf("dependsOn has a cycle: %s", strings.Join(cycle, " -> ")))
+	}
+	return msgs
+}
+
+// findDependencyCycle returns a cycle in the graph, or nil if there are no cycles.
+func findDependencyCycle(graph map[string][]string) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(string) []string
+	visit = func(n string) []string {
+		switch state[n] {
+		case visiting:
+			for i, s := range stack {
+				if s == n {
+					return append(append([]string{}, stack[i:]...), n)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, m := range graph[n] {
+			if cycle := visit(m); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = visited
+		return nil
+	}
+	names := make([]string, 0, len(graph))
+	for n := range graph {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if cycle := visit(n); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func lintDuplicateContainer(t *lintTarget) []string {
+	var msgs []string
+	seen := map[string]bool{}
+	for _, c := range t.TaskDefinition.ContainerDefinitions {
+		name := aws.ToString(c.Name)
+		if seen[name] {
+			msgs = append(msgs, fmt.Sprintf("container %s is defined more than once", name))
+		}
+		seen[name] = true
+	}
+	return msgs
+}
+
+func lintDuplicatePort(t *lintTarget) []string {
+	var msgs []string
+	td := t.TaskDefinition
+	// containers share the network namespace in awsvpc and host networkMode
+	shared := td.NetworkMode == types.NetworkModeAwsvpc || td.NetworkMode == types.NetworkModeHost
+	ports := map[string]string{}
+	names := map[string]string{}
+	for _, c := range td.ContainerDefinitions {
+		cname := aws.ToString(c.Name)
+		if !shared {
+			ports = map[string]string{}
+		}
+		for _, pm := range c.PortMappings {
+			if pm.ContainerPort != nil {
+				protocol := pm.Protocol
+				if protocol == "" {
+					protocol = types.TransportProtocolTcp
+				}
+				key := fmt.Sprintf("%d/%s", aws.ToInt32(pm.ContainerPort), protocol)
+				if other, ok := ports[key]; ok {
+					msgs = append(msgs, fmt.Sprintf("containerPort %s of container %s is already used by container %s", key, cname, other))
+				} else {
+					ports[key] = cname
+				}
+			}
+			if name := aws.ToString(pm.Name); name != "" {
+				if other, ok := names[name]; ok {
+					msgs = append(msgs, fmt.Sprintf("port mapping name %s of container %s is already used by container %s", name, cname, other))
+				} else {
+					names[name] = cname
+				}
+			}
+		}
+	}
+	return msgs
+}
+
+func lintHealthCheck(t *lintTarget) []string {
+	var msgs []string
+	inRange := func(v *int32, min, max int32) bool {
+		return v == nil || (*v >= min && *v <= max)
+	}
+	for _, c := range t.TaskDefinition.ContainerDefinitions {
+		hc := c.HealthCheck
+		if hc == nil {
+			continue
+		}
+		name := aws.ToString(c.Name)
+		if len(hc.Command) == 0 {
+			msgs = append(msgs, fmt.Sprintf("healthCheck command of container %s is empty", name))
+		} else {
+			switch hc.Command[0] {
+			case "CMD", "CMD-SHELL", "NONE":
+			default:
+				msgs = append(msgs, fmt.Sprintf("healthCheck command of container %s must start with CMD or CMD-SHELL", name))
+			}
+		}
+		if !inRange(hc.Interval, 5, 300) {
+			msgs = append(msgs, fmt.Sprintf("healthCheck interval of container %s must be between 5 and 300", name))
+		}
+		if !inRange(hc.Timeout, 2, 60) {
+			msgs = append(msgs, fmt.Sprintf("healthCheck timeout of container %s must be between 2 and 60", name))
+		}
+		if !inRange(hc.Retries, 1, 10) {
+			msgs = append(msgs, fmt.Sprintf("healthCheck retries of container %s must be between 1 and 10", name))
+		}
+		if !inRange(hc.StartPeriod, 0, 300) {
+			msgs = append(msgs, fmt.Sprintf("healthCheck startPeriod of container %s must be between 0 and 300", name))
+		}
+		interval, timeout := int32(30), int32(5) // defaults
+		if hc.Interval != nil {
+			interval = *hc.Interval
+		}
+		if hc.Timeout != nil {
+			timeout = *hc.Timeout
+		}
+		if timeout >= interval {
+			msgs = append(msgs, fmt.Sprintf("healthCheck timeout %d of container %s should be less than interval %d", timeout, name, interval))
+		}
+	}
+	return msgs
+}
+
+func lintLoadBalancerContainer(t *lintTarget) []string {
+	if t.Service == nil {
+		return nil
+	}
+	var msgs []string
+LB:
+	for _, lb := range t.Service.LoadBalancers {
+		cname := aws.ToString(lb.ContainerName)
+		cport := aws.ToInt32(lb.ContainerPort)
+		for _, c := range t.TaskDefinition.ContainerDefinitions {
+			if aws.ToString(c.Name) != cname {
+				continue
+			}
+			for _, pm := range c.PortMappings {
+				if aws.ToInt32(pm.ContainerPort) == cport {
+					continue LB
+				}
+			}
+		}
+		msgs = append(msgs, fmt.Sprintf("container name %s and port %d is not defined in task definition", cname, cport))
+	}
+	return msgs
+}
+
+func lintTaskDefinitionSize(t *lintTarget) []string {
+	b, err := MarshalJSONForAPI(t.TaskDefinition)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to marshal task definition: %s", err)}
+	}
+	if len(b) > maxTaskDefinitionSize {
+		return []string{fmt.Sprintf("task definition size %d bytes exceeds %d bytes", len(b), maxTaskDefinitionSize)}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package ecspresso
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ConfigLintSecrets represents a configuration of detecting plaintext secrets.
+type ConfigLintSecrets struct {
+	// Allowlist is a list of regular expressions. Findings are ignored when the name of the environment variable or the detected value matches one of them.
+	Allowlist []string `yaml:"allowlist,omitempty" json:"allowlist,omitempty"`
+	// FailOnDeploy fails deploy before registering the task definition when plaintext secrets are detected.
+	FailOnDeploy bool `yaml:"fail_on_deploy,omitempty" json:"fail_on_deploy,omitempty"`
+
+	allowlist []*regexp.Regexp
+}
+
+func (c *ConfigLintSecrets) validate() error {
+	c.allowlist = nil
+	for _, s := range c.Allowlist {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return fmt.Errorf("invalid allowlist pattern %s: %w", s, err)
+		}
+		c.allowlist = append(c.allowlist, re)
+	}
+	return nil
+}
+
+func (c *ConfigLintSecrets) allowed(name, value string) bool {
+	if c == nil {
+		return false
+	}
+	for _, re := range c.allowlist {
+		if re.MatchString(name) || re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretPattern is a well-known pattern of credentials.
+type secretPattern struct {
+	name string
+	re   *regexp.Regexp
+	// group is the index of the submatch to be masked. 0 means the whole match.
+	group int
+}
+
+var secretPatterns = []secretPattern{
+	{name: "AWS access key ID", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "private key", re: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----`)},
+	{name: "GitHub token", re: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{name: "Slack token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{name: "Google API key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "Stripe secret key", re: regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{16,}\b`)},
+	{name: "JSON Web Token", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{name: "password in DSN", re: regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+:([^@/\s]+)@`), group: 1},
+}
+
+var highEntropyTokenRegex = regexp.MustCompile(`[A-Za-z0-9+/_-]{20,}={0,2}`)
+
+const (
+	highEntropyThreshold    = 4.0
+	highEntropyHexThreshold = 3.0
+	highEntropyHexMinLength = 32
+)
+
+var hexRegex = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// urlRegex matches URLs except for query strings and fragments, which may contain credentials.
+var urlRegex = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s?#]+`)
+
+// pathSegmentRegex matches a segment of paths which consists of lower case words, like "v1" or "callback-endpoint".
+var pathSegmentRegex = regexp.MustCompile(`^[A-Za-z]?[a-z0-9._-]*$`)
+
+// isPathLike reports whether s is a path separated by "/" like "com/v1/webhooks/callback-endpoint".
+// Base64 strings contain "/" too, but their segments are mixed case.
+func isPathLike(s string) bool {
+	if !strings.Contains(s, "/") {
+		return false
+	}
+	for _, seg := range strings.Split(s, "/") {
+		if !pathSegmentRegex.MatchString(seg) {
+			return false
+		}
+	}
+	return true
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	freq := map[rune]float64{}
+	for _, r := range s {
+		freq[r]++
+	}
+	var e float64
+	n := float64(len([]rune(s)))
+	for _, c := range freq {
+		p := c / n
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+func isHighEntropy(s string) bool {
+	if hexRegex.MatchString(s) {
+		return len(s) >= highEntropyHexMinLength && shannonEntropy(s) >= highEntropyHexThreshold
+	}
+	return shannonEntropy(s) >= highEntropyThreshold
+}
+
+// maskSecret masks s except for the first 4 characters.
+func maskSecret(s string) string {
+	r := []rune(s)
+	if len(r) <= 4 {
+		return "****"
+	}
+	return string(r[:4]) + "****"
+}
+
+// secretFinding is a plaintext secret detected in a value.
+type secretFinding struct {
+	kind   string
+	value  string
+	masked string
+}
+
+// detectSecrets detects well-known credential patterns and high entropy strings in the value.
+func detectSecrets(value string) []secretFinding {
+	var findings []secretFinding
+	for _, p := range secretPatterns {
+		for _, m := range p.re.FindAllStringSubmatchIndex(value, -1) {
+			start, end := m[2*p.group], m[2*p.group+1]
+			secret := value[start:end]
+			masked := maskSecret(secret)
+			if p.group != 0 {
+				// mask the submatch entirely, like a password in a DSN
+				masked = "****"
+			}
+			findings = append(findings, secretFinding{
+				kind:   p.name,
+				value:  secret,
+				masked: value[m[0]:start] + masked + value[end:m[1]],
+			})
+		}
+	}
+	if len(findings) > 0 {
+		return findings
+	}
+	// URLs and paths are not high entropy secrets, but often long and diverse enough to look like them
+	value = urlRegex.ReplaceAllString(value, " ")
+	for _, token := range highEntropyTokenRegex.FindAllString(value, -1) {
+		if isPathLike(token) {
+			continue
+		}
+		if isHighEntropy(token) {
+			findings = append(findings, secretFinding{
+				kind:   "high entropy string",
+				value:  token,
+				masked: maskSecret(token),
+			})
+		}
+	}
+	return findings
+}
+
+// lintPlaintextSecret detects plaintext secrets in environment, command and entryPoint of containers.
+func lintPlaintextSecret(t *lintTarget) []string {
+	var secrets *ConfigLintSecrets
+	if t.Config != nil {
+		secrets = t.Config.Secrets
+	}
+	var msgs []string
+	check := func(container, location, name, value string) {
+		for _, f := range detectSecrets(value) {
+			if secrets.allowed(name, f.value) {
+				continue
+			}
+			msgs = append(msgs, fmt.Sprintf("%s of container %s may contain a plaintext secret (%s): %s", location, container, f.kind, f.masked))
+		}
+	}
+	for _, c := range t.TaskDefinition.ContainerDefinitions {
+		cname := aws.ToString(c.Name)
+		for _, env := range c.Environment {
+			name := aws.ToString(env.Name)
+			check(cname, "environment "+name, name, aws.ToString(env.Value))
+		}
+		if len(c.Command) > 0 {
+			check(cname, "command", "command", strings.Join(c.Command, " "))
+		}
+		if len(c.EntryPoint) > 0 {
+			check(cname, "entryPoint", "entryPoint", strings.Join(c.EntryPoint, " "))
+		}
+	}
+	return msgs
+}
+
+// checkPlaintextSecrets returns an error when plaintext secrets are detected in the task definition
+// and fail_on_deploy is enabled by the config. It does nothing when the rule is turned off.
+func (d *App) checkPlaintextSecrets(td *TaskDefinitionInput) error {
+	conf := d.config.Lint
+	if conf == nil || conf.Secrets == nil || !conf.Secrets.FailOnDeploy {
+		return nil
+	}
+	if findLintRule("ECS010").severity(conf) == LintSeverityOff {
+		return nil
+	}
+	msgs := lintPlaintextSecret(&lintTarget{TaskDefinition: td, Config: conf})
+	if len(msgs) == 0 {
+		return nil
+	}
+	for _, msg := range msgs {
+		d.Log("[ERROR] %s", msg)
+	}
+	return fmt.Errorf("plaintext secrets are detected in the task definition. use secrets instead of environment")
+}
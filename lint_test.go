@@ -0,0 +1,257 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func lintTestTaskDefinition() *ecspresso.TaskDefinitionInput {
+	return &ecspresso.TaskDefinitionInput{
+		Family:                  aws.String("app"),
+		Cpu:                     aws.String("256"),
+		Memory:                  aws.String("512"),
+		NetworkMode:             types.NetworkModeAwsvpc,
+		RequiresCompatibilities: []types.Compatibility{types.CompatibilityFargate},
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:  aws.String("app"),
+				Image: aws.String("app:latest"),
+				PortMappings: []types.PortMapping{
+					{Name: aws.String("http"), ContainerPort: aws.Int32(8080), HostPort: aws.Int32(8080)},
+				},
+				HealthCheck: &types.HealthCheck{
+					Command:  []string{"CMD-SHELL", "curl -f http://localhost:8080/ || exit 1"},
+					Interval: aws.Int32(10),
+					Timeout:  aws.Int32(5),
+				},
+			},
+			{
+				Name:      aws.String("sidecar"),
+				Image:     aws.String("sidecar:latest"),
+				Essential: aws.Bool(false),
+				DependsOn: []types.ContainerDependency{
+					{ContainerName: aws.String("app"), Condition: types.ContainerConditionHealthy},
+				},
+			},
+		},
+	}
+}
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service)
+		rules  []string
+	}{
+		{
+			name:   "valid",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {},
+		},
+		{
+			name: "vCPU and GB notation",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.Cpu = aws.String("1 vCPU")
+				td.Memory = aws.String("3 GB")
+			},
+		},
+		{
+			name: "invalid fargate memory",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.Cpu = aws.String("256")
+				td.Memory = aws.String("1536")
+			},
+			rules: []string{"ECS001"},
+		},
+		{
+			name: "invalid fargate cpu",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.Cpu = aws.String("3072")
+				td.Memory = aws.String("8192")
+			},
+			rules: []string{"ECS001"},
+		},
+		{
+			name: "ec2 task is not checked for fargate combination",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.RequiresCompatibilities = []types.Compatibility{types.CompatibilityEc2}
+				td.Cpu = aws.String("3072")
+			},
+		},
+		{
+			name: "awsvpc host port",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[0].PortMappings[0].HostPort = aws.Int32(80)
+			},
+			rules: []string{"ECS002"},
+		},
+		{
+			name: "no essential containers",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[0].Essential = aws.Bool(false)
+			},
+			rules: []string{"ECS003"},
+		},
+		{
+			name: "depends on undefined container",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[1].DependsOn[0].ContainerName = aws.String("db")
+			},
+			rules: []string{"ECS004"},
+		},
+		{
+			name: "depends on healthy container without health check",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[0].HealthCheck = nil
+			},
+			rules: []string{"ECS004"},
+		},
+		{
+			name: "dependency cycle",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[0].DependsOn = []types.ContainerDependency{
+					{ContainerName: aws.String("sidecar"), Condition: types.ContainerConditionStart},
+				}
+			},
+			rules: []string{"ECS004"},
+		},
+		{
+			name: "duplicate container and port",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[1].Name = aws.String("app")
+				td.ContainerDefinitions[1].DependsOn = nil
+				td.ContainerDefinitions[1].PortMappings = []types.PortMapping{
+					{ContainerPort: aws.Int32(8080)},
+				}
+			},
+			rules: []string{"ECS005", "ECS006"},
+		},
+		{
+			name: "invalid health check",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[0].HealthCheck.Command = []string{"curl", "-f", "http://localhost:8080/"}
+				td.ContainerDefinitions[0].HealthCheck.Timeout = aws.Int32(30)
+			},
+			rules: []string{"ECS007", "ECS007"},
+		},
+		{
+			name: "load balancer container",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				sv.LoadBalancers = []types.LoadBalancer{
+					{ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)},
+					{ContainerName: aws.String("app"), ContainerPort: aws.Int32(80)},
+				}
+			},
+			rules: []string{"ECS008"},
+		},
+		{
+			name: "task definition size",
+			modify: func(td *ecspresso.TaskDefinitionInput, sv *ecspresso.Service) {
+				td.ContainerDefinitions[0].Environment = []types.KeyValuePair{
+					{Name: aws.String("LARGE"), Value: aws.String(strings.Repeat("x", 65536))},
+				}
+			},
+			rules: []string{"ECS009"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			td := lintTestTaskDefinition()
+			sv := &ecspresso.Service{}
+			tc.modify(td, sv)
+			var rules []string
+			for _, f := range ecspresso.Lint(td, sv, nil) {
+				rules = append(rules, f.RuleID)
+			}
+			if diff := cmp.Diff(tc.rules, rules); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	td := lintTestTaskDefinition()
+	td.ContainerDefinitions[0].Essential = aws.Bool(false)
+	td.ContainerDefinitions[0].PortMappings[0].HostPort = aws.Int32(80)
+
+	conf := &ecspresso.ConfigLint{
+		Rules: map[string]string{
+			"ECS002": ecspresso.LintSeverityOff,
+			"ECS003": ecspresso.LintSeverityWarning,
+		},
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	findings := ecspresso.Lint(td, nil, conf)
+	if len(findings) != 1 {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+	if f := findings[0]; f.RuleID != "ECS003" || f.Severity != ecspresso.LintSeverityWarning {
+		t.Errorf("unexpected finding: %s", f)
+	}
+
+	for _, rules := range []map[string]string{
+		{"ECS999": ecspresso.LintSeverityOff},
+		{"ECS001": "fatal"},
+	} {
+		conf := &ecspresso.ConfigLint{Rules: rules}
+		if err := conf.Validate(); err == nil {
+			t.Errorf("expected error for %v", rules)
+		}
+	}
+}
+
+func TestVerifyLint(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		config   string
+		status   string
+		warnings int
+	}{
+		// findings of error severity fail verify with or without --offline
+		{config: "tests/lint-error.yaml", status: ecspresso.VerifyStatusNG},
+		{config: "tests/lint-warning.yaml", status: ecspresso.VerifyStatusOK, warnings: 1},
+	}
+	for _, tc := range testCases {
+		app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: tc.config})
+		if err != nil {
+			t.Fatal(err)
+		}
+		runner := ecspresso.NewVerifyRunner(false, 2)
+		runner.SetOutput("json")
+		var verifyErr error
+		out := extractStdout(t, func() {
+			verifyErr = runner.RunCheck(ctx, "Lint", app.VerifyLint)
+		})
+		var results []*ecspresso.VerifyResult
+		if err := json.Unmarshal(out, &results); err != nil {
+			t.Fatal(err, string(out))
+		}
+		var ecs002 *ecspresso.VerifyResult
+		for _, r := range results {
+			if strings.HasPrefix(r.Name, "Rule[ECS002 ") {
+				ecs002 = r
+			}
+		}
+		if ecs002 == nil {
+			t.Fatalf("%s: result of ECS002 not found: %s", tc.config, string(out))
+		}
+		if ecs002.Status != tc.status {
+			t.Errorf("%s: unexpected status %s, want %s", tc.config, ecs002.Status, tc.status)
+		}
+		if (verifyErr != nil) != (tc.status == ecspresso.VerifyStatusNG) {
+			t.Errorf("%s: unexpected error %v", tc.config, verifyErr)
+		}
+		if len(ecs002.Warnings) != tc.warnings {
+			t.Errorf("%s: unexpected warnings %#v", tc.config, ecs002.Warnings)
+		}
+	}
+}
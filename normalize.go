@@ -0,0 +1,171 @@
+package ecspresso
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Default values of health check filled by ECS.
+// https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_HealthCheck.html
+const (
+	defaultHealthCheckInterval    = 30
+	defaultHealthCheckTimeout     = 5
+	defaultHealthCheckRetries     = 3
+	defaultHealthCheckStartPeriod = 0
+)
+
+// containerDefinitionDefaults is a table of normalizers which remove values filled by ECS as defaults in container definitions.
+var containerDefinitionDefaults = []struct {
+	name      string
+	normalize func(cd *types.ContainerDefinition)
+}{
+	{
+		name: "essential: true",
+		normalize: func(cd *types.ContainerDefinition) {
+			if aws.ToBool(cd.Essential) {
+				cd.Essential = nil
+			}
+		},
+	},
+	{
+		name: "portMappings[].protocol: tcp",
+		normalize: func(cd *types.ContainerDefinition) {
+			for i := range cd.PortMappings {
+				if cd.PortMappings[i].Protocol == types.TransportProtocolTcp {
+					cd.PortMappings[i].Protocol = ""
+				}
+			}
+		},
+	},
+	{
+		name: "mountPoints[].readOnly: false",
+		normalize: func(cd *types.ContainerDefinition) {
+			for i := range cd.MountPoints {
+				if mp := &cd.MountPoints[i]; mp.ReadOnly != nil && !*mp.ReadOnly {
+					mp.ReadOnly = nil
+				}
+			}
+		},
+	},
+	{
+		name: "volumesFrom[].readOnly: false",
+		normalize: func(cd *types.ContainerDefinition) {
+			for i := range cd.VolumesFrom {
+				if vf := &cd.VolumesFrom[i]; vf.ReadOnly != nil && !*vf.ReadOnly {
+					vf.ReadOnly = nil
+				}
+			}
+		},
+	},
+	{
+		name: "empty lists",
+		normalize: func(cd *types.ContainerDefinition) {
+			if len(cd.VolumesFrom) == 0 {
+				cd.VolumesFrom = nil
+			}
+			if len(cd.MountPoints) == 0 {
+				cd.MountPoints = nil
+			}
+			if len(cd.Ulimits) == 0 {
+				cd.Ulimits = nil
+			}
+			if len(cd.SystemControls) == 0 {
+				cd.SystemControls = nil
+			}
+		},
+	},
+	{
+		name: "healthCheck interval: 30, timeout: 5, retries: 3, startPeriod: 0",
+		normalize: func(cd *types.ContainerDefinition) {
+			hc := cd.HealthCheck
+			if hc == nil {
+				return
+			}
+			if aws.ToInt32(hc.Interval) == defaultHealthCheckInterval {
+				hc.Interval = nil
+			}
+			if aws.ToInt32(hc.Timeout) == defaultHealthCheckTimeout {
+				hc.Timeout = nil
+			}
+			if aws.ToInt32(hc.Retries) == defaultHealthCheckRetries {
+				hc.Retries = nil
+			}
+			if hc.StartPeriod != nil && *hc.StartPeriod == defaultHealthCheckStartPeriod {
+				hc.StartPeriod = nil
+			}
+		},
+	},
+}
+
+// volumeDefaults is a table of normalizers which remove values filled by ECS as defaults in volumes.
+var volumeDefaults = []struct {
+	name      string
+	normalize func(v *types.Volume)
+}{
+	{
+		name: "host: {}",
+		normalize: func(v *types.Volume) {
+			if v.Host != nil && v.Host.SourcePath == nil {
+				v.Host = nil
+			}
+		},
+	},
+	{
+		name: "dockerVolumeConfiguration.scope: task",
+		normalize: func(v *types.Volume) {
+			if c := v.DockerVolumeConfiguration; c != nil && c.Scope == types.ScopeTask {
+				c.Scope = ""
+			}
+		},
+	},
+	{
+		name: "efsVolumeConfiguration.rootDirectory: /, transitEncryption: DISABLED",
+		normalize: func(v *types.Volume) {
+			c := v.EfsVolumeConfiguration
+			if c == nil {
+				return
+			}
+			if aws.ToString(c.RootDirectory) == "/" {
+				c.RootDirectory = nil
+			}
+			if c.TransitEncryption == types.EFSTransitEncryptionDisabled {
+				c.TransitEncryption = ""
+			}
+		},
+	},
+}
+
+// proxyConfigurationDefaults is a table of normalizers which remove values filled by ECS as defaults in a proxy configuration.
+var proxyConfigurationDefaults = []struct {
+	name      string
+	normalize func(p *types.ProxyConfiguration)
+}{
+	{
+		name: "type: APPMESH",
+		normalize: func(p *types.ProxyConfiguration) {
+			if p.Type == types.ProxyConfigurationTypeAppmesh {
+				p.Type = ""
+			}
+		},
+	},
+}
+
+// normalizeTaskDefinitionForDiff removes values filled by ECS as defaults.
+// This must be applied to both of local and remote task definitions to avoid spurious diffs.
+func normalizeTaskDefinitionForDiff(td *TaskDefinitionInput) {
+	for i := range td.ContainerDefinitions {
+		for _, d := range containerDefinitionDefaults {
+			d.normalize(&td.ContainerDefinitions[i])
+		}
+	}
+	for i := range td.Volumes {
+		for _, d := range volumeDefaults {
+			d.normalize(&td.Volumes[i])
+		}
+	}
+	if td.ProxyConfiguration != nil {
+		for _, d := range proxyConfigurationDefaults {
+			d.normalize(td.ProxyConfiguration)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var testNormalizeTaskDefinitions = []struct {
+	name   string
+	local  string
+	remote string
+}{
+	{
+		name:   "fargate with health check",
+		local:  "tests/normalize/fargate-local.json",
+		remote: "tests/normalize/fargate-remote.json",
+	},
+	{
+		name:   "ec2 with volumes",
+		local:  "tests/normalize/ec2-volumes-local.json",
+		remote: "tests/normalize/ec2-volumes-remote.json",
+	},
+	{
+		name:   "appmesh proxy configuration",
+		local:  "tests/normalize/appmesh-local.json",
+		remote: "tests/normalize/appmesh-remote.json",
+	},
+}
+
+func loadDescribeTaskDefinitionFixture(t *testing.T, path string) *ecspresso.TaskDefinitionInput {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ecs.DescribeTaskDefinitionOutput
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return ecspresso.TdToTaskDefinitionInput(out.TaskDefinition, out.Tags)
+}
+
+func TestNormalizeTaskDefinitionForDiff(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range testNormalizeTaskDefinitions {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, normalize := range []bool{true, false} {
+				local, err := app.LoadTaskDefinition(tt.local)
+				if err != nil {
+					t.Fatal(err)
+				}
+				remote := loadDescribeTaskDefinitionFixture(t, tt.remote)
+				ds, err := ecspresso.DiffTaskDefs(local, remote, tt.remote, tt.local, true, normalize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if normalize && ds != "" {
+					t.Errorf("unexpected diff with normalize:\n%s", ds)
+				}
+				if !normalize && ds == "" {
+					t.Errorf("diff must be found without normalize")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	testUser     = "user"
+	testPassword = "password"
+	testToken    = "test-token"
+)
+
+func manifestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v2/org/app/manifests/v1" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+	json.NewEncoder(w).Encode(ocispec.Index{
+		Manifests: []ocispec.Descriptor{
+			{Platform: &ocispec.Platform{Architecture: "amd64", OS: "linux"}},
+		},
+	})
+}
+
+func newBasicAuthRegistry(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != testUser || p != testPassword {
+			w.Header().Set("Www-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		manifestHandler(w, r)
+	}))
+}
+
+func newTokenAuthRegistry(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if u, p, ok := r.BasicAuth(); !ok || u != testUser || p != testPassword {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:org/app:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": testToken})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="test",scope="repository:org/app:pull"`, srv.URL,
+			))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		manifestHandler(w, r)
+	}))
+	return srv
+}
+
+func TestAuth(t *testing.T) {
+	testCases := []struct {
+		name     string
+		server   func(*testing.T) *httptest.Server
+		user     string
+		password string
+		ok       bool
+	}{
+		{name: "basic", server: newBasicAuthRegistry, user: testUser, password: testPassword, ok: true},
+		{name: "basic without credentials", server: newBasicAuthRegistry},
+		{name: "basic with wrong password", server: newBasicAuthRegistry, user: testUser, password: "wrong"},
+		{name: "token", server: newTokenAuthRegistry, user: testUser, password: testPassword, ok: true},
+		{name: "token without credentials", server: newTokenAuthRegistry},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := tc.server(t)
+			defer srv.Close()
+			host := strings.TrimPrefix(srv.URL, "https://")
+			repo := registry.New(host+"/org/app", tc.user, tc.password, registry.WithHTTPClient(srv.Client()))
+			if repo.Host() != host {
+				t.Errorf("unexpected host %s", repo.Host())
+			}
+			ctx := context.Background()
+			ok, err := repo.HasImage(ctx, "v1")
+			if tc.ok {
+				if err != nil || !ok {
+					t.Fatalf("HasImage failed: %v %s", ok, err)
+				}
+				ok, err := repo.HasPlatformImage(ctx, "v1", "amd64", "linux")
+				if err != nil || !ok {
+					t.Errorf("HasPlatformImage failed: %v %s", ok, err)
+				}
+			} else if err == nil || ok {
+				t.Errorf("HasImage should fail: %v %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper script is not supported on windows")
+	}
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "docker-credential-test")
+	script := `#!/bin/sh
+read server
+if [ "$server" = "helper.example.com" ]; then
+  echo '{"ServerURL":"helper.example.com","Username":"helper-user","Secret":"helper-secret"}'
+else
+  echo "credentials not found in native keychain"
+  exit 1
+fi
+`
+	if err := os.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := fmt.Sprintf(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "%s"},
+    "https://ghcr.io": {"auth": "%s"},
+    "registry.example.com": {"username": "reg-user", "password": "reg-password"}
+  },
+  "credHelpers": {
+    "helper.example.com": "test",
+    "missing.example.com": "test"
+  }
+}`,
+		base64.StdEncoding.EncodeToString([]byte("hub-user:hub-password")),
+		base64.StdEncoding.EncodeToString([]byte("gh-user:gh:token")),
+	)
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+	if p := registry.DefaultDockerConfigPath(); p != path {
+		t.Errorf("unexpected default path %s", p)
+	}
+	dc, err := registry.LoadDockerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		image    string
+		user     string
+		password string
+	}{
+		{image: "debian", user: "hub-user", password: "hub-password"},
+		{image: "ghcr.io/org/app", user: "gh-user", password: "gh:token"},
+		{image: "registry.example.com/org/app", user: "reg-user", password: "reg-password"},
+		{image: "helper.example.com/org/app", user: "helper-user", password: "helper-secret"},
+		{image: "missing.example.com/org/app"},
+		{image: "unknown.example.com/org/app"},
+	}
+	for _, tc := range testCases {
+		host := registry.New(tc.image, "", "").Host()
+		user, password, err := dc.Credentials(host)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.image, err)
+			continue
+		}
+		if user != tc.user || password != tc.password {
+			t.Errorf("%s: unexpected credentials %s:%s", tc.image, user, password)
+		}
+	}
+}
+
+func TestAuthCacheByCredentials(t *testing.T) {
+	srv := newBasicAuthRegistry(t)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	cache := registry.NewCache()
+	ctx := context.Background()
+
+	// a failure with the wrong password must not be shared with the valid credentials
+	wrong := registry.New(host+"/org/app", testUser, "wrong", registry.WithHTTPClient(srv.Client()), registry.WithCache(cache))
+	if _, err := wrong.ResolveDigest(ctx, "v1"); err == nil {
+		t.Error("ResolveDigest with the wrong password should fail")
+	}
+	valid := registry.New(host+"/org/app", testUser, testPassword, registry.WithHTTPClient(srv.Client()), registry.WithCache(cache))
+	if _, err := valid.ResolveDigest(ctx, "v1"); err != nil {
+		t.Errorf("ResolveDigest with the valid credentials failed: %s", err)
+	}
+	anonymous := registry.New(host+"/org/app", "", "", registry.WithHTTPClient(srv.Client()), registry.WithCache(cache))
+	if _, err := anonymous.ResolveDigest(ctx, "v1"); err == nil {
+		t.Error("ResolveDigest without credentials should fail")
+	}
+}
@@ -2,6 +2,8 @@ package registry
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/shogo82148/go-retry"
 )
@@ -19,11 +22,18 @@ const (
 	dockerHubHost                      = "registry-1.docker.io"
 	mediaTypeDockerSchema2ManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
 	mediaTypeDockerSchema2Manifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerSchema2Config       = "application/vnd.docker.container.image.v1+json"
+	mediaTypeDockerSchema1Manifest     = "application/vnd.docker.distribution.manifest.v1+json"
+	mediaTypeDockerSchema1SignedManif  = "application/vnd.docker.distribution.manifest.v1+prettyjws"
 )
 
 var (
+	// ErrDeprecatedManifest was returned for schema1 manifests.
+	//
+	// Deprecated: schema1 manifests are supported and this error is no longer returned.
 	ErrDeprecatedManifest    = fmt.Errorf("deprecated image manifest")
 	ErrPullRateLimitExceeded = fmt.Errorf("image pull rate limit exceeded")
+	ErrNotFound              = fmt.Errorf("not found")
 
 	retryPolicy = retry.Policy{
 		MinDelay: time.Second,
@@ -32,25 +42,60 @@ var (
 	}
 )
 
+// manifestMediaTypes are media types of manifests accepted by the client in the order of preference.
+var manifestMediaTypes = []string{
+	ocispec.MediaTypeImageIndex,
+	mediaTypeDockerSchema2ManifestList,
+	ocispec.MediaTypeImageManifest,
+	mediaTypeDockerSchema2Manifest,
+	mediaTypeDockerSchema1SignedManif,
+	mediaTypeDockerSchema1Manifest,
+}
+
 // Repository represents a repository using Docker Registry API v2.
 type Repository struct {
-	client   *http.Client
-	host     string
-	repo     string
-	user     string
-	password string
-	token    string
+	client    *http.Client
+	host      string
+	repo      string
+	user      string
+	password  string
+	token     string
+	basicAuth bool
+	cache     *Cache
+}
+
+// Option is an option of Repository.
+type Option func(*Repository)
+
+// WithHTTPClient sets the HTTP client to access the registry.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Repository) {
+		c.client = client
+	}
+}
+
+// WithCache sets the cache of manifests and image configs shared by repositories.
+func WithCache(cache *Cache) Option {
+	return func(c *Repository) {
+		c.cache = cache
+	}
 }
 
 // New creates a client for a repository.
-func New(image, user, password string) *Repository {
+func New(image, user, password string, opts ...Option) *Repository {
 	c := &Repository{
 		client:   &http.Client{},
 		user:     user,
 		password: password,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.cache == nil {
+		c.cache = NewCache()
+	}
 	p := strings.SplitN(image, "/", 2)
-	if strings.Contains(p[0], ".") && len(p) >= 2 {
+	if (strings.Contains(p[0], ".") || strings.Contains(p[0], ":")) && len(p) >= 2 {
 		// Docker registry v2 API
 		c.host = p[0]
 		c.repo = p[1]
@@ -65,6 +110,25 @@ func New(image, user, password string) *Repository {
 	return c
 }
 
+// ParseReference splits an image reference into the repository and the tag or digest.
+// The tag is "latest" if neither a tag nor a digest is specified.
+func ParseReference(image string) (repository, reference string) {
+	if i := strings.Index(image, "@"); i != -1 {
+		// the tag is ignored when the digest is specified like "name:tag@digest"
+		repository, _ = ParseReference(image[:i])
+		return repository, image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i != -1 && !strings.Contains(image[i:], "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+// Host returns the host of the registry.
+func (c *Repository) Host() string {
+	return c.host
+}
+
 func (c *Repository) login(ctx context.Context, endpoint, service, scope string) error {
 	u, err := url.Parse(endpoint)
 	if err != nil {
@@ -79,7 +143,7 @@ func (c *Repository) login(ctx context.Context, endpoint, service, scope string)
 		return err
 	}
 	if c.user != "" && c.password != "" {
-		req.SetBasicAuth(c.user, c.password)
+		c.setBasicAuth(req)
 	}
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -91,90 +155,201 @@ func (c *Repository) login(ctx context.Context, endpoint, service, scope string)
 	}
 	dec := json.NewDecoder(resp.Body)
 	var body struct {
-		Token string `json:"Token"`
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
 	}
 	if err := dec.Decode(&body); err != nil {
 		return err
 	}
-	if body.Token == "" {
+	switch {
+	case body.Token != "":
+		c.token = body.Token
+	case body.AccessToken != "":
+		c.token = body.AccessToken
+	default:
 		return fmt.Errorf("response does not contains token")
 	}
-	c.token = body.Token
 	return nil
 }
 
-func (c *Repository) fetchManifests(ctx context.Context, method, tag string) (*http.Response, error) {
-	u := fmt.Sprintf("https://%s/v2/%s/manifests/%s", c.host, c.repo, tag)
-	req, err := http.NewRequestWithContext(ctx, method, u, nil)
-	if err != nil {
-		return nil, err
+// authenticate authenticates by the challenge in the WWW-Authenticate header.
+func (c *Repository) authenticate(ctx context.Context, challenge string) error {
+	switch {
+	case strings.HasPrefix(challenge, "Bearer "):
+		auth := strings.SplitN(challenge, " ", 2)[1]
+		e, svc, scope := parseAuthHeader(auth)
+		return c.login(ctx, e, svc, scope)
+	case strings.HasPrefix(challenge, "Basic "):
+		if c.user == "" || c.password == "" || c.basicAuth {
+			return fmt.Errorf("authentication required")
+		}
+		c.basicAuth = true
+		return nil
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
 	}
-	req.Header.Set("Accept", strings.Join([]string{
-		mediaTypeDockerSchema2ManifestList,
-		ocispec.MediaTypeImageIndex,
-		mediaTypeDockerSchema2Manifest,
-		ocispec.MediaTypeImageManifest}, ", "))
-	c.setAuthHeader(req)
-	return c.client.Do(req)
 }
 
-func (c *Repository) getAvailability(ctx context.Context, tag string) (*http.Response, error) {
-	resp, err := c.fetchManifests(ctx, http.MethodHead, tag)
-	if err != nil {
-		return nil, err
+// do sends a request, authenticating by the challenge of the registry when the response is 401.
+func (c *Repository) do(ctx context.Context, method, u string, accept []string) (*http.Response, error) {
+	for i := 0; ; i++ {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		c.setAuthHeader(req)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || i > 0 {
+			return resp, nil
+		}
+		challenge := resp.Header.Get("Www-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(ctx, challenge); err != nil {
+			return nil, fmt.Errorf("%s: %w", resp.Status, err)
+		}
 	}
-	resp.Body.Close()
-	return resp, nil
 }
 
-func (c *Repository) getManifests(ctx context.Context, tag string) (mediaType string, _ io.ReadCloser, _ error) {
-	retrier := retryPolicy.Start(ctx)
-	var lastErr error
-	for retrier.Continue() {
-		resp, err := c.fetchManifests(ctx, http.MethodGet, tag)
-		if err == nil && resp.StatusCode == http.StatusOK {
-			mediaType = parseContentType(resp.Header.Get("Content-Type"))
-			return mediaType, resp.Body, nil
-		}
-		switch resp.StatusCode {
-		case http.StatusNotFound, http.StatusUnauthorized:
-			// should not be retried
-			return "", nil, fmt.Errorf("faild to fetch manifests: %s", resp.Status)
-		case http.StatusTooManyRequests:
-			lastErr = ErrPullRateLimitExceeded
-		default:
-			lastErr = fmt.Errorf(resp.Status)
+// content is a manifest or a blob fetched from the registry.
+type content struct {
+	mediaType string
+	digest    string
+	body      []byte
+}
+
+// credentialsKey identifies the credentials of the repository in the cache key without exposing them.
+func (c *Repository) credentialsKey() string {
+	if c.user == "" && c.password == "" {
+		return "anonymous"
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(c.user+"\x00"+c.password)))
+}
+
+// fetch fetches a manifest or a blob with retries, using the cache.
+// Cached contents are shared by repositories, so they must not be modified.
+// The cache key includes the credentials, not to share failures of authentication or authorization with other credentials.
+func (c *Repository) fetch(ctx context.Context, kind, ref string, accept []string) (*content, error) {
+	key := fmt.Sprintf("%s/%s/%s/%s/%s", c.host, c.repo, kind, ref, c.credentialsKey())
+	return c.cache.get(key, func() (*content, error) {
+		u := fmt.Sprintf("https://%s/v2/%s/%s/%s", c.host, c.repo, kind, ref)
+		retrier := retryPolicy.Start(ctx)
+		var lastErr error
+		for retrier.Continue() {
+			resp, err := c.do(ctx, http.MethodGet, u, accept)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if resp.StatusCode == http.StatusOK {
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					return nil, err
+				}
+				ct := &content{
+					mediaType: parseContentType(resp.Header.Get("Content-Type")),
+					digest:    resp.Header.Get("Docker-Content-Digest"),
+					body:      body,
+				}
+				if ct.digest == "" {
+					ct.digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+				}
+				if kind == "manifests" {
+					ct.mediaType = manifestMediaType(ct.mediaType, body)
+				}
+				return ct, nil
+			}
+			resp.Body.Close()
+			switch resp.StatusCode {
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("faild to fetch %s: %s: %w", kind, resp.Status, ErrNotFound)
+			case http.StatusUnauthorized, http.StatusForbidden:
+				// should not be retried
+				return nil, fmt.Errorf("faild to fetch %s: %s", kind, resp.Status)
+			case http.StatusTooManyRequests:
+				lastErr = ErrPullRateLimitExceeded
+			default:
+				lastErr = fmt.Errorf(resp.Status)
+			}
 		}
+		return nil, fmt.Errorf("faild to fetch %s: %w", kind, lastErr)
+	})
+}
+
+func (c *Repository) getManifest(ctx context.Context, ref string) (*content, error) {
+	return c.fetch(ctx, "manifests", ref, manifestMediaTypes)
+}
+
+// manifestMediaType returns the media type of the manifest by Content-Type,
+// or by the body when Content-Type is not a media type of manifests.
+func manifestMediaType(contentType string, body []byte) string {
+	if contentType != "" && contentType != "application/json" && contentType != "text/plain" {
+		return contentType
+	}
+	// some registries don't return a media type of the manifest in Content-Type
+	var m struct {
+		MediaType     string `json:"mediaType"`
+		SchemaVersion int    `json:"schemaVersion"`
+		Manifests     []json.RawMessage
+	}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return contentType
+	}
+	switch {
+	case m.MediaType != "":
+		return m.MediaType
+	case m.SchemaVersion == 1:
+		return mediaTypeDockerSchema1Manifest
+	case m.Manifests != nil:
+		return ocispec.MediaTypeImageIndex
+	default:
+		return ocispec.MediaTypeImageManifest
 	}
-	return "", nil, fmt.Errorf("faild to fetch manifests: %w", lastErr)
 }
 
-func (c *Repository) getImageConfig(ctx context.Context, digest string) (io.ReadCloser, error) {
-	u := fmt.Sprintf("https://%s/v2/%s/blobs/%s", c.host, c.repo, digest)
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-	req.Header.Set("Accept", strings.Join([]string{
-		"application/vnd.docker.container.image.v1+json",
-		ocispec.MediaTypeImageConfig,
-	}, ", "))
-	c.setAuthHeader(req)
-	resp, err := c.client.Do(req)
+// getImagePlatform returns the platform in the image config.
+// https://github.com/opencontainers/image-spec/blob/main/config.md#properties
+func (c *Repository) getImagePlatform(ctx context.Context, digest string) (*Platform, error) {
+	ct, err := c.fetch(ctx, "blobs", digest, []string{mediaTypeDockerSchema2Config, ocispec.MediaTypeImageConfig})
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf(resp.Status)
+	var p Platform
+	if err := json.Unmarshal(ct.body, &p); err != nil {
+		return nil, fmt.Errorf("image config decode error: %w", err)
 	}
-	return resp.Body, err
+	return &p, nil
 }
 
 func (c *Repository) setAuthHeader(req *http.Request) {
-	if c.user == "AWS" && c.password != "" {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user == "AWS" && c.password != "" {
 		// ECR
+		c.setBasicAuth(req)
+	} else if c.basicAuth {
+		c.setBasicAuth(req)
+	}
+}
+
+func (c *Repository) setBasicAuth(req *http.Request) {
+	if c.user == "AWS" && isBase64Token(c.password) {
+		// an authorization token of ECR is already encoded as "AWS:password"
 		req.Header.Set("Authorization", "Basic "+c.password)
-	} else if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+		return
 	}
+	req.SetBasicAuth(c.user, c.password)
+}
+
+func isBase64Token(s string) bool {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return err == nil && strings.HasPrefix(string(b), "AWS:")
 }
 
 func parseContentType(contentType string) (mediaType string) {
@@ -182,75 +357,96 @@ func parseContentType(contentType string) (mediaType string) {
 	if i := strings.IndexByte(contentType, ';'); i != -1 {
 		mediaType = contentType[0:i]
 	}
-	return
+	return strings.TrimSpace(mediaType)
 }
 
-func match(want, got string) bool {
-	// if want is empty, skip verify
-	return want == "" || want == got
+// ResolveDigest returns the digest of the manifest referred by the tag or digest.
+func (c *Repository) ResolveDigest(ctx context.Context, ref string) (string, error) {
+	if _, err := digest.Parse(ref); err == nil {
+		return ref, nil
+	}
+	ct, err := c.getManifest(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return ct.digest, nil
 }
 
-// HasPlatformImage returns an image tag for arch/os exists or not in the repository.
-func (c *Repository) HasPlatformImage(ctx context.Context, tag, arch, os string) (bool, error) {
-	mediaType, rc, err := c.getManifests(ctx, tag)
+// Platforms returns platforms of the image referred by the tag or digest.
+func (c *Repository) Platforms(ctx context.Context, ref string) ([]Platform, error) {
+	ct, err := c.getManifest(ctx, ref)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	defer rc.Close()
-	dec := json.NewDecoder(rc)
-	switch mediaType {
-	case
-		ocispec.MediaTypeImageIndex,
-		mediaTypeDockerSchema2ManifestList:
-		var manifestList ocispec.Index
-		if err := dec.Decode(&manifestList); err != nil {
-			return false, fmt.Errorf("manifest list decode error: %w", err)
+	switch ct.mediaType {
+	case ocispec.MediaTypeImageIndex, mediaTypeDockerSchema2ManifestList:
+		var index ocispec.Index
+		if err := json.Unmarshal(ct.body, &index); err != nil {
+			return nil, fmt.Errorf("manifest list decode error: %w", err)
 		}
 		// https://github.com/opencontainers/image-spec/blob/main/image-index.md#image-index-property-descriptions
-		for _, desc := range manifestList.Manifests {
-			p := desc.Platform
-			if p == nil {
-				// regard as non platform-specific image
-				return true, nil
+		var platforms []Platform
+		for _, desc := range index.Manifests {
+			if isAttestationManifest(desc) {
+				continue
+			}
+			if p := desc.Platform; p != nil {
+				platforms = append(platforms, Platform{Architecture: p.Architecture, OS: p.OS, Variant: p.Variant})
+				continue
 			}
-			if match(arch, p.Architecture) && match(os, p.OS) {
-				return true, nil
+			// resolve the platform of the child manifest
+			ps, err := c.Platforms(ctx, desc.Digest.String())
+			if err != nil {
+				return nil, err
 			}
+			platforms = append(platforms, ps...)
 		}
-	case
-		mediaTypeDockerSchema2Manifest,
-		ocispec.MediaTypeImageManifest:
+		return platforms, nil
+	case ocispec.MediaTypeImageManifest, mediaTypeDockerSchema2Manifest:
 		var manifest ocispec.Manifest
-		if err := dec.Decode(&manifest); err != nil {
-			return false, fmt.Errorf("manifest decode error: %w", err)
+		if err := json.Unmarshal(ct.body, &manifest); err != nil {
+			return nil, fmt.Errorf("manifest decode error: %w", err)
 		}
 		if p := manifest.Config.Platform; p != nil {
-			if match(arch, p.OS) && match(os, p.Architecture) {
-				return true, nil
-			}
+			return []Platform{{Architecture: p.Architecture, OS: p.OS, Variant: p.Variant}}, nil
 		}
-
 		// fallback to image config
-		// https://github.com/opencontainers/image-spec/blob/main/config.md#properties
-		rc, err := c.getImageConfig(ctx, manifest.Config.Digest.String())
+		p, err := c.getImagePlatform(ctx, manifest.Config.Digest.String())
 		if err != nil {
-			return false, err
+			return nil, err
 		}
-		defer rc.Close()
-		var image ocispec.Image
-		if err := json.NewDecoder(rc).Decode(&image); err != nil {
-			return false, fmt.Errorf("image config decode error: %w", err)
+		return []Platform{*p}, nil
+	case mediaTypeDockerSchema1Manifest, mediaTypeDockerSchema1SignedManif:
+		// https://docs.docker.com/registry/spec/deprecated-schema-v1/
+		var manifest struct {
+			Architecture string `json:"architecture"`
 		}
-		if match(arch, image.Architecture) && match(os, image.OS) {
-			return true, nil
+		if err := json.Unmarshal(ct.body, &manifest); err != nil {
+			return nil, fmt.Errorf("schema1 manifest decode error: %w", err)
 		}
-	case
-		// https://docs.docker.com/registry/spec/deprecated-schema-v1/
-		"application/vnd.docker.distribution.manifest.v1+prettyjws",
-		"application/vnd.docker.distribution.manifest.v1+json":
-		return false, ErrDeprecatedManifest
+		// schema1 manifests have no os. regard as linux.
+		return []Platform{{Architecture: manifest.Architecture, OS: "linux"}}, nil
 	default:
-		return false, fmt.Errorf("unknown MediaType %s", mediaType)
+		return nil, fmt.Errorf("unknown MediaType %s", ct.mediaType)
+	}
+}
+
+// isAttestationManifest reports whether the descriptor refers to an attestation manifest by BuildKit.
+func isAttestationManifest(desc ocispec.Descriptor) bool {
+	return desc.Annotations["vnd.docker.reference.type"] == "attestation-manifest"
+}
+
+// HasPlatformImage returns an image tag for arch/os exists or not in the repository.
+// arch may contain a variant like "arm64/v8".
+func (c *Repository) HasPlatformImage(ctx context.Context, tag, arch, os string) (bool, error) {
+	platforms, err := c.Platforms(ctx, tag)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range platforms {
+		if p.Match(arch, os) {
+			return true, nil
+		}
 	}
 	// not found
 	return false, nil
@@ -258,30 +454,20 @@ func (c *Repository) HasPlatformImage(ctx context.Context, tag, arch, os string)
 
 // HasImage returns an image tag exists or not in the repository.
 func (c *Repository) HasImage(ctx context.Context, tag string) (bool, error) {
-	tries := 2
-	for tries > 0 {
-		tries--
-		resp, err := c.getAvailability(ctx, tag)
-		if err != nil {
-			return false, err
-		}
-		switch resp.StatusCode {
-		case http.StatusUnauthorized:
-			h := resp.Header.Get("Www-Authenticate")
-			if strings.HasPrefix(h, "Bearer ") {
-				auth := strings.SplitN(h, " ", 2)[1]
-				e, svc, scope := parseAuthHeader(auth)
-				if err := c.login(ctx, e, svc, scope); err != nil {
-					return false, err
-				}
-			}
-		case http.StatusOK:
-			return true, nil
-		default:
-			return false, fmt.Errorf(resp.Status)
-		}
+	u := fmt.Sprintf("https://%s/v2/%s/manifests/%s", c.host, c.repo, tag)
+	resp, err := c.do(ctx, http.MethodHead, u, manifestMediaTypes)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, fmt.Errorf("%s: %w", resp.Status, ErrNotFound)
+	default:
+		return false, fmt.Errorf(resp.Status)
 	}
-	return false, fmt.Errorf("aborted")
 }
 
 var (
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// DockerConfig represents credentials in a config file of docker CLI.
+type DockerConfig struct {
+	Auths       map[string]DockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+// DockerAuth represents an entry of auths in a config file of docker CLI.
+type DockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// DefaultDockerConfigPath returns the path of the config file of docker CLI.
+func DefaultDockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// LoadDockerConfig loads a config file of docker CLI.
+func LoadDockerConfig(path string) (*DockerConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c DockerConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// normalizeRegistryHost normalizes keys of auths like "https://ghcr.io/v2/" to hosts.
+func normalizeRegistryHost(s string) string {
+	if s == dockerHubAuthKey {
+		return dockerHubHost
+	}
+	s = strings.TrimPrefix(s, "https://")
+	s = strings.TrimPrefix(s, "http://")
+	s, _, _ = strings.Cut(s, "/")
+	switch s {
+	case "index.docker.io", "docker.io":
+		return dockerHubHost
+	}
+	return s
+}
+
+// Credentials returns a user and a password for the registry host.
+// It returns empty strings when no credentials are found.
+func (c *DockerConfig) Credentials(host string) (user, password string, err error) {
+	host = normalizeRegistryHost(host)
+	for key, helper := range c.CredHelpers {
+		if normalizeRegistryHost(key) == host {
+			return credentialsFromHelper(helper, key)
+		}
+	}
+	for key, auth := range c.Auths {
+		if normalizeRegistryHost(key) != host {
+			continue
+		}
+		if auth.Auth != "" {
+			b, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to decode auth of %s: %w", key, err)
+			}
+			user, password, ok := strings.Cut(string(b), ":")
+			if !ok {
+				return "", "", fmt.Errorf("invalid auth of %s", key)
+			}
+			return user, password, nil
+		}
+		if auth.Username != "" {
+			return auth.Username, auth.Password, nil
+		}
+	}
+	if c.CredsStore != "" {
+		serverURL := host
+		if host == dockerHubHost {
+			serverURL = dockerHubAuthKey
+		}
+		return credentialsFromHelper(c.CredsStore, serverURL)
+	}
+	return "", "", nil
+}
+
+// credentialsFromHelper gets credentials from a docker credential helper.
+// It returns empty strings when the helper has no credentials for the server.
+func credentialsFromHelper(helper, serverURL string) (user, password string, err error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(msg, "credentials not found") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to get credentials from docker-credential-%s: %s: %w", helper, msg, err)
+	}
+	var out struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return "", "", fmt.Errorf("failed to parse output of docker-credential-%s: %w", helper, err)
+	}
+	return out.Username, out.Secret, nil
+}
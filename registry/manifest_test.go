@@ -0,0 +1,288 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type testBlob struct {
+	mediaType string
+	body      []byte
+}
+
+func newTestBlob(mediaType string, v interface{}) testBlob {
+	b, _ := json.Marshal(v)
+	return testBlob{mediaType: mediaType, body: b}
+}
+
+func (b testBlob) digest() digest.Digest {
+	return digest.FromBytes(b.body)
+}
+
+// newManifestRegistry serves manifests and blobs of repository "org/app", counting requests by path.
+func newManifestRegistry(t *testing.T, manifests map[string]testBlob, blobs map[string]testBlob) (*httptest.Server, *sync.Map) {
+	var counts sync.Map
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := counts.LoadOrStore(r.Method+" "+r.URL.Path, new(int64))
+		atomic.AddInt64(n.(*int64), 1)
+		var b testBlob
+		var ok bool
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/org/app/manifests/"):
+			b, ok = manifests[strings.TrimPrefix(r.URL.Path, "/v2/org/app/manifests/")]
+		case strings.HasPrefix(r.URL.Path, "/v2/org/app/blobs/"):
+			b, ok = blobs[strings.TrimPrefix(r.URL.Path, "/v2/org/app/blobs/")]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if b.mediaType != "" {
+			w.Header().Set("Content-Type", b.mediaType)
+		}
+		w.Write(b.body)
+	}))
+	return srv, &counts
+}
+
+func requestCount(counts *sync.Map, key string) int64 {
+	n, ok := counts.Load(key)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(n.(*int64))
+}
+
+func TestPlatforms(t *testing.T) {
+	amd64Config := newTestBlob(ocispec.MediaTypeImageConfig, map[string]string{"architecture": "amd64", "os": "linux"})
+	armConfig := newTestBlob("application/vnd.docker.container.image.v1+json", map[string]string{"architecture": "arm", "os": "linux", "variant": "v6"})
+	amd64Manifest := newTestBlob(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Config: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: amd64Config.digest()},
+	})
+	armManifest := newTestBlob("application/vnd.docker.distribution.manifest.v2+json", map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.docker.distribution.manifest.v2+json",
+		"config":        map[string]string{"digest": armConfig.digest().String()},
+	})
+	index := newTestBlob(ocispec.MediaTypeImageIndex, ocispec.Index{
+		Manifests: []ocispec.Descriptor{
+			{Digest: amd64Manifest.digest(), Platform: &ocispec.Platform{Architecture: "amd64", OS: "linux"}},
+			{Digest: "sha256:0000", Platform: &ocispec.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}},
+			// attestation manifest by BuildKit
+			{
+				Digest:      "sha256:1111",
+				Platform:    &ocispec.Platform{Architecture: "unknown", OS: "unknown"},
+				Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+			},
+			// platform is resolved by the child manifest
+			{Digest: armManifest.digest()},
+		},
+	})
+	manifestList := newTestBlob("application/vnd.docker.distribution.manifest.list.v2+json", map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.docker.distribution.manifest.list.v2+json",
+		"manifests": []map[string]interface{}{
+			{"digest": "sha256:2222", "platform": map[string]string{"architecture": "arm64", "os": "linux"}},
+			{"digest": "sha256:3333", "platform": map[string]string{"architecture": "amd64", "os": "windows"}},
+		},
+	})
+	schema1 := newTestBlob("application/vnd.docker.distribution.manifest.v1+prettyjws", map[string]interface{}{
+		"schemaVersion": 1,
+		"name":          "org/app",
+		"tag":           "schema1",
+		"architecture":  "amd64",
+	})
+	// without Content-Type
+	untyped := testBlob{body: amd64Manifest.body}
+
+	manifests := map[string]testBlob{
+		"index":                         index,
+		"list":                          manifestList,
+		"manifest":                      amd64Manifest,
+		"schema1":                       schema1,
+		"untyped":                       untyped,
+		armManifest.digest().String():   armManifest,
+		amd64Manifest.digest().String(): amd64Manifest,
+	}
+	blobs := map[string]testBlob{
+		amd64Config.digest().String(): amd64Config,
+		armConfig.digest().String():   armConfig,
+	}
+	srv, _ := newManifestRegistry(t, manifests, blobs)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	testCases := []struct {
+		ref       string
+		platforms []registry.Platform
+	}{
+		{
+			ref: "index",
+			platforms: []registry.Platform{
+				{Architecture: "amd64", OS: "linux"},
+				{Architecture: "arm64", OS: "linux", Variant: "v8"},
+				{Architecture: "arm", OS: "linux", Variant: "v6"},
+			},
+		},
+		{
+			ref: "list",
+			platforms: []registry.Platform{
+				{Architecture: "arm64", OS: "linux"},
+				{Architecture: "amd64", OS: "windows"},
+			},
+		},
+		{ref: "manifest", platforms: []registry.Platform{{Architecture: "amd64", OS: "linux"}}},
+		{ref: "schema1", platforms: []registry.Platform{{Architecture: "amd64", OS: "linux"}}},
+		{ref: "untyped", platforms: []registry.Platform{{Architecture: "amd64", OS: "linux"}}},
+		{ref: amd64Manifest.digest().String(), platforms: []registry.Platform{{Architecture: "amd64", OS: "linux"}}},
+	}
+	repo := registry.New(host+"/org/app", "", "", registry.WithHTTPClient(srv.Client()))
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			platforms, err := repo.Platforms(context.Background(), tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.platforms, platforms); diff != "" {
+				t.Errorf("unexpected platforms %s", diff)
+			}
+		})
+	}
+
+	if _, err := repo.Platforms(context.Background(), "notfound"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("Platforms of notfound should fail with ErrNotFound: %v", err)
+	}
+	if _, err := repo.HasImage(context.Background(), "notfound"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("HasImage of notfound should fail with ErrNotFound: %v", err)
+	}
+}
+
+func TestPlatformsConcurrent(t *testing.T) {
+	config := newTestBlob(ocispec.MediaTypeImageConfig, map[string]string{"architecture": "amd64", "os": "linux"})
+	manifest := newTestBlob(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Config: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: config.digest()},
+	})
+	// without Content-Type, the media type is resolved by the body
+	srv, _ := newManifestRegistry(t,
+		map[string]testBlob{"untyped": {body: manifest.body}},
+		map[string]testBlob{config.digest().String(): config},
+	)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	cache := registry.NewCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo := registry.New(host+"/org/app", "", "", registry.WithHTTPClient(srv.Client()), registry.WithCache(cache))
+			platforms, err := repo.Platforms(context.Background(), "untyped")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if diff := cmp.Diff([]registry.Platform{{Architecture: "amd64", OS: "linux"}}, platforms); diff != "" {
+				t.Errorf("unexpected platforms %s", diff)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestResolveDigest(t *testing.T) {
+	manifest := newTestBlob(ocispec.MediaTypeImageManifest, ocispec.Manifest{})
+	srv, counts := newManifestRegistry(t, map[string]testBlob{"v1": manifest}, nil)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	cache := registry.NewCache()
+	ctx := context.Background()
+	want := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest.body))
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// repositories sharing the cache fetch the manifest only once
+			repo := registry.New(host+"/org/app", "", "", registry.WithHTTPClient(srv.Client()), registry.WithCache(cache))
+			d, err := repo.ResolveDigest(ctx, "v1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if d != want {
+				t.Errorf("unexpected digest %s, want %s", d, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requestCount(counts, "GET /v2/org/app/manifests/v1"); n != 1 {
+		t.Errorf("manifest should be fetched once, but fetched %d times", n)
+	}
+
+	// digest is returned as is
+	repo := registry.New(host+"/org/app", "", "", registry.WithHTTPClient(srv.Client()), registry.WithCache(cache))
+	if d, err := repo.ResolveDigest(ctx, want); err != nil || d != want {
+		t.Errorf("unexpected digest %s %v", d, err)
+	}
+}
+
+func TestPlatformMatch(t *testing.T) {
+	testCases := []struct {
+		platform registry.Platform
+		arch     string
+		os       string
+		match    bool
+	}{
+		{platform: registry.Platform{Architecture: "arm64", OS: "linux"}, arch: "arm64", os: "linux", match: true},
+		{platform: registry.Platform{Architecture: "arm64", OS: "linux"}, arch: "arm64/v8", os: "linux", match: true},
+		{platform: registry.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}, arch: "arm64", os: "linux", match: true},
+		{platform: registry.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"}, arch: "arm64/v8", os: "linux", match: true},
+		{platform: registry.Platform{Architecture: "arm", OS: "linux", Variant: "v6"}, arch: "arm/v7", os: "linux", match: false},
+		{platform: registry.Platform{Architecture: "arm", OS: "linux", Variant: "v6"}, arch: "arm", os: "linux", match: true},
+		{platform: registry.Platform{Architecture: "amd64", OS: "linux"}, arch: "arm64", os: "linux", match: false},
+		{platform: registry.Platform{Architecture: "amd64", OS: "windows"}, arch: "amd64", os: "linux", match: false},
+		{platform: registry.Platform{Architecture: "amd64", OS: "windows"}, arch: "", os: "windows", match: true},
+	}
+	for _, tc := range testCases {
+		if m := tc.platform.Match(tc.arch, tc.os); m != tc.match {
+			t.Errorf("%s match %s/%s: got %v, want %v", tc.platform, tc.os, tc.arch, m, tc.match)
+		}
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		image      string
+		repository string
+		reference  string
+	}{
+		{image: "debian", repository: "debian", reference: "latest"},
+		{image: "debian:bookworm", repository: "debian", reference: "bookworm"},
+		{image: "localhost:5000/org/app", repository: "localhost:5000/org/app", reference: "latest"},
+		{image: "localhost:5000/org/app:v1", repository: "localhost:5000/org/app", reference: "v1"},
+		{image: "ghcr.io/org/app@sha256:abcd", repository: "ghcr.io/org/app", reference: "sha256:abcd"},
+		{image: "ghcr.io/org/app:v1@sha256:abcd", repository: "ghcr.io/org/app", reference: "sha256:abcd"},
+	}
+	for _, tc := range testCases {
+		repo, ref := registry.ParseReference(tc.image)
+		if repo != tc.repository || ref != tc.reference {
+			t.Errorf("%s: unexpected %s %s", tc.image, repo, ref)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"strings"
+	"sync"
+)
+
+// Platform represents a platform of an image.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform like "linux/arm64/v8".
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// defaultVariants are variants which are regarded as the same as an empty variant.
+var defaultVariants = map[string]string{
+	"arm64": "v8",
+	"arm":   "v7",
+}
+
+func normalizeVariant(arch, variant string) string {
+	if variant == "" {
+		return defaultVariants[arch]
+	}
+	return variant
+}
+
+// Match reports whether the platform matches arch and os. Empty arch or os matches any.
+// arch may contain a variant like "arm64/v8".
+func (p Platform) Match(arch, os string) bool {
+	arch, variant, _ := strings.Cut(arch, "/")
+	if arch != "" && arch != p.Architecture {
+		return false
+	}
+	if os != "" && os != p.OS {
+		return false
+	}
+	if variant != "" && normalizeVariant(arch, variant) != normalizeVariant(p.Architecture, p.Variant) {
+		return false
+	}
+	return true
+}
+
+// Cache is a cache of manifests and image configs in a run.
+// It is safe for concurrent use, and concurrent fetches of the same content are done once.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once    sync.Once
+	content *content
+	err     error
+}
+
+// NewCache creates a new cache.
+func NewCache() *Cache {
+	return &Cache{entries: map[string]*cacheEntry{}}
+}
+
+func (c *Cache) get(key string, fetch func() (*content, error)) (*content, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+	e.once.Do(func() {
+		e.content, e.err = fetch()
+	})
+	return e.content, e.err
+}
@@ -18,6 +18,8 @@ type RollbackOption struct {
 	DeregisterTaskDefinition bool   `help:"deregister the rolled-back task definition. not works with --no-wait" default:"true" negatable:""`
 	Wait                     bool   `help:"wait for the service stable" default:"true" negatable:""`
 	RollbackEvents           string `help:"roll back when specified events happened (DEPLOYMENT_FAILURE,DEPLOYMENT_STOP_ON_ALARM,DEPLOYMENT_STOP_ON_REQUEST,...) CodeDeploy only." default:""`
+	Revision                 int64  `help:"revision number of the task definition to roll back to. default is the previously deployed revision" default:"0"`
+	WithService              bool   `help:"restore service attributes from the snapshot saved by deploy" default:"false"`
 }
 
 func (opt RollbackOption) DryRunString() string {
@@ -42,13 +44,32 @@ func (d *App) Rollback(ctx context.Context, opt RollbackOption) error {
 	}
 
 	currentArn := *sv.TaskDefinition
-	targetArn, err := d.FindRollbackTarget(ctx, currentArn)
+	targetArn, err := d.rollbackTarget(ctx, sv, opt)
 	if err != nil {
 		return err
 	}
 
 	d.Log("Rolling back to %s", arnToName(targetArn))
+	var snapshotSv *Service
+	if opt.WithService {
+		snapshotSv, err = d.loadServiceSnapshot(sv, targetArn)
+		if err != nil {
+			return err
+		}
+	}
 	if opt.DryRun {
+		if err := d.showRollbackDiff(ctx, currentArn, targetArn); err != nil {
+			return err
+		}
+		if snapshotSv != nil {
+			ds, err := diffServices(snapshotSv, sv, *sv.ServiceArn, "service snapshot", true)
+			if err != nil {
+				return err
+			}
+			if ds != "" {
+				fmt.Print(coloredDiff(ds))
+			}
+		}
 		if opt.DeregisterTaskDefinition {
 			d.Log("%s will be deregistered", arnToName(currentArn))
 		} else {
@@ -68,9 +89,18 @@ func (d *App) Rollback(ctx context.Context, opt RollbackOption) error {
 		return err
 	}
 
+	if snapshotSv != nil {
+		d.Log("Restoring service attributes from the snapshot")
+		if err := d.UpdateServiceAttributes(ctx, snapshotSv, targetArn, DeployOption{}); err != nil {
+			return err
+		}
+	}
 	if err := doRollback(ctx, sv, targetArn, opt); err != nil {
 		return err
 	}
+	if err := d.recordDeployHistory(ctx, sv.ServiceArn, targetArn, currentArn); err != nil {
+		d.Log("[WARNING] %s", err)
+	}
 
 	if !opt.Wait {
 		d.Log("Service is rolled back.")
@@ -163,6 +193,96 @@ func (d *App) RollbackByCodeDeploy(ctx context.Context, sv *Service, tdArn strin
 	}
 }
 
+func (d *App) rollbackTarget(ctx context.Context, sv *Service, opt RollbackOption) (string, error) {
+	currentArn := *sv.TaskDefinition
+	if opt.Revision > 0 {
+		family := strings.Split(arnToName(currentArn), ":")[0]
+		name := fmt.Sprintf("%s:%d", family, opt.Revision)
+		td, err := d.describeActiveTaskDefinition(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if aws.ToString(td.TaskDefinitionArn) == currentArn {
+			return "", fmt.Errorf("%s is already deployed to the service", name)
+		}
+		return *td.TaskDefinitionArn, nil
+	}
+
+	history := deployHistoryFromTags(sv.Tags)
+	if len(history) == 0 {
+		d.Log("[INFO] deploy history of the service is not found. Rolling back to the previous revision in the family")
+		return d.FindRollbackTarget(ctx, currentArn)
+	}
+	d.Log("[DEBUG] deploy history: %v", history)
+	currentName := arnToName(currentArn)
+	for _, name := range history {
+		if name == currentName {
+			continue
+		}
+		td, err := d.describeActiveTaskDefinition(ctx, name)
+		if err != nil {
+			d.Log("[DEBUG] %s is not available for rollback: %s", name, err)
+			continue
+		}
+		return *td.TaskDefinitionArn, nil
+	}
+	return "", ErrNotFound("rollback target is not found in the deploy history")
+}
+
+func (d *App) loadServiceSnapshot(sv *Service, targetArn string) (*Service, error) {
+	snapshot, err := decodeServiceSnapshot(sv.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service snapshot: %w", err)
+	}
+	if target := arnToName(targetArn); snapshot.TaskDefinition != target {
+		return nil, fmt.Errorf("service snapshot was saved with %s, but the rollback target is %s. run rollback without --with-service", snapshot.TaskDefinition, target)
+	}
+	snapshotSv, err := snapshot.ServiceDefinition()
+	if err != nil {
+		return nil, err
+	}
+	// keep attributes which are not saved in the snapshot
+	snapshotSv.ServiceName = sv.ServiceName
+	snapshotSv.ServiceArn = sv.ServiceArn
+	snapshotSv.DeploymentController = sv.DeploymentController
+	snapshotSv.SchedulingStrategy = sv.SchedulingStrategy
+	snapshotSv.LaunchType = sv.LaunchType
+	snapshotSv.Tags = sv.Tags
+	return snapshotSv, nil
+}
+
+func (d *App) describeActiveTaskDefinition(ctx context.Context, name string) (*TaskDefinition, error) {
+	out, err := d.ecs.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: &name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe task definition %s: %w", name, err)
+	}
+	if st := out.TaskDefinition.Status; st != types.TaskDefinitionStatusActive {
+		return nil, fmt.Errorf("task definition %s is %s", name, st)
+	}
+	return out.TaskDefinition, nil
+}
+
+func (d *App) showRollbackDiff(ctx context.Context, currentArn, targetArn string) error {
+	currentTd, err := d.DescribeTaskDefinition(ctx, currentArn)
+	if err != nil {
+		return err
+	}
+	targetTd, err := d.DescribeTaskDefinition(ctx, targetArn)
+	if err != nil {
+		return err
+	}
+	ds, err := diffTaskDefs(targetTd, currentTd, currentArn, targetArn, true, true)
+	if err != nil {
+		return err
+	}
+	if ds != "" {
+		fmt.Print(coloredDiff(ds))
+	}
+	return nil
+}
+
 func (d *App) FindRollbackTarget(ctx context.Context, taskDefinitionArn string) (string, error) {
 	var found bool
 	var nextToken *string
@@ -3,6 +3,7 @@ package ecspresso
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -18,14 +19,39 @@ type RunOption struct {
 	Wait                 bool   `help:"wait for task to complete" default:"true" negatable:""`
 	TaskOverrideStr      string `name:"overrides" help:"task override JSON string" default:""`
 	TaskOverrideFile     string `name:"overrides-file" help:"task override JSON file path" default:""`
+	TaskOverridesList    string `name:"overrides-list-file" help:"JSON file path of a list of task overrides. runs a task for each override" default:""`
 	SkipTaskDefinition   bool   `help:"skip register a new task definition" default:"false"`
-	Count                int32  `help:"number of tasks to run (max 10)" default:"1"`
+	Count                int32  `help:"number of tasks to run" default:"1"`
 	WatchContainer       string `help:"container name for watching exit code" default:""`
 	LatestTaskDefinition bool   `help:"use the latest task definition without registering a new task definition" default:"false"`
 	PropagateTags        string `help:"propagate the tags for the task (SERVICE or TASK_DEFINITION)" default:""`
 	Tags                 string `help:"tags for the task: format is KeyFoo=ValueFoo,KeyBar=ValueBar" default:""`
 	WaitUntil            string `help:"wait until invoked tasks status reached to (running or stopped)" default:"stopped" enum:"running,stopped"`
 	Revision             *int64 `help:"revision of the task definition to run when --skip-task-definition" default:"0"`
+	PropagateExitCode    bool   `help:"exit with the exit code of the watch container" default:"false"`
+
+	Command          string   `help:"override the command of the container. JSON array or words separated by spaces" default:""`
+	Env              []string `help:"override an environment variable of the container (KEY=VALUE). can be specified multiple times" sep:"none"`
+	EnvFile          string   `help:"env file to override environment variables of the container" default:""`
+	Cpu              string   `help:"override the cpu of the task (e.g. 1024, \"1 vCPU\")" default:""`
+	Memory           string   `help:"override the memory of the task (e.g. 2048, 2GB)" default:""`
+	Container        string   `help:"container name to override the command and environment variables. default is the watch container" default:""`
+	TaskRoleArn      string   `help:"override the task role ARN" default:""`
+	EphemeralStorage int32    `help:"override the ephemeral storage size of the task in GiB" default:"0"`
+
+	Subnets          []string `help:"subnets of awsvpc network configuration"`
+	SecurityGroups   []string `help:"security groups of awsvpc network configuration"`
+	AssignPublicIP   string   `name:"assign-public-ip" help:"assign public IP (ENABLED or DISABLED)" default:""`
+	LaunchType       string   `help:"launch type (EC2, FARGATE or EXTERNAL)" default:""`
+	CapacityProvider []string `help:"capacity provider strategy item: format is name[:weight[:base]]"`
+	PlatformVersion  string   `help:"platform version of Fargate" default:""`
+	StartedBy        string   `help:"an optional tag of the task (startedBy)" default:""`
+	Group            string   `help:"name of the task group" default:""`
+
+	Exec bool `help:"execute a command in the task by ECS Exec and stop the task when the session ends. --command (default sh) and --container specify the command to execute" default:"false"`
+
+	RetryMaxAttempts         int      `help:"max attempts of run on capacity and placement failures, including the first attempt" default:"0"`
+	FallbackCapacityProvider []string `help:"capacity provider strategy item to fall back to on retry: format is name[:weight[:base]]"`
 }
 
 func (opt RunOption) waitUntilRunning() bool {
@@ -40,55 +66,110 @@ func (opt RunOption) DryRunString() string {
 }
 
 func (d *App) Run(ctx context.Context, opt RunOption) error {
+	err := d.run(ctx, opt)
+	if opt.PropagateExitCode {
+		return propagateExitCode(err)
+	}
+	return err
+}
+
+func (d *App) run(ctx context.Context, opt RunOption) error {
+	execCtx := ctx // the session of exec is not limited by the timeout
 	ctx, cancel := d.Start(ctx)
 	defer cancel()
 
 	d.Log("Running task %s", opt.DryRunString())
-	ov := types.TaskOverride{}
-	if opt.TaskOverrideStr != "" {
-		if err := json.Unmarshal([]byte(opt.TaskOverrideStr), &ov); err != nil {
-			return fmt.Errorf("invalid overrides: %w", err)
-		}
-	} else if ovFile := opt.TaskOverrideFile; ovFile != "" {
-		src, err := d.readDefinitionFile(ovFile)
-		if err != nil {
-			return fmt.Errorf("failed to read overrides-file %s: %w", ovFile, err)
-		}
-		if err := unmarshalJSON(src, &ov, ovFile); err != nil {
-			return fmt.Errorf("failed to read overrides-file %s: %w", ovFile, err)
+	var eo *runExecOption
+	if opt.Exec {
+		var err error
+		if eo, opt, err = opt.execOption(); err != nil {
+			return err
 		}
 	}
-	d.Log("[DEBUG] Overrides")
-	d.LogJSON(ov)
+	ovs, err := d.taskOverridesForRun(opt)
+	if err != nil {
+		return err
+	}
+	flags, err := opt.overrideFlags()
+	if err != nil {
+		return err
+	}
 
 	tdArn, err := d.taskDefinitionArnForRun(ctx, opt)
 	if err != nil {
 		return err
 	}
 	d.Log("Task definition ARN: %s", tdArn)
+	td, err := d.taskDefinitionForRun(ctx, opt, tdArn)
+	if err != nil {
+		return err
+	}
+	watchContainer := containerOf(td, &opt.WatchContainer)
+	if watchContainer == nil {
+		return fmt.Errorf("watch container %s is not found in the task definition", opt.WatchContainer)
+	}
+	d.Log("Watch container: %s", *watchContainer.Name)
+	if eo != nil {
+		if eo.container == "" {
+			eo.container = *watchContainer.Name
+		} else if containerOf(td, &eo.container) == nil {
+			return fmt.Errorf("container %s to exec is not found in the task definition", eo.container)
+		}
+	}
+	for i := range ovs {
+		flags.apply(&ovs[i], *watchContainer.Name)
+		if err := validateTaskOverride(&ovs[i], td); err != nil {
+			return err
+		}
+	}
+	d.Log("[DEBUG] Overrides")
+	d.LogJSON(ovs)
 	if opt.DryRun {
 		d.Log("DRY RUN OK")
 		return nil
 	}
-	td, err := d.DescribeTaskDefinition(ctx, tdArn)
+
+	in, err := d.runTaskInput(ctx, tdArn, &opt)
 	if err != nil {
 		return err
 	}
-	watchContainer := containerOf(td, &opt.WatchContainer)
-	d.Log("Watch container: %s", *watchContainer.Name)
-
-	task, err := d.RunTask(ctx, tdArn, &ov, &opt)
+	if eo != nil {
+		in.EnableExecuteCommand = true
+	}
+	rc, err := opt.runRetryConfig(d.config.Run)
 	if err != nil {
 		return err
 	}
+	r := newRunRetry(rc)
 	if !opt.Wait {
+		if _, err := d.runTasks(ctx, in, ovs, opt.Count, r); err != nil {
+			return err
+		}
 		d.Log("Run task invoked")
 		return nil
 	}
-	if err := d.WaitRunTask(ctx, task, watchContainer, time.Now(), opt.waitUntilRunning()); err != nil {
-		return err
+	if len(ovs) > 1 || opt.Count > 1 {
+		if err := d.runAndWaitTasks(ctx, in, ovs, opt.Count, watchContainer, opt.waitUntilRunning(), r); err != nil {
+			return err
+		}
+		d.Log("Run task completed!")
+		return nil
+	}
+
+	untilRunning := opt.waitUntilRunning() || eo != nil
+	task, err := d.runAndWaitTask(ctx, in, &ovs[0], watchContainer, untilRunning, r)
+	if eo != nil {
+		if err != nil {
+			if task != nil {
+				if serr := d.stopRunTask(task, runExecStoppedReason); serr != nil {
+					d.Log("[WARNING] %s", serr)
+				}
+			}
+			return err
+		}
+		return d.runExec(execCtx, ctx, task, eo)
 	}
-	if err := d.DescribeTaskStatus(ctx, task, watchContainer); err != nil {
+	if err != nil {
 		return err
 	}
 	d.Log("Run task completed!")
@@ -96,10 +177,130 @@ func (d *App) Run(ctx context.Context, opt RunOption) error {
 	return nil
 }
 
+// runAndWaitTask runs a task and waits for it in each attempt of retry.
+// A task failed to start is run again, sharing the max attempts with failures of RunTask API.
+// It returns the last task run with an error.
+func (d *App) runAndWaitTask(ctx context.Context, in *ecs.RunTaskInput, ov *types.TaskOverride, watchContainer *types.ContainerDefinition, untilRunning bool, r *runRetry) (*types.Task, error) {
+	var task *types.Task
+	err := d.retryRun(ctx, r, func(_ int) error {
+		task = nil
+		in := *in
+		r.applyFallback(&in)
+		in.Overrides = ov
+		in.Count = aws.Int32(1)
+		ts, err := d.runTask(ctx, &in)
+		if err != nil {
+			return err
+		}
+		task = &ts[0]
+		return d.waitRunTaskStatus(ctx, task, watchContainer, untilRunning)
+	})
+	return task, err
+}
+
+// taskDefinitionForRun returns the task definition to run.
+// In dry run, the task definition to be registered is loaded from the file, because it is not registered yet.
+func (d *App) taskDefinitionForRun(ctx context.Context, opt RunOption, tdArn string) (*TaskDefinitionInput, error) {
+	registered := aws.ToInt64(opt.Revision) > 0 || opt.LatestTaskDefinition || opt.SkipTaskDefinition
+	if opt.DryRun && !registered {
+		tdPath := opt.TaskDefinition
+		if tdPath == "" {
+			tdPath = d.config.TaskDefinitionPath
+		}
+		return d.LoadTaskDefinition(tdPath)
+	}
+	return d.DescribeTaskDefinition(ctx, tdArn)
+}
+
+// waitRunTaskStatus waits for the task and returns the status of the task.
+func (d *App) waitRunTaskStatus(ctx context.Context, task *types.Task, watchContainer *types.ContainerDefinition, untilRunning bool) error {
+	if err := d.WaitRunTask(ctx, task, watchContainer, time.Now(), untilRunning); err != nil {
+		if untilRunning {
+			// the waiter fails when the task is stopped before running
+			var stopped *taskStoppedError
+			if serr := d.DescribeTaskStatus(ctx, task, watchContainer); errors.As(serr, &stopped) && stopped.failedToStart {
+				return serr
+			}
+		}
+		return err
+	}
+	return d.DescribeTaskStatus(ctx, task, watchContainer)
+}
+
+// taskOverridesForRun returns task overrides from options. It returns an override for each task when overrides-list-file is specified.
+func (d *App) taskOverridesForRun(opt RunOption) ([]types.TaskOverride, error) {
+	if opt.Count < 1 {
+		return nil, fmt.Errorf("count must be greater than 0: %d", opt.Count)
+	}
+	if listFile := opt.TaskOverridesList; listFile != "" {
+		if opt.TaskOverrideStr != "" || opt.TaskOverrideFile != "" {
+			return nil, ErrConflictOptions("overrides-list-file and overrides or overrides-file are exclusive")
+		}
+		if opt.Count != 1 {
+			return nil, ErrConflictOptions("overrides-list-file and count are exclusive")
+		}
+		src, err := d.readDefinitionFile(listFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read overrides-list-file %s: %w", listFile, err)
+		}
+		var ovs []types.TaskOverride
+		if err := unmarshalJSON(src, &ovs, listFile); err != nil {
+			return nil, fmt.Errorf("failed to read overrides-list-file %s: %w", listFile, err)
+		}
+		if len(ovs) == 0 {
+			return nil, fmt.Errorf("overrides-list-file %s has no overrides", listFile)
+		}
+		return ovs, nil
+	}
+
+	ov := types.TaskOverride{}
+	if opt.TaskOverrideStr != "" {
+		if err := json.Unmarshal([]byte(opt.TaskOverrideStr), &ov); err != nil {
+			return nil, fmt.Errorf("invalid overrides: %w", err)
+		}
+	} else if ovFile := opt.TaskOverrideFile; ovFile != "" {
+		src, err := d.readDefinitionFile(ovFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read overrides-file %s: %w", ovFile, err)
+		}
+		if err := unmarshalJSON(src, &ov, ovFile); err != nil {
+			return nil, fmt.Errorf("failed to read overrides-file %s: %w", ovFile, err)
+		}
+	}
+	return []types.TaskOverride{ov}, nil
+}
+
+// RunTask runs opt.Count tasks of the task definition with the override, and returns the first task.
 func (d *App) RunTask(ctx context.Context, tdArn string, ov *types.TaskOverride, opt *RunOption) (*types.Task, error) {
+	if ov == nil {
+		ov = &types.TaskOverride{}
+	}
+	tasks, err := d.RunTasks(ctx, tdArn, []types.TaskOverride{*ov}, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
+}
+
+// RunTasks runs tasks of the task definition. When ovs has one override, it runs opt.Count tasks with the override.
+// Otherwise, it runs a task for each override.
+func (d *App) RunTasks(ctx context.Context, tdArn string, ovs []types.TaskOverride, opt *RunOption) ([]types.Task, error) {
+	in, err := d.runTaskInput(ctx, tdArn, opt)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := opt.runRetryConfig(d.config.Run)
+	if err != nil {
+		return nil, err
+	}
+	return d.runTasks(ctx, in, ovs, opt.Count, newRunRetry(rc))
+}
+
+// runTaskInput returns the input of RunTask API built from the service definition, the run config and flags.
+func (d *App) runTaskInput(ctx context.Context, tdArn string, opt *RunOption) (*ecs.RunTaskInput, error) {
 	d.Log("Running task with %s", tdArn)
 
-	sv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+	flagsConfig, err := opt.runConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -110,23 +311,34 @@ func (d *App) RunTask(ctx context.Context, tdArn string, ov *types.TaskOverride,
 	}
 
 	in := &ecs.RunTaskInput{
-		Cluster:                  aws.String(d.Cluster),
-		TaskDefinition:           aws.String(tdArn),
-		NetworkConfiguration:     sv.NetworkConfiguration,
-		LaunchType:               sv.LaunchType,
-		Overrides:                ov,
-		Count:                    &opt.Count,
-		CapacityProviderStrategy: sv.CapacityProviderStrategy,
-		PlacementConstraints:     sv.PlacementConstraints,
-		PlacementStrategy:        sv.PlacementStrategy,
-		PlatformVersion:          sv.PlatformVersion,
-		Tags:                     tags,
-		EnableECSManagedTags:     sv.EnableECSManagedTags,
-		EnableExecuteCommand:     sv.EnableExecuteCommand,
+		Cluster:        aws.String(d.Cluster),
+		TaskDefinition: aws.String(tdArn),
+		Tags:           tags,
+	}
+	var sv *Service
+	if d.config.ServiceDefinitionPath != "" {
+		sv, err = d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+		if err != nil {
+			return nil, err
+		}
+		in.NetworkConfiguration = sv.NetworkConfiguration
+		in.LaunchType = sv.LaunchType
+		in.CapacityProviderStrategy = sv.CapacityProviderStrategy
+		in.PlacementConstraints = sv.PlacementConstraints
+		in.PlacementStrategy = sv.PlacementStrategy
+		in.PlatformVersion = sv.PlatformVersion
+		in.EnableECSManagedTags = sv.EnableECSManagedTags
+		in.EnableExecuteCommand = sv.EnableExecuteCommand
 	}
+	// run config and flags override the service definition in this order
+	d.config.Run.apply(in)
+	flagsConfig.apply(in)
 
 	switch opt.PropagateTags {
 	case "SERVICE":
+		if sv == nil {
+			return nil, fmt.Errorf("propagate-tags SERVICE requires service_definition")
+		}
 		out, err := d.ecs.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{
 			ResourceArn: sv.ServiceArn,
 		})
@@ -145,25 +357,76 @@ func (d *App) RunTask(ctx context.Context, tdArn string, ov *types.TaskOverride,
 	}
 	d.Log("[DEBUG] run task input")
 	d.LogJSON(in)
+	return in, nil
+}
 
+// runTasks runs tasks by RunTask API calls, retrying only the tasks which failed to run on retryable failures.
+// When some tasks failed to run, it stops the tasks already started not to leave them running without waiting.
+func (d *App) runTasks(ctx context.Context, in *ecs.RunTaskInput, ovs []types.TaskOverride, count int32, r *runRetry) ([]types.Task, error) {
+	reqs := runTaskRequests(ovs, count)
+	if err := d.runRequests(ctx, in, reqs, r); err != nil {
+		return nil, err
+	}
+	return tasksOfRequests(reqs), nil
+}
+
+// runRequests runs tasks of the requests and stores the started tasks and the attempts in each request.
+// The attempts of a request run again continue from the attempts already made.
+func (d *App) runRequests(ctx context.Context, in *ecs.RunTaskInput, reqs []runTaskRequest, r *runRetry) error {
+	if len(reqs) == 0 {
+		return fmt.Errorf("no tasks to run")
+	}
+	var tasks []types.Task
+	for i := range reqs {
+		req := &reqs[i]
+		req.tasks = nil
+		err := d.retryRunFrom(ctx, r, req.attempts+1, func(attempt int) error {
+			req.attempts = attempt
+			in := *in
+			r.applyFallback(&in)
+			in.Overrides = req.overrides
+			in.Count = aws.Int32(req.count - int32(len(req.tasks)))
+			ts, err := d.runTask(ctx, &in)
+			req.tasks = append(req.tasks, ts...)
+			return err
+		})
+		tasks = append(tasks, req.tasks...)
+		if err != nil {
+			if len(tasks) > 0 {
+				d.Log("[WARNING] stopping %d tasks already started", len(tasks))
+				for i := range tasks {
+					if serr := d.stopRunTask(&tasks[i], runTasksStoppedReason); serr != nil {
+						d.Log("[WARNING] %s", serr)
+					}
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// runTask calls RunTask API. It returns the started tasks with an error when some tasks failed to run.
+func (d *App) runTask(ctx context.Context, in *ecs.RunTaskInput) ([]types.Task, error) {
 	out, err := d.ecs.RunTask(ctx, in)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run task: %w", err)
 	}
+	for _, task := range out.Tasks {
+		d.Log("Task ARN: %s", aws.ToString(task.TaskArn))
+	}
 	if len(out.Failures) > 0 {
 		f := out.Failures[0]
 		if f.Arn != nil {
 			d.Log("Task ARN: %s", *f.Arn)
 		}
-		return nil, fmt.Errorf("failed to run task: %s %s", aws.ToString(f.Reason), aws.ToString(f.Detail))
+		return out.Tasks, &runTaskFailureError{failure: f}
 	}
 
 	if len(out.Tasks) == 0 {
 		return nil, fmt.Errorf("failed to run task: no tasks run")
 	}
-	task := out.Tasks[0]
-	d.Log("Task ARN: %s", aws.ToString(task.TaskArn))
-	return &task, nil
+	return out.Tasks, nil
 }
 
 func (d *App) WaitRunTask(ctx context.Context, task *types.Task, watchContainer *types.ContainerDefinition, startedAt time.Time, untilRunning bool) error {
@@ -0,0 +1,166 @@
+package ecspresso
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// ConfigRun represents a configuration of run.
+// It is used when no service definition exists, or overrides the attributes of the service definition.
+type ConfigRun struct {
+	Subnets                  []string                  `yaml:"subnets,omitempty" json:"subnets,omitempty"`
+	SecurityGroups           []string                  `yaml:"security_groups,omitempty" json:"security_groups,omitempty"`
+	AssignPublicIP           string                    `yaml:"assign_public_ip,omitempty" json:"assign_public_ip,omitempty"`
+	LaunchType               string                    `yaml:"launch_type,omitempty" json:"launch_type,omitempty"`
+	CapacityProviderStrategy []*ConfigCapacityProvider `yaml:"capacity_provider_strategy,omitempty" json:"capacity_provider_strategy,omitempty"`
+	PlatformVersion          string                    `yaml:"platform_version,omitempty" json:"platform_version,omitempty"`
+	StartedBy                string                    `yaml:"started_by,omitempty" json:"started_by,omitempty"`
+	Group                    string                    `yaml:"group,omitempty" json:"group,omitempty"`
+	Retry                    *ConfigRunRetry           `yaml:"retry,omitempty" json:"retry,omitempty"`
+}
+
+// ConfigCapacityProvider represents an item of a capacity provider strategy.
+type ConfigCapacityProvider struct {
+	CapacityProvider string `yaml:"capacity_provider" json:"capacity_provider"`
+	Weight           int32  `yaml:"weight,omitempty" json:"weight,omitempty"`
+	Base             int32  `yaml:"base,omitempty" json:"base,omitempty"`
+}
+
+func (c *ConfigRun) validate() error {
+	if c.LaunchType != "" && len(c.CapacityProviderStrategy) > 0 {
+		return fmt.Errorf("launch_type and capacity_provider_strategy are exclusive")
+	}
+	if c.LaunchType != "" && !isValidEnum(c.LaunchType, types.LaunchType("").Values()) {
+		return fmt.Errorf("invalid launch_type %s", c.LaunchType)
+	}
+	if c.AssignPublicIP != "" && !isValidEnum(c.AssignPublicIP, types.AssignPublicIp("").Values()) {
+		return fmt.Errorf("invalid assign_public_ip %s", c.AssignPublicIP)
+	}
+	for _, cp := range c.CapacityProviderStrategy {
+		if cp.CapacityProvider == "" {
+			return fmt.Errorf("capacity_provider is required for capacity_provider_strategy")
+		}
+	}
+	if c.Retry != nil {
+		if err := c.Retry.validate(); err != nil {
+			return fmt.Errorf("invalid retry: %w", err)
+		}
+	}
+	return nil
+}
+
+func isValidEnum[T ~string](s string, values []T) bool {
+	for _, v := range values {
+		if string(v) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// apply overrides the input of RunTask API by the configuration.
+func (c *ConfigRun) apply(in *ecs.RunTaskInput) {
+	if c == nil {
+		return
+	}
+	if len(c.Subnets) > 0 || len(c.SecurityGroups) > 0 || c.AssignPublicIP != "" {
+		vpc := &types.AwsVpcConfiguration{}
+		if nc := in.NetworkConfiguration; nc != nil && nc.AwsvpcConfiguration != nil {
+			v := *nc.AwsvpcConfiguration
+			vpc = &v
+		}
+		if len(c.Subnets) > 0 {
+			vpc.Subnets = c.Subnets
+		}
+		if len(c.SecurityGroups) > 0 {
+			vpc.SecurityGroups = c.SecurityGroups
+		}
+		if c.AssignPublicIP != "" {
+			vpc.AssignPublicIp = types.AssignPublicIp(c.AssignPublicIP)
+		}
+		in.NetworkConfiguration = &types.NetworkConfiguration{AwsvpcConfiguration: vpc}
+	}
+	// launch type and capacity provider strategy are exclusive
+	if c.LaunchType != "" {
+		in.LaunchType = types.LaunchType(c.LaunchType)
+		in.CapacityProviderStrategy = nil
+	}
+	if len(c.CapacityProviderStrategy) > 0 {
+		in.LaunchType = ""
+		in.CapacityProviderStrategy = capacityProviderStrategy(c.CapacityProviderStrategy)
+	}
+	if c.PlatformVersion != "" {
+		in.PlatformVersion = aws.String(c.PlatformVersion)
+	}
+	if c.StartedBy != "" {
+		in.StartedBy = aws.String(c.StartedBy)
+	}
+	if c.Group != "" {
+		in.Group = aws.String(c.Group)
+	}
+}
+
+func capacityProviderStrategy(cps []*ConfigCapacityProvider) []types.CapacityProviderStrategyItem {
+	if len(cps) == 0 {
+		return nil
+	}
+	s := make([]types.CapacityProviderStrategyItem, 0, len(cps))
+	for _, cp := range cps {
+		s = append(s, types.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(cp.CapacityProvider),
+			Weight:           cp.Weight,
+			Base:             cp.Base,
+		})
+	}
+	return s
+}
+
+// runConfig returns a configuration of run by flags.
+func (opt RunOption) runConfig() (*ConfigRun, error) {
+	c := &ConfigRun{
+		Subnets:         opt.Subnets,
+		SecurityGroups:  opt.SecurityGroups,
+		AssignPublicIP:  opt.AssignPublicIP,
+		LaunchType:      opt.LaunchType,
+		PlatformVersion: opt.PlatformVersion,
+		StartedBy:       opt.StartedBy,
+		Group:           opt.Group,
+	}
+	for _, s := range opt.CapacityProvider {
+		cp, err := parseCapacityProvider(s)
+		if err != nil {
+			return nil, err
+		}
+		c.CapacityProviderStrategy = append(c.CapacityProviderStrategy, cp)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid flags of run: %w", err)
+	}
+	return c, nil
+}
+
+// parseCapacityProvider parses a capacity provider strategy item formatted as name[:weight[:base]].
+func parseCapacityProvider(s string) (*ConfigCapacityProvider, error) {
+	p := strings.Split(s, ":")
+	if len(p) > 3 || p[0] == "" {
+		return nil, fmt.Errorf("invalid capacity provider %s. name[:weight[:base]] is required", s)
+	}
+	cp := &ConfigCapacityProvider{CapacityProvider: p[0]}
+	for i, v := range p[1:] {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capacity provider %s: %w", s, err)
+		}
+		if i == 0 {
+			cp.Weight = int32(n)
+		} else {
+			cp.Base = int32(n)
+		}
+	}
+	return cp, nil
+}
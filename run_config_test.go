@@ -0,0 +1,184 @@
+package ecspresso_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestLoadConfigRun(t *testing.T) {
+	ctx := context.Background()
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	conf, err := loader.Load(ctx, "tests/run-task-only.yaml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ecspresso.ConfigRun{
+		Subnets:        []string{"subnet-01234567", "subnet-89abcdef"},
+		SecurityGroups: []string{"sg-01234567"},
+		AssignPublicIP: "DISABLED",
+		CapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{
+			{CapacityProvider: "FARGATE_SPOT", Weight: 1},
+		},
+		PlatformVersion: "LATEST",
+		StartedBy:       "batch",
+		Retry: &ecspresso.ConfigRunRetry{
+			MaxAttempts: 5,
+			MinDelay:    &ecspresso.Duration{Duration: 5 * time.Second},
+			MaxDelay:    &ecspresso.Duration{Duration: 30 * time.Second},
+			FallbackCapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{
+				{CapacityProvider: "FARGATE"},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, conf.Run); diff != "" {
+		t.Errorf("unexpected run config %s", diff)
+	}
+}
+
+func TestConfigRunValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		conf  ecspresso.ConfigRun
+		isErr bool
+	}{
+		{name: "empty", conf: ecspresso.ConfigRun{}},
+		{name: "launch type", conf: ecspresso.ConfigRun{LaunchType: "FARGATE", AssignPublicIP: "ENABLED"}},
+		{name: "invalid launch type", conf: ecspresso.ConfigRun{LaunchType: "fargate"}, isErr: true},
+		{name: "invalid assign public ip", conf: ecspresso.ConfigRun{AssignPublicIP: "true"}, isErr: true},
+		{
+			name: "launch type and capacity provider strategy",
+			conf: ecspresso.ConfigRun{
+				LaunchType:               "FARGATE",
+				CapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{{CapacityProvider: "FARGATE"}},
+			},
+			isErr: true,
+		},
+		{
+			name:  "negative max attempts",
+			conf:  ecspresso.ConfigRun{Retry: &ecspresso.ConfigRunRetry{MaxAttempts: -1}},
+			isErr: true,
+		},
+		{
+			name: "fallback capacity provider without name",
+			conf: ecspresso.ConfigRun{Retry: &ecspresso.ConfigRunRetry{
+				FallbackCapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{{Weight: 1}},
+			}},
+			isErr: true,
+		},
+		{
+			name:  "capacity provider without name",
+			conf:  ecspresso.ConfigRun{CapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{{Weight: 1}}},
+			isErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		err := tc.conf.Validate()
+		if tc.isErr && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+		}
+	}
+}
+
+func TestParseCapacityProvider(t *testing.T) {
+	testCases := []struct {
+		src      string
+		expected *ecspresso.ConfigCapacityProvider
+	}{
+		{src: "FARGATE", expected: &ecspresso.ConfigCapacityProvider{CapacityProvider: "FARGATE"}},
+		{src: "FARGATE_SPOT:3", expected: &ecspresso.ConfigCapacityProvider{CapacityProvider: "FARGATE_SPOT", Weight: 3}},
+		{src: "FARGATE:1:2", expected: &ecspresso.ConfigCapacityProvider{CapacityProvider: "FARGATE", Weight: 1, Base: 2}},
+		{src: ""},
+		{src: "FARGATE:x"},
+		{src: "FARGATE:1:2:3"},
+	}
+	for _, tc := range testCases {
+		cp, err := ecspresso.ParseCapacityProvider(tc.src)
+		if tc.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.src, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.expected, cp); diff != "" {
+			t.Errorf("%s: unexpected %s", tc.src, diff)
+		}
+	}
+}
+
+func TestConfigRunApply(t *testing.T) {
+	// attributes from a service definition
+	in := &ecs.RunTaskInput{
+		NetworkConfiguration: &types.NetworkConfiguration{
+			AwsvpcConfiguration: &types.AwsVpcConfiguration{
+				Subnets:        []string{"subnet-service"},
+				SecurityGroups: []string{"sg-service"},
+				AssignPublicIp: types.AssignPublicIpDisabled,
+			},
+		},
+		LaunchType:      types.LaunchTypeFargate,
+		PlatformVersion: aws.String("1.4.0"),
+	}
+	conf := &ecspresso.ConfigRun{
+		SecurityGroups: []string{"sg-run"},
+		CapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{
+			{CapacityProvider: "FARGATE_SPOT", Weight: 1},
+		},
+		StartedBy: "batch",
+	}
+	conf.Apply(in)
+	flags, err := ecspresso.RunOption{
+		AssignPublicIP:  "ENABLED",
+		PlatformVersion: "LATEST",
+		Group:           "family:batch",
+	}.RunConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags.Apply(in)
+
+	expected := &ecs.RunTaskInput{
+		NetworkConfiguration: &types.NetworkConfiguration{
+			AwsvpcConfiguration: &types.AwsVpcConfiguration{
+				Subnets:        []string{"subnet-service"},
+				SecurityGroups: []string{"sg-run"},
+				AssignPublicIp: types.AssignPublicIpEnabled,
+			},
+		},
+		CapacityProviderStrategy: []types.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		},
+		PlatformVersion: aws.String("LATEST"),
+		StartedBy:       aws.String("batch"),
+		Group:           aws.String("family:batch"),
+	}
+	opt := cmpopts.IgnoreUnexported(
+		ecs.RunTaskInput{}, types.NetworkConfiguration{}, types.AwsVpcConfiguration{}, types.CapacityProviderStrategyItem{},
+	)
+	if diff := cmp.Diff(expected, in, opt); diff != "" {
+		t.Errorf("unexpected input %s", diff)
+	}
+
+	// launch type by flags clears capacity provider strategy
+	flags, err = ecspresso.RunOption{LaunchType: "EC2"}.RunConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags.Apply(in)
+	if in.LaunchType != types.LaunchTypeEc2 || in.CapacityProviderStrategy != nil {
+		t.Errorf("unexpected launch type %s and capacity provider strategy %v", in.LaunchType, in.CapacityProviderStrategy)
+	}
+}
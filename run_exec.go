@@ -0,0 +1,128 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/fujiwara/ecsta"
+)
+
+const (
+	defaultRunExecCommand       = "sh"
+	executeCommandAgentRunning  = "RUNNING"
+	executeCommandAgentStopped  = "STOPPED"
+	executeCommandAgentInterval = 3 * time.Second
+	runExecStoppedReason        = "stopped by ecspresso run --exec"
+)
+
+// runExecOption is a command and a container to execute by run --exec.
+type runExecOption struct {
+	command   string
+	container string
+}
+
+// execOption returns an option of run --exec, and opt without command and container,
+// because --command and --container specify the command to execute instead of task overrides.
+func (opt RunOption) execOption() (*runExecOption, RunOption, error) {
+	switch {
+	case opt.Count != 1:
+		return nil, opt, ErrConflictOptions("exec and count are exclusive")
+	case opt.TaskOverridesList != "":
+		return nil, opt, ErrConflictOptions("exec and overrides-list-file are exclusive")
+	case !opt.Wait:
+		return nil, opt, ErrConflictOptions("exec and no-wait are exclusive")
+	case opt.PropagateExitCode:
+		return nil, opt, ErrConflictOptions("exec and propagate-exit-code are exclusive")
+	}
+	eo := &runExecOption{command: opt.Command, container: opt.Container}
+	if eo.command == "" {
+		eo.command = defaultRunExecCommand
+	}
+	opt.Command = ""
+	opt.Container = ""
+	return eo, opt, nil
+}
+
+// runExec waits until ExecuteCommandAgent of the container is running, and executes the command in the task by ECS Exec.
+// The task is stopped when the session ends.
+// ctx for the session is not limited by the timeout, like exec.
+func (d *App) runExec(ctx context.Context, waitCtx context.Context, task *types.Task, eo *runExecOption) (err error) {
+	id := arnToName(aws.ToString(task.TaskArn))
+	defer func() {
+		if serr := d.stopRunTask(task, runExecStoppedReason); serr != nil {
+			d.Log("[WARNING] %s", serr)
+			if err == nil {
+				err = serr
+			}
+		}
+	}()
+
+	if err := d.waitExecuteCommandAgent(waitCtx, task, eo.container); err != nil {
+		return err
+	}
+	ecstaApp, err := d.NewEcsta(ctx)
+	if err != nil {
+		return err
+	}
+	d.Log("Executing %s in container %s of task ID %s", eo.command, eo.container, id)
+	return ecstaApp.RunExec(ctx, &ecsta.ExecOption{
+		ID:        id,
+		Command:   eo.command,
+		Container: eo.container,
+	})
+}
+
+// waitExecuteCommandAgent waits until ExecuteCommandAgent of the container in the task is running.
+func (d *App) waitExecuteCommandAgent(ctx context.Context, task *types.Task, container string) error {
+	d.Log("Waiting for ExecuteCommandAgent of container %s until running", container)
+	ticker := time.NewTicker(executeCommandAgentInterval)
+	defer ticker.Stop()
+	for {
+		out, err := d.ecs.DescribeTasks(ctx, d.DescribeTasksInput(task))
+		if err != nil {
+			return fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		if len(out.Tasks) == 0 {
+			return fmt.Errorf("task %s is not found", arnToName(aws.ToString(task.TaskArn)))
+		}
+		ts := &out.Tasks[0]
+		if status := aws.ToString(ts.LastStatus); status == "STOPPED" || status == "DEPROVISIONING" {
+			return fmt.Errorf("task is %s: %s", status, aws.ToString(ts.StoppedReason))
+		}
+		switch status := executeCommandAgentStatus(ts, container); status {
+		case executeCommandAgentRunning:
+			d.Log("ExecuteCommandAgent is running")
+			return nil
+		case executeCommandAgentStopped:
+			return fmt.Errorf("ExecuteCommandAgent of container %s is stopped. See also https://github.com/aws-containers/amazon-ecs-exec-checker", container)
+		case "":
+			d.Log("[DEBUG] ExecuteCommandAgent of container %s is not found yet", container)
+		default:
+			d.Log("[DEBUG] ExecuteCommandAgent of container %s is %s", container, status)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for ExecuteCommandAgent: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// executeCommandAgentStatus returns the last status of ExecuteCommandAgent of the container in the task.
+// It returns an empty string when the agent is not found.
+func executeCommandAgentStatus(task *types.Task, container string) string {
+	for _, c := range task.Containers {
+		if aws.ToString(c.Name) != container {
+			continue
+		}
+		for _, a := range c.ManagedAgents {
+			if a.Name == types.ManagedAgentNameExecuteCommandAgent {
+				return aws.ToString(a.LastStatus)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestRunExecOption(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opt       ecspresso.RunOption
+		command   string
+		container string
+		isErr     bool
+	}{
+		{
+			name:    "default",
+			opt:     ecspresso.RunOption{Exec: true, Count: 1, Wait: true},
+			command: "sh",
+		},
+		{
+			name:      "command and container",
+			opt:       ecspresso.RunOption{Exec: true, Count: 1, Wait: true, Command: "bash -l", Container: "app", Env: []string{"FOO=bar"}},
+			command:   "bash -l",
+			container: "app",
+		},
+		{name: "count", opt: ecspresso.RunOption{Exec: true, Count: 2, Wait: true}, isErr: true},
+		{name: "no wait", opt: ecspresso.RunOption{Exec: true, Count: 1}, isErr: true},
+		{name: "overrides list", opt: ecspresso.RunOption{Exec: true, Count: 1, Wait: true, TaskOverridesList: "list.json"}, isErr: true},
+		{name: "propagate exit code", opt: ecspresso.RunOption{Exec: true, Count: 1, Wait: true, PropagateExitCode: true}, isErr: true},
+	}
+	for _, tc := range testCases {
+		command, container, opt, err := tc.opt.ExecOption()
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+			continue
+		}
+		if command != tc.command || container != tc.container {
+			t.Errorf("%s: unexpected command %q and container %q", tc.name, command, container)
+		}
+		// command and container are not task overrides
+		if opt.Command != "" || opt.Container != "" {
+			t.Errorf("%s: command and container must be cleared: %q %q", tc.name, opt.Command, opt.Container)
+		}
+		if len(opt.Env) != len(tc.opt.Env) {
+			t.Errorf("%s: other overrides must be kept: %v", tc.name, opt.Env)
+		}
+	}
+}
+
+func TestExecuteCommandAgentStatus(t *testing.T) {
+	task := &types.Task{
+		Containers: []types.Container{
+			{
+				Name: aws.String("app"),
+				ManagedAgents: []types.ManagedAgent{
+					{Name: types.ManagedAgentNameExecuteCommandAgent, LastStatus: aws.String("RUNNING")},
+				},
+			},
+			{
+				Name: aws.String("sidecar"),
+				ManagedAgents: []types.ManagedAgent{
+					{Name: types.ManagedAgentNameExecuteCommandAgent, LastStatus: aws.String("PENDING")},
+				},
+			},
+			{Name: aws.String("init")},
+		},
+	}
+	testCases := []struct {
+		container string
+		expected  string
+	}{
+		{container: "app", expected: "RUNNING"},
+		{container: "sidecar", expected: "PENDING"},
+		{container: "init", expected: ""},
+		{container: "unknown", expected: ""},
+	}
+	for _, tc := range testCases {
+		if got := ecspresso.ExecuteCommandAgentStatus(task, tc.container); got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.container, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Exit codes of run --propagate-exit-code for failures of the infrastructure.
+// These are reserved to distinguish from exit codes of the watch container, like timeout(1) and docker run.
+// The watch container exiting with one of them can't be distinguished from the failures.
+const (
+	ExitCodeRunError           = 123 // other errors, like failures of API calls
+	ExitCodeRunTimeout         = 124
+	ExitCodeRunTaskFailed      = 125
+	ExitCodeRunPlacementFailed = 126
+)
+
+// taskStoppedError represents a task which failed to start or whose watch container exited abnormally.
+type taskStoppedError struct {
+	failedToStart bool
+	container     string
+	exitCode      *int32
+	reason        string
+}
+
+func (e *taskStoppedError) Error() string {
+	if e.failedToStart {
+		return "task failed to start: " + e.reason
+	}
+	if e.exitCode != nil && *e.exitCode != 0 {
+		msg := fmt.Sprintf("container: %s, exit code: %s", e.container, strconv.FormatInt(int64(*e.exitCode), 10))
+		if e.reason != "" {
+			msg += ", reason: " + e.reason
+		}
+		return msg
+	}
+	return fmt.Sprintf("container: %s, reason: %s", e.container, e.reason)
+}
+
+func (e *taskStoppedError) exitStatus() int {
+	if !e.failedToStart && e.exitCode != nil && *e.exitCode != 0 {
+		return int(*e.exitCode)
+	}
+	return ExitCodeRunTaskFailed
+}
+
+// runTaskFailureError represents a failure of RunTask API, like insufficient capacity or placement constraints.
+type runTaskFailureError struct {
+	failure types.Failure
+}
+
+func (e *runTaskFailureError) Error() string {
+	return fmt.Sprintf("failed to run task: %s %s", aws.ToString(e.failure.Reason), aws.ToString(e.failure.Detail))
+}
+
+// runExitCode returns an exit code of the process for the error of run.
+func runExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var stopped *taskStoppedError
+	var failure *runTaskFailureError
+	switch {
+	case errors.As(err, &stopped):
+		return stopped.exitStatus()
+	case errors.As(err, &failure):
+		return ExitCodeRunPlacementFailed
+	case isWaitTimeout(err):
+		return ExitCodeRunTimeout
+	default:
+		return ExitCodeRunError
+	}
+}
+
+// isWaitTimeout reports whether the error is caused by a timeout of waiters or the context.
+func isWaitTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "exceeded max wait time")
+}
+
+// propagateExitCode converts the error of run to ExitError with the exit code of the watch container or the reserved code.
+func propagateExitCode(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: runExitCode(err), Err: err}
+}
+
+// exitError returns an error of the results which determines the exit code.
+// A task failed to start takes precedence, otherwise the first task which exited abnormally.
+func (rs runTaskResults) exitError() error {
+	var first error
+	for _, r := range rs {
+		if r.Err == nil {
+			continue
+		}
+		if runExitCode(r.Err) == ExitCodeRunTaskFailed {
+			return r.Err
+		}
+		if first == nil {
+			first = r.Err
+		}
+	}
+	return first
+}
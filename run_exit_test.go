@@ -0,0 +1,100 @@
+package ecspresso_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestPropagateExitCode(t *testing.T) {
+	watch := &types.ContainerDefinition{Name: aws.String("app")}
+	taskErr := func(task types.Task) error {
+		_, err := ecspresso.TaskStatus(&task, watch)
+		return err
+	}
+	testCases := []struct {
+		name string
+		err  error
+		code int
+		msg  string
+	}{
+		{
+			name: "exit code of the watch container",
+			err: taskErr(types.Task{Containers: []types.Container{
+				{Name: aws.String("sidecar"), ExitCode: aws.Int32(137)},
+				{Name: aws.String("app"), ExitCode: aws.Int32(3)},
+			}}),
+			code: 3,
+			msg:  "container: app, exit code: 3",
+		},
+		{
+			name: "wrapped",
+			err: fmt.Errorf("2 of 5 tasks failed: %w", taskErr(types.Task{Containers: []types.Container{
+				{Name: aws.String("app"), ExitCode: aws.Int32(42), Reason: aws.String("OutOfMemoryError")},
+			}})),
+			code: 42,
+			msg:  "2 of 5 tasks failed: container: app, exit code: 42, reason: OutOfMemoryError",
+		},
+		{
+			name: "task failed to start",
+			err: taskErr(types.Task{
+				StopCode:      types.TaskStopCodeTaskFailedToStart,
+				StoppedReason: aws.String("CannotPullContainerError"),
+			}),
+			code: ecspresso.ExitCodeRunTaskFailed,
+			msg:  "task failed to start: CannotPullContainerError",
+		},
+		{
+			name: "container without exit code",
+			err: taskErr(types.Task{Containers: []types.Container{
+				{Name: aws.String("app"), Reason: aws.String("CannotStartContainerError")},
+			}}),
+			code: ecspresso.ExitCodeRunTaskFailed,
+			msg:  "container: app, reason: CannotStartContainerError",
+		},
+		{
+			name: "placement failure",
+			err:  ecspresso.NewRunTaskFailureError("RESOURCE:MEMORY"),
+			code: ecspresso.ExitCodeRunPlacementFailed,
+			msg:  "failed to run task: RESOURCE:MEMORY ",
+		},
+		{
+			name: "context timeout",
+			err:  fmt.Errorf("failed to wait task: %w", context.DeadlineExceeded),
+			code: ecspresso.ExitCodeRunTimeout,
+		},
+		{
+			name: "waiter timeout",
+			err:  fmt.Errorf("failed to wait task: %w", errors.New("exceeded max wait time for TasksStopped waiter")),
+			code: ecspresso.ExitCodeRunTimeout,
+		},
+		{
+			name: "other errors",
+			err:  errors.New("failed to describe tasks"),
+			code: ecspresso.ExitCodeRunError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ecspresso.PropagateExitCode(tc.err)
+			var exitErr *ecspresso.ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if exitErr.Code != tc.code {
+				t.Errorf("unexpected exit code %d, want %d", exitErr.Code, tc.code)
+			}
+			if tc.msg != "" && exitErr.Error() != tc.msg {
+				t.Errorf("unexpected message %q, want %q", exitErr.Error(), tc.msg)
+			}
+		})
+	}
+	if err := ecspresso.PropagateExitCode(nil); err != nil {
+		t.Errorf("nil should be propagated as nil: %v", err)
+	}
+}
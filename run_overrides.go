@@ -0,0 +1,237 @@
+package ecspresso
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/go-envparse"
+)
+
+const (
+	minEphemeralStorageGiB = 21
+	maxEphemeralStorageGiB = 200
+)
+
+// runOverrideFlags are task overrides built by flags of run.
+type runOverrideFlags struct {
+	container        string
+	command          []string
+	environment      []types.KeyValuePair
+	cpu              *string
+	memory           *string
+	taskRoleArn      *string
+	ephemeralStorage *types.EphemeralStorage
+}
+
+// overrideFlags parses flags of run for task overrides.
+func (opt RunOption) overrideFlags() (*runOverrideFlags, error) {
+	f := &runOverrideFlags{container: opt.Container}
+	if opt.Command != "" {
+		cmd, err := parseCommand(opt.Command)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command: %w", err)
+		}
+		f.command = cmd
+	}
+	env, err := opt.overrideEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	f.environment = env
+	if opt.Cpu != "" {
+		cpu := toNumberCPU(opt.Cpu)
+		if cpu == nil {
+			return nil, fmt.Errorf("invalid cpu: %s", opt.Cpu)
+		}
+		if _, err := strconv.Atoi(*cpu); err != nil {
+			return nil, fmt.Errorf("invalid cpu: %s", opt.Cpu)
+		}
+		f.cpu = cpu
+	}
+	if opt.Memory != "" {
+		memory := toNumberMemory(opt.Memory)
+		if memory == nil {
+			return nil, fmt.Errorf("invalid memory: %s", opt.Memory)
+		}
+		if _, err := strconv.Atoi(*memory); err != nil {
+			return nil, fmt.Errorf("invalid memory: %s", opt.Memory)
+		}
+		f.memory = memory
+	}
+	if opt.TaskRoleArn != "" {
+		f.taskRoleArn = aws.String(opt.TaskRoleArn)
+	}
+	if size := opt.EphemeralStorage; size != 0 {
+		if size < minEphemeralStorageGiB || size > maxEphemeralStorageGiB {
+			return nil, fmt.Errorf("ephemeral-storage must be between %d and %d GiB", minEphemeralStorageGiB, maxEphemeralStorageGiB)
+		}
+		f.ephemeralStorage = &types.EphemeralStorage{SizeInGiB: size}
+	}
+	return f, nil
+}
+
+// overrideEnvironment returns environment variables from env-file and env. Values of env take precedence.
+func (opt RunOption) overrideEnvironment() ([]types.KeyValuePair, error) {
+	envs := map[string]string{}
+	if opt.EnvFile != "" {
+		f, err := os.Open(opt.EnvFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open env-file %s: %w", opt.EnvFile, err)
+		}
+		defer f.Close()
+		fileEnvs, err := envparse.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse env-file %s: %w", opt.EnvFile, err)
+		}
+		for k, v := range fileEnvs {
+			envs[k] = v
+		}
+	}
+	for _, e := range opt.Env {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid env format. KEY=VALUE is required: %s", e)
+		}
+		envs[k] = v
+	}
+	if len(envs) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(envs))
+	for k := range envs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]types.KeyValuePair, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, types.KeyValuePair{Name: aws.String(k), Value: aws.String(envs[k])})
+	}
+	return kvs, nil
+}
+
+// apply merges the overrides by flags into ov. Values of flags take precedence.
+// Command and environment are applied to the container of the flag or defaultContainer.
+func (f *runOverrideFlags) apply(ov *types.TaskOverride, defaultContainer string) {
+	if f.cpu != nil {
+		ov.Cpu = f.cpu
+	}
+	if f.memory != nil {
+		ov.Memory = f.memory
+	}
+	if f.taskRoleArn != nil {
+		ov.TaskRoleArn = f.taskRoleArn
+	}
+	if f.ephemeralStorage != nil {
+		ov.EphemeralStorage = f.ephemeralStorage
+	}
+	if len(f.command) == 0 && len(f.environment) == 0 {
+		return
+	}
+	name := f.container
+	if name == "" {
+		name = defaultContainer
+	}
+	co := containerOverrideOf(ov, name)
+	if len(f.command) > 0 {
+		co.Command = f.command
+	}
+	for _, kv := range f.environment {
+		setEnvironment(co, kv)
+	}
+}
+
+// containerOverrideOf returns the container override of the name in ov, adding it when not found.
+func containerOverrideOf(ov *types.TaskOverride, name string) *types.ContainerOverride {
+	for i := range ov.ContainerOverrides {
+		if aws.ToString(ov.ContainerOverrides[i].Name) == name {
+			return &ov.ContainerOverrides[i]
+		}
+	}
+	ov.ContainerOverrides = append(ov.ContainerOverrides, types.ContainerOverride{Name: aws.String(name)})
+	return &ov.ContainerOverrides[len(ov.ContainerOverrides)-1]
+}
+
+func setEnvironment(co *types.ContainerOverride, kv types.KeyValuePair) {
+	for i := range co.Environment {
+		if aws.ToString(co.Environment[i].Name) == aws.ToString(kv.Name) {
+			co.Environment[i].Value = kv.Value
+			return
+		}
+	}
+	co.Environment = append(co.Environment, kv)
+}
+
+// validateTaskOverride validates that containers in the override exist in the task definition.
+func validateTaskOverride(ov *types.TaskOverride, td *TaskDefinitionInput) error {
+	for _, co := range ov.ContainerOverrides {
+		name := aws.ToString(co.Name)
+		if containerOf(td, &name) == nil {
+			return fmt.Errorf("container %s in overrides is not found in the task definition %s", name, aws.ToString(td.Family))
+		}
+	}
+	return nil
+}
+
+// parseCommand parses a command as a JSON array or words separated by spaces.
+// Words can be quoted by single or double quotes, and characters can be escaped by backslash.
+func parseCommand(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		var cmd []string
+		if err := json.Unmarshal([]byte(s), &cmd); err != nil {
+			return nil, err
+		}
+		return cmd, nil
+	}
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("unterminated escape")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
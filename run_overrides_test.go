@@ -0,0 +1,216 @@
+package ecspresso_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParseCommand(t *testing.T) {
+	testCases := []struct {
+		src     string
+		command []string
+		isErr   bool
+	}{
+		{src: "echo hello", command: []string{"echo", "hello"}},
+		{src: `  sh -c "echo 'hello world'"  `, command: []string{"sh", "-c", "echo 'hello world'"}},
+		{src: `echo hello\ world 'a\b' "c\"d"`, command: []string{"echo", "hello world", `a\b`, `c"d`}},
+		{src: `echo ""`, command: []string{"echo", ""}},
+		{src: `["sh", "-c", "echo $FOO"]`, command: []string{"sh", "-c", "echo $FOO"}},
+		{src: `echo "hello`, isErr: true},
+		{src: `echo hello\`, isErr: true},
+		{src: `["sh", 1]`, isErr: true},
+	}
+	for _, tc := range testCases {
+		command, err := ecspresso.ParseCommand(tc.src)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %q", tc.src, command)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.src, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.command, command); diff != "" {
+			t.Errorf("%s: unexpected command %s", tc.src, diff)
+		}
+	}
+}
+
+func TestApplyOverrideFlags(t *testing.T) {
+	base := func() *types.TaskOverride {
+		return &types.TaskOverride{
+			Cpu: aws.String("256"),
+			ContainerOverrides: []types.ContainerOverride{
+				{
+					Name:    aws.String("app"),
+					Command: []string{"original"},
+					Environment: []types.KeyValuePair{
+						{Name: aws.String("FOO"), Value: aws.String("original")},
+						{Name: aws.String("QUX"), Value: aws.String("qux")},
+					},
+				},
+			},
+		}
+	}
+	testCases := []struct {
+		name     string
+		opt      ecspresso.RunOption
+		expected *types.TaskOverride
+		isErr    bool
+	}{
+		{
+			name:     "no flags",
+			expected: base(),
+		},
+		{
+			name: "merge into the default container",
+			opt: ecspresso.RunOption{
+				Command:          "echo hello",
+				Env:              []string{"FOO=foo", "BAR=a=b"},
+				EnvFile:          "tests/run.env",
+				Cpu:              "1 vCPU",
+				Memory:           "2GB",
+				TaskRoleArn:      "arn:aws:iam::123456789012:role/app",
+				EphemeralStorage: 30,
+			},
+			expected: &types.TaskOverride{
+				Cpu:              aws.String("1024"),
+				Memory:           aws.String("2048"),
+				TaskRoleArn:      aws.String("arn:aws:iam::123456789012:role/app"),
+				EphemeralStorage: &types.EphemeralStorage{SizeInGiB: 30},
+				ContainerOverrides: []types.ContainerOverride{
+					{
+						Name:    aws.String("app"),
+						Command: []string{"echo", "hello"},
+						Environment: []types.KeyValuePair{
+							{Name: aws.String("FOO"), Value: aws.String("foo")},
+							{Name: aws.String("QUX"), Value: aws.String("qux")},
+							{Name: aws.String("BAR"), Value: aws.String("a=b")},
+							{Name: aws.String("BAZ"), Value: aws.String("baz")},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "add another container",
+			opt:  ecspresso.RunOption{Container: "worker", Env: []string{"FOO=foo"}},
+			expected: func() *types.TaskOverride {
+				ov := base()
+				ov.ContainerOverrides = append(ov.ContainerOverrides, types.ContainerOverride{
+					Name:        aws.String("worker"),
+					Environment: []types.KeyValuePair{{Name: aws.String("FOO"), Value: aws.String("foo")}},
+				})
+				return ov
+			}(),
+		},
+		{name: "invalid env", opt: ecspresso.RunOption{Env: []string{"FOO"}}, isErr: true},
+		{name: "invalid cpu", opt: ecspresso.RunOption{Cpu: "large"}, isErr: true},
+		{name: "invalid memory", opt: ecspresso.RunOption{Memory: "xGB"}, isErr: true},
+		{name: "too small ephemeral storage", opt: ecspresso.RunOption{EphemeralStorage: 20}, isErr: true},
+		{name: "env-file not found", opt: ecspresso.RunOption{EnvFile: "tests/notfound.env"}, isErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ov := base()
+			err := ecspresso.ApplyOverrideFlags(tc.opt, ov, "app")
+			if tc.isErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expected, ov, cmpopts.IgnoreUnexported(types.TaskOverride{}, types.ContainerOverride{}, types.KeyValuePair{}, types.EphemeralStorage{})); diff != "" {
+				t.Errorf("unexpected overrides %s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateTaskOverride(t *testing.T) {
+	td := &ecspresso.TaskDefinitionInput{
+		Family: aws.String("test"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("app")},
+			{Name: aws.String("sidecar")},
+		},
+	}
+	ok := &types.TaskOverride{ContainerOverrides: []types.ContainerOverride{{Name: aws.String("sidecar")}}}
+	if err := ecspresso.ValidateTaskOverride(ok, td); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	ng := &types.TaskOverride{ContainerOverrides: []types.ContainerOverride{{Name: aws.String("worker")}}}
+	if err := ecspresso.ValidateTaskOverride(ng, td); err == nil {
+		t.Error("expected error for unknown container")
+	}
+}
+
+func TestTaskOverridesFileJsonnet(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{
+		ConfigFilePath: "tests/run-without-sv.yaml",
+		ExtStr:         map[string]string{"MESSAGE": "hello"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ovs, err := app.TaskOverridesForRun(ecspresso.RunOption{Count: 1, TaskOverrideFile: "tests/run-overrides.jsonnet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ovs) != 1 || len(ovs[0].ContainerOverrides) != 1 {
+		t.Fatalf("unexpected overrides %#v", ovs)
+	}
+	for _, count := range []int32{0, -1} {
+		if _, err := app.TaskOverridesForRun(ecspresso.RunOption{Count: count}); err == nil {
+			t.Errorf("expected error for count %d", count)
+		}
+	}
+	if diff := cmp.Diff([]string{"echo", "hello"}, ovs[0].ContainerOverrides[0].Command); diff != "" {
+		t.Errorf("unexpected command %s", diff)
+	}
+}
+
+func TestRunDryRunOverrideFlags(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-without-sv.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name  string
+		opt   ecspresso.RunOption
+		isErr bool
+	}{
+		{name: "default container", opt: ecspresso.RunOption{Env: []string{"FOO=foo"}}},
+		{name: "container", opt: ecspresso.RunOption{Container: "katsubushi", Command: "echo hello"}},
+		{name: "unknown container", opt: ecspresso.RunOption{Container: "worker", Command: "echo hello"}, isErr: true},
+		{name: "unknown watch container", opt: ecspresso.RunOption{WatchContainer: "worker"}, isErr: true},
+		{name: "invalid env", opt: ecspresso.RunOption{Env: []string{"FOO"}}, isErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opt := tc.opt
+			opt.DryRun = true
+			opt.Count = 1
+			opt.Revision = aws.Int64(0)
+			err := app.Run(ctx, opt)
+			if tc.isErr && err == nil {
+				t.Error("expected error")
+			} else if !tc.isErr && err != nil {
+				t.Errorf("unexpected error %s", err)
+			}
+		})
+	}
+}
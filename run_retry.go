@@ -0,0 +1,207 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/shogo82148/go-retry"
+)
+
+const (
+	defaultRunRetryMaxAttempts = 3
+	defaultRunRetryMinDelay    = 10 * time.Second
+	defaultRunRetryMaxDelay    = time.Minute
+)
+
+// ConfigRunRetry represents a configuration of retrying run on capacity and placement failures.
+type ConfigRunRetry struct {
+	MaxAttempts                      int                       `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+	MinDelay                         *Duration                 `yaml:"min_delay,omitempty" json:"min_delay,omitempty"`
+	MaxDelay                         *Duration                 `yaml:"max_delay,omitempty" json:"max_delay,omitempty"`
+	FallbackCapacityProviderStrategy []*ConfigCapacityProvider `yaml:"fallback_capacity_provider_strategy,omitempty" json:"fallback_capacity_provider_strategy,omitempty"`
+}
+
+func (c *ConfigRunRetry) validate() error {
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative")
+	}
+	if c.MinDelay != nil && c.MaxDelay != nil && c.MinDelay.Duration > c.MaxDelay.Duration {
+		return fmt.Errorf("min_delay must not be greater than max_delay")
+	}
+	for _, cp := range c.FallbackCapacityProviderStrategy {
+		if cp.CapacityProvider == "" {
+			return fmt.Errorf("capacity_provider is required for fallback_capacity_provider_strategy")
+		}
+	}
+	return nil
+}
+
+// runRetryConfig returns a configuration of retry by the run config and flags. Flags take precedence.
+// It returns nil when retry is not configured.
+func (opt RunOption) runRetryConfig(conf *ConfigRun) (*ConfigRunRetry, error) {
+	var c *ConfigRunRetry
+	if conf != nil && conf.Retry != nil {
+		v := *conf.Retry
+		c = &v
+	}
+	if opt.RetryMaxAttempts == 0 && len(opt.FallbackCapacityProvider) == 0 {
+		return c, nil
+	}
+	if c == nil {
+		c = &ConfigRunRetry{}
+	}
+	if opt.RetryMaxAttempts != 0 {
+		c.MaxAttempts = opt.RetryMaxAttempts
+	}
+	if len(opt.FallbackCapacityProvider) > 0 {
+		c.FallbackCapacityProviderStrategy = nil
+		for _, s := range opt.FallbackCapacityProvider {
+			cp, err := parseCapacityProvider(s)
+			if err != nil {
+				return nil, err
+			}
+			c.FallbackCapacityProviderStrategy = append(c.FallbackCapacityProviderStrategy, cp)
+		}
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid flags of run: %w", err)
+	}
+	return c, nil
+}
+
+// runRetry is a policy of retrying run, which holds whether run has fallen back to the fallback capacity provider strategy.
+type runRetry struct {
+	policy   retry.Policy
+	fallback []types.CapacityProviderStrategyItem
+	fellBack bool
+}
+
+// newRunRetry returns a policy of retry by the configuration. It never retries when c is nil.
+func newRunRetry(c *ConfigRunRetry) *runRetry {
+	r := &runRetry{
+		policy: retry.Policy{
+			MinDelay: defaultRunRetryMinDelay,
+			MaxDelay: defaultRunRetryMaxDelay,
+			MaxCount: 1,
+		},
+	}
+	if c == nil {
+		return r
+	}
+	r.policy.MaxCount = c.MaxAttempts
+	if r.policy.MaxCount == 0 {
+		r.policy.MaxCount = defaultRunRetryMaxAttempts
+	}
+	if c.MinDelay != nil {
+		r.policy.MinDelay = c.MinDelay.Duration
+	}
+	if c.MaxDelay != nil {
+		r.policy.MaxDelay = c.MaxDelay.Duration
+	}
+	r.fallback = capacityProviderStrategy(c.FallbackCapacityProviderStrategy)
+	return r
+}
+
+// applyFallback replaces the launch type and capacity provider strategy of in with the fallback after falling back.
+func (r *runRetry) applyFallback(in *ecs.RunTaskInput) {
+	if !r.fellBack {
+		return
+	}
+	in.LaunchType = ""
+	in.CapacityProviderStrategy = r.fallback
+}
+
+// retryRun calls f until it succeeds or returns an error which is not retryable, up to the max attempts with exponential backoff.
+// It falls back to the fallback capacity provider strategy at the first retry.
+func (d *App) retryRun(ctx context.Context, r *runRetry, f func(attempt int) error) error {
+	return d.retryRunFrom(ctx, r, 1, f)
+}
+
+// retryRunFrom is retryRun starting from the attempt first, to run again within the max attempts shared with the attempts already made.
+func (d *App) retryRunFrom(ctx context.Context, r *runRetry, first int, f func(attempt int) error) error {
+	retrier := r.policy.Start(ctx)
+	var err error
+	for attempt := first; retrier.Continue(); attempt++ {
+		if attempt > 1 {
+			d.Log("Retrying run (attempt %d/%d)", attempt, r.policy.MaxCount)
+		}
+		if err = f(attempt); err == nil {
+			return nil
+		}
+		if !isRetryableRunError(err) {
+			return err
+		}
+		if attempt >= r.policy.MaxCount {
+			// not to be retried again by callers
+			return &retryExhaustedError{err: err}
+		}
+		d.Log("[WARNING] attempt %d/%d failed: %s", attempt, r.policy.MaxCount, err)
+		if len(r.fallback) > 0 && !r.fellBack {
+			r.fellBack = true
+			d.Log("Falling back to capacity provider strategy %s", formatCapacityProviderStrategy(r.fallback))
+		}
+	}
+	if rerr := retrier.Err(); rerr != nil {
+		return fmt.Errorf("retry of run is canceled: %w (last error: %s)", rerr, err)
+	}
+	return err
+}
+
+// retryExhaustedError represents a retryable error of run which exceeded the max attempts.
+type retryExhaustedError struct {
+	err error
+}
+
+func (e *retryExhaustedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryExhaustedError) Unwrap() error {
+	return e.err
+}
+
+// isRetryableRunError reports whether run may succeed by retrying, for insufficient capacity of the cluster or a task failed to start.
+func isRetryableRunError(err error) bool {
+	var exhausted *retryExhaustedError
+	var failure *runTaskFailureError
+	var stopped *taskStoppedError
+	switch {
+	case errors.As(err, &exhausted):
+		return false
+	case errors.As(err, &failure):
+		return isRetryableRunTaskFailure(failure.failure)
+	case errors.As(err, &stopped):
+		return stopped.failedToStart
+	default:
+		return false
+	}
+}
+
+// isRetryableRunTaskFailure reports whether the failure of RunTask API is caused by insufficient resources or capacity.
+// Failures caused by the task definition or placement constraints (e.g. ATTRIBUTE) are not retryable.
+func isRetryableRunTaskFailure(f types.Failure) bool {
+	reason := aws.ToString(f.Reason)
+	switch {
+	case strings.HasPrefix(reason, "RESOURCE:"), reason == "AGENT":
+		return true
+	case strings.Contains(strings.ToLower(reason), "capacity is unavailable"):
+		// Fargate and Fargate Spot
+		return true
+	default:
+		return false
+	}
+}
+
+func formatCapacityProviderStrategy(s []types.CapacityProviderStrategyItem) string {
+	items := make([]string, 0, len(s))
+	for _, cp := range s {
+		items = append(items, fmt.Sprintf("%s:%d:%d", aws.ToString(cp.CapacityProvider), cp.Weight, cp.Base))
+	}
+	return strings.Join(items, ",")
+}
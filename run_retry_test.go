@@ -0,0 +1,321 @@
+package ecspresso_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestIsRetryableRunError(t *testing.T) {
+	testCases := []struct {
+		err       error
+		retryable bool
+	}{
+		{err: ecspresso.NewRunTaskFailureError("RESOURCE:MEMORY"), retryable: true},
+		{err: ecspresso.NewRunTaskFailureError("RESOURCE:CPU"), retryable: true},
+		{err: ecspresso.NewRunTaskFailureError("AGENT"), retryable: true},
+		{err: ecspresso.NewRunTaskFailureError("Capacity is unavailable at this time. Please try again later or in a different availability zone"), retryable: true},
+		{err: ecspresso.NewRunTaskFailureError("ATTRIBUTE"), retryable: false},
+		{err: ecspresso.NewRunTaskFailureError("MISSING"), retryable: false},
+		{err: ecspresso.NewTaskFailedToStartError("CannotPullContainerError"), retryable: true},
+		{err: fmt.Errorf("1 of 2 tasks failed: %w", ecspresso.NewTaskFailedToStartError("Timeout waiting for network interface provisioning")), retryable: true},
+		{err: errors.New("failed to run task: AccessDeniedException"), retryable: false},
+	}
+	for _, tc := range testCases {
+		if got := ecspresso.IsRetryableRunError(tc.err); got != tc.retryable {
+			t.Errorf("%s: expected retryable %v, got %v", tc.err, tc.retryable, got)
+		}
+	}
+}
+
+func TestRunRetryConfig(t *testing.T) {
+	conf := &ecspresso.ConfigRun{
+		Retry: &ecspresso.ConfigRunRetry{
+			MaxAttempts: 5,
+			FallbackCapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{
+				{CapacityProvider: "FARGATE"},
+			},
+		},
+	}
+	testCases := []struct {
+		name     string
+		opt      ecspresso.RunOption
+		conf     *ecspresso.ConfigRun
+		expected *ecspresso.ConfigRunRetry
+		isErr    bool
+	}{
+		{name: "not configured", conf: &ecspresso.ConfigRun{}},
+		{name: "config", conf: conf, expected: conf.Retry},
+		{
+			name:     "flags",
+			opt:      ecspresso.RunOption{RetryMaxAttempts: 2},
+			expected: &ecspresso.ConfigRunRetry{MaxAttempts: 2},
+		},
+		{
+			name: "flags override config",
+			opt:  ecspresso.RunOption{RetryMaxAttempts: 3, FallbackCapacityProvider: []string{"FARGATE:1:1", "FARGATE_SPOT:2"}},
+			conf: conf,
+			expected: &ecspresso.ConfigRunRetry{
+				MaxAttempts: 3,
+				FallbackCapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{
+					{CapacityProvider: "FARGATE", Weight: 1, Base: 1},
+					{CapacityProvider: "FARGATE_SPOT", Weight: 2},
+				},
+			},
+		},
+		{name: "invalid max attempts", opt: ecspresso.RunOption{RetryMaxAttempts: -1}, isErr: true},
+		{name: "invalid fallback", opt: ecspresso.RunOption{FallbackCapacityProvider: []string{"FARGATE:x"}}, isErr: true},
+	}
+	for _, tc := range testCases {
+		c, err := tc.opt.RunRetryConfig(tc.conf)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.expected, c); diff != "" {
+			t.Errorf("%s: unexpected config %s", tc.name, diff)
+		}
+	}
+	if conf.Retry.MaxAttempts != 5 || len(conf.Retry.FallbackCapacityProviderStrategy) != 1 {
+		t.Errorf("config must not be modified by flags: %#v", conf.Retry)
+	}
+}
+
+func TestRetryRun(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-task-only.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ecspresso.ConfigRunRetry{
+		MaxAttempts: 3,
+		MinDelay:    &ecspresso.Duration{Duration: time.Millisecond},
+		MaxDelay:    &ecspresso.Duration{Duration: time.Millisecond},
+		FallbackCapacityProviderStrategy: []*ecspresso.ConfigCapacityProvider{
+			{CapacityProvider: "FARGATE", Weight: 1},
+		},
+	}
+	base := &ecs.RunTaskInput{
+		CapacityProviderStrategy: []types.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		},
+	}
+	capacityErr := ecspresso.NewRunTaskFailureError("Capacity is unavailable at this time.")
+
+	testCases := []struct {
+		name      string
+		conf      *ecspresso.ConfigRunRetry
+		errs      []error
+		attempts  int
+		providers []string
+		isErr     bool
+	}{
+		{
+			name:      "succeeded at first",
+			conf:      conf,
+			errs:      []error{nil},
+			attempts:  1,
+			providers: []string{"FARGATE_SPOT"},
+		},
+		{
+			name:      "succeeded with fallback",
+			conf:      conf,
+			errs:      []error{capacityErr, ecspresso.NewTaskFailedToStartError("ResourceInitializationError"), nil},
+			attempts:  3,
+			providers: []string{"FARGATE_SPOT", "FARGATE", "FARGATE"},
+		},
+		{
+			name:      "exceeded max attempts",
+			conf:      conf,
+			errs:      []error{capacityErr, capacityErr, capacityErr, nil},
+			attempts:  3,
+			providers: []string{"FARGATE_SPOT", "FARGATE", "FARGATE"},
+			isErr:     true,
+		},
+		{
+			name:      "not retryable",
+			conf:      conf,
+			errs:      []error{ecspresso.NewRunTaskFailureError("ATTRIBUTE"), nil},
+			attempts:  1,
+			providers: []string{"FARGATE_SPOT"},
+			isErr:     true,
+		},
+		{
+			name:      "not configured",
+			errs:      []error{capacityErr, nil},
+			attempts:  1,
+			providers: []string{"FARGATE_SPOT"},
+			isErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		var providers []string
+		err := app.RetryRun(ctx, tc.conf, base, func(attempt int, in *ecs.RunTaskInput) error {
+			providers = append(providers, aws.ToString(in.CapacityProviderStrategy[0].CapacityProvider))
+			return tc.errs[attempt-1]
+		})
+		if tc.isErr && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+		}
+		if len(providers) != tc.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", tc.name, tc.attempts, len(providers))
+		}
+		if diff := cmp.Diff(tc.providers, providers); diff != "" {
+			t.Errorf("%s: unexpected capacity providers %s", tc.name, diff)
+		}
+		if tc.isErr && ecspresso.IsRetryableRunError(err) {
+			t.Errorf("%s: error must not be retried again: %s", tc.name, err)
+		}
+	}
+}
+
+// failedToStartTestMiddleware runs tasks for each RunTask API call, and describes the first fails tasks as failed to start.
+// All the tasks fail to start when fails is 0. The other tasks are running, or stopped successfully.
+type failedToStartTestMiddleware struct {
+	mu       sync.Mutex
+	fails    int
+	running  bool
+	runTasks int
+	started  int
+}
+
+func (m *failedToStartTestMiddleware) apply(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("test",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			switch params := in.Parameters.(type) {
+			case *ecs.RunTaskInput:
+				m.runTasks++
+				out := &ecs.RunTaskOutput{}
+				for i := int32(0); i < aws.ToInt32(params.Count); i++ {
+					m.started++
+					out.Tasks = append(out.Tasks, types.Task{TaskArn: aws.String(fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task/default/task%d", m.started))})
+				}
+				return middleware.InitializeOutput{Result: out}, middleware.Metadata{}, nil
+			case *ecs.DescribeTasksInput:
+				out := &ecs.DescribeTasksOutput{}
+				for _, arn := range params.Tasks {
+					var n int
+					fmt.Sscanf(ecspresso.ArnToName(arn), "task%d", &n)
+					switch {
+					case m.fails == 0 || n <= m.fails:
+						out.Tasks = append(out.Tasks, types.Task{
+							TaskArn:       aws.String(arn),
+							LastStatus:    aws.String("STOPPED"),
+							StopCode:      types.TaskStopCodeTaskFailedToStart,
+							StoppedReason: aws.String("CannotPullContainerError"),
+						})
+					case m.running:
+						out.Tasks = append(out.Tasks, types.Task{TaskArn: aws.String(arn), LastStatus: aws.String("RUNNING")})
+					default:
+						out.Tasks = append(out.Tasks, types.Task{
+							TaskArn:    aws.String(arn),
+							LastStatus: aws.String("STOPPED"),
+							StopCode:   types.TaskStopCodeEssentialContainerExited,
+							Containers: []types.Container{{Name: aws.String("app"), ExitCode: aws.Int32(0)}},
+						})
+					}
+				}
+				return middleware.InitializeOutput{Result: out}, middleware.Metadata{}, nil
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+}
+
+func TestRunAndWaitTaskMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	m := &failedToStartTestMiddleware{}
+	ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+		config.WithRegion("ap-northeast-1"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{m.apply}),
+	})
+	defer ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-without-sv.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ecspresso.ConfigRunRetry{
+		MaxAttempts: 3,
+		MinDelay:    &ecspresso.Duration{Duration: time.Millisecond},
+		MaxDelay:    &ecspresso.Duration{Duration: time.Millisecond},
+	}
+	in := &ecs.RunTaskInput{Cluster: aws.String("default"), TaskDefinition: aws.String("app:1")}
+	watch := &types.ContainerDefinition{Name: aws.String("app")}
+	task, err := app.RunAndWaitTask(ctx, conf, in, watch, true)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if task == nil || aws.ToString(task.TaskArn) != "arn:aws:ecs:ap-northeast-1:123456789012:task/default/task3" {
+		t.Errorf("the last task must be returned: %v", task)
+	}
+	// tasks failed to start and failures of RunTask API share the max attempts
+	if m.runTasks != conf.MaxAttempts {
+		t.Errorf("RunTask must be called %d times, but %d times", conf.MaxAttempts, m.runTasks)
+	}
+}
+
+func TestRunAndWaitTasksFailedToStart(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name     string
+		fails    int
+		running  bool
+		runTasks int
+		started  int
+		isErr    bool
+	}{
+		// 3 tasks are run, and the 2 tasks failed to start are run again
+		{name: "run again", fails: 2, runTasks: 2, started: 5},
+		{name: "run again until running", fails: 2, running: true, runTasks: 2, started: 5},
+		// the tasks failed to start are run again within the max attempts
+		{name: "max attempts", runTasks: 3, started: 9, isErr: true},
+	}
+	conf := &ecspresso.ConfigRunRetry{
+		MaxAttempts: 3,
+		MinDelay:    &ecspresso.Duration{Duration: time.Millisecond},
+		MaxDelay:    &ecspresso.Duration{Duration: time.Millisecond},
+	}
+	in := &ecs.RunTaskInput{Cluster: aws.String("default"), TaskDefinition: aws.String("app:1")}
+	watch := &types.ContainerDefinition{Name: aws.String("app")}
+	for _, tc := range testCases {
+		m := &failedToStartTestMiddleware{fails: tc.fails, running: tc.running}
+		ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+			config.WithRegion("ap-northeast-1"),
+			config.WithAPIOptions([]func(*middleware.Stack) error{m.apply}),
+		})
+		app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-without-sv.yaml"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = app.RunAndWaitTasks(ctx, conf, in, []types.TaskOverride{{}}, 3, watch, tc.running)
+		ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+		if tc.isErr && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+		}
+		if m.runTasks != tc.runTasks || m.started != tc.started {
+			t.Errorf("%s: expected %d RunTask calls and %d tasks, got %d calls and %d tasks", tc.name, tc.runTasks, tc.started, m.runTasks, m.started)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// maxRunTaskCount is the maximum count of tasks in a RunTask API call.
+	maxRunTaskCount = 10
+	// maxDescribeTasks is the maximum number of tasks in a DescribeTasks API call.
+	maxDescribeTasks = 100
+
+	runTasksStoppedReason = "stopped by ecspresso run because other tasks failed to run"
+)
+
+// runTaskRequest is a request of RunTask API.
+type runTaskRequest struct {
+	overrides *types.TaskOverride
+	count     int32
+
+	// attempts is the number of attempts made for the request, and tasks are the tasks started by the last run.
+	attempts int
+	tasks    []types.Task
+}
+
+// tasksOfRequests returns the tasks started by the requests in the order of the requests.
+func tasksOfRequests(reqs []runTaskRequest) []types.Task {
+	var tasks []types.Task
+	for _, req := range reqs {
+		tasks = append(tasks, req.tasks...)
+	}
+	return tasks
+}
+
+// runTaskRequests splits tasks to run into requests of RunTask API.
+// When ovs has one override, count tasks are run with the override in batches of maxRunTaskCount.
+// Otherwise, a task is run for each override.
+func runTaskRequests(ovs []types.TaskOverride, count int32) []runTaskRequest {
+	var reqs []runTaskRequest
+	if len(ovs) != 1 {
+		for i := range ovs {
+			reqs = append(reqs, runTaskRequest{overrides: &ovs[i], count: 1})
+		}
+		return reqs
+	}
+	for count > 0 {
+		n := count
+		if n > maxRunTaskCount {
+			n = maxRunTaskCount
+		}
+		reqs = append(reqs, runTaskRequest{overrides: &ovs[0], count: n})
+		count -= n
+	}
+	return reqs
+}
+
+// stopRunTask stops the task run by run.
+func (d *App) stopRunTask(task *types.Task, reason string) error {
+	// the context of run may be canceled already
+	ctx, cancel := d.Start(context.Background())
+	defer cancel()
+	id := arnToName(aws.ToString(task.TaskArn))
+	d.Log("Stopping task ID %s", id)
+	if _, err := d.ecs.StopTask(ctx, &ecs.StopTaskInput{
+		Cluster: aws.String(d.Cluster),
+		Task:    task.TaskArn,
+		Reason:  aws.String(reason),
+	}); err != nil {
+		return fmt.Errorf("failed to stop task %s: %w", id, err)
+	}
+	return nil
+}
+
+// WaitRunTasks waits for all tasks concurrently, printing logs of the watch container of each task with a prefix.
+// It prints the results of tasks and returns an error when some tasks failed.
+func (d *App) WaitRunTasks(ctx context.Context, tasks []types.Task, watchContainer *types.ContainerDefinition, startedAt time.Time, untilRunning bool) error {
+	described, err := d.waitRunTasks(ctx, tasks, watchContainer, startedAt, untilRunning)
+	if err != nil {
+		return err
+	}
+	return d.runTasksResult(described, watchContainer, untilRunning)
+}
+
+// runAndWaitTasks runs tasks and waits for them. Tasks failed to start are run again with their overrides,
+// and running them again counts as an attempt of their request, sharing the max attempts with failures of RunTask API.
+func (d *App) runAndWaitTasks(ctx context.Context, in *ecs.RunTaskInput, ovs []types.TaskOverride, count int32, watchContainer *types.ContainerDefinition, untilRunning bool, r *runRetry) error {
+	startedAt := time.Now()
+	reqs := runTaskRequests(ovs, count)
+	var results []types.Task
+	for {
+		if err := d.runRequests(ctx, in, reqs, r); err != nil {
+			return err
+		}
+		described, err := d.waitRunTasks(ctx, tasksOfRequests(reqs), watchContainer, startedAt, untilRunning)
+		if described == nil {
+			return err
+		}
+		var retries []runTaskRequest
+		var failedToStart, notRunning int
+		i := 0
+		for _, req := range reqs {
+			var n int32
+			for range req.tasks {
+				task := described[i]
+				i++
+				if task.StopCode == types.TaskStopCodeTaskFailedToStart && req.attempts < r.policy.MaxCount {
+					n++
+					continue
+				}
+				if untilRunning && aws.ToString(task.LastStatus) != "RUNNING" {
+					notRunning++
+				}
+				results = append(results, task)
+			}
+			if n > 0 {
+				retries = append(retries, runTaskRequest{overrides: req.overrides, count: n, attempts: req.attempts})
+				failedToStart += int(n)
+			}
+		}
+		if err != nil && (len(retries) == 0 || notRunning > 0) {
+			// some tasks will not be running by running again the tasks failed to start
+			return err
+		}
+		if len(retries) == 0 {
+			break
+		}
+		d.Log("[WARNING] %d tasks failed to start", failedToStart)
+		reqs = retries
+	}
+	return d.runTasksResult(results, watchContainer, untilRunning)
+}
+
+// waitRunTasks waits for tasks with logs of the watch container, and returns the described tasks.
+// When waiting until running fails, it also returns the described tasks with the error to find tasks stopped before running.
+func (d *App) waitRunTasks(ctx context.Context, tasks []types.Task, watchContainer *types.ContainerDefinition, startedAt time.Time, untilRunning bool) ([]types.Task, error) {
+	d.Log("Waiting for %d tasks...(it may take a while)", len(tasks))
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lc := watchContainer.LogConfiguration
+	if lc == nil || lc.LogDriver != types.LogDriverAwslogs || lc.Options["awslogs-stream-prefix"] == "" {
+		d.Log("awslogs not configured")
+	} else {
+		d.Log("Watching container: %s", *watchContainer.Name)
+		time.Sleep(3 * time.Second) // wait for log stream
+		for i, task := range tasks {
+			logGroup, logStream := logInfo(&task, watchContainer)
+			prefix := fmt.Sprintf("[%d %s] ", i+1, arnToName(*task.TaskArn))
+			go func() {
+				ticker := time.NewTicker(5 * time.Second)
+				var nextToken *string
+				for {
+					select {
+					case <-waitCtx.Done():
+						return
+					case <-ticker.C:
+						nextToken, _ = d.getLogEvents(waitCtx, logGroup, logStream, startedAt, nextToken, prefix)
+					}
+				}
+			}()
+		}
+	}
+
+	werr := d.waitTasks(ctx, tasks, untilRunning)
+	if werr != nil && !untilRunning {
+		return nil, werr
+	}
+	described, err := d.describeTasks(ctx, tasks)
+	if err != nil {
+		if werr != nil {
+			return nil, werr
+		}
+		return nil, err
+	}
+	return described, werr
+}
+
+// runTasksResult prints the results of the tasks and returns an error when some tasks failed.
+func (d *App) runTasksResult(tasks []types.Task, watchContainer *types.ContainerDefinition, untilRunning bool) error {
+	if untilRunning {
+		d.Log("%d tasks are running", len(tasks))
+		return nil
+	}
+	results := newRunTaskResults(tasks, watchContainer)
+	results.OutputTable(os.Stdout)
+	if n := results.failed(); n > 0 {
+		return fmt.Errorf("%d of %d tasks failed: %w", n, len(results), results.exitError())
+	}
+	return nil
+}
+
+// waitTasks waits for tasks in chunks of DescribeTasks API concurrently.
+func (d *App) waitTasks(ctx context.Context, tasks []types.Task, untilRunning bool) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, arns := range chunkTaskArns(tasks) {
+		arns := arns
+		in := &ecs.DescribeTasksInput{
+			Cluster: aws.String(d.Cluster),
+			Tasks:   arns,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if untilRunning {
+				err = ecs.NewTasksRunningWaiter(d.ecs).Wait(ctx, in, d.Timeout())
+			} else {
+				err = ecs.NewTasksStoppedWaiter(d.ecs).Wait(ctx, in, d.Timeout())
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to wait tasks: %w", errs[0])
+	}
+	return nil
+}
+
+// describeTasks describes tasks in the order of tasks.
+func (d *App) describeTasks(ctx context.Context, tasks []types.Task) ([]types.Task, error) {
+	described := make(map[string]types.Task, len(tasks))
+	for _, arns := range chunkTaskArns(tasks) {
+		out, err := d.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(d.Cluster),
+			Tasks:   arns,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		for _, f := range out.Failures {
+			d.Log("[WARNING] failed to describe task %s: %s", aws.ToString(f.Arn), aws.ToString(f.Reason))
+		}
+		for _, t := range out.Tasks {
+			described[aws.ToString(t.TaskArn)] = t
+		}
+	}
+	res := make([]types.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if dt, ok := described[aws.ToString(t.TaskArn)]; ok {
+			res = append(res, dt)
+		} else {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func chunkTaskArns(tasks []types.Task) [][]string {
+	var chunks [][]string
+	for i := 0; i < len(tasks); i += maxDescribeTasks {
+		end := i + maxDescribeTasks
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+		arns := make([]string, 0, end-i)
+		for _, t := range tasks[i:end] {
+			arns = append(arns, aws.ToString(t.TaskArn))
+		}
+		chunks = append(chunks, arns)
+	}
+	return chunks
+}
+
+// runTaskResult is a result of a task run by RunTask.
+type runTaskResult struct {
+	TaskID    string
+	Container string
+	ExitCode  *int32
+	Err       error
+}
+
+type runTaskResults []runTaskResult
+
+func newRunTaskResults(tasks []types.Task, watchContainer *types.ContainerDefinition) runTaskResults {
+	results := make(runTaskResults, 0, len(tasks))
+	for i := range tasks {
+		task := &tasks[i]
+		r := runTaskResult{TaskID: arnToName(aws.ToString(task.TaskArn))}
+		if task.LastStatus != nil && *task.LastStatus != "STOPPED" {
+			r.Err = fmt.Errorf("task is %s", *task.LastStatus)
+		} else {
+			c, err := taskStatus(task, watchContainer)
+			if c != nil {
+				r.Container = aws.ToString(c.Name)
+				r.ExitCode = c.ExitCode
+			}
+			r.Err = err
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func (rs runTaskResults) failed() int {
+	n := 0
+	for _, r := range rs {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (rs runTaskResults) Header() []string {
+	return []string{"#", "Task ID", "Container", "Exit Code", "Error"}
+}
+
+func (r runTaskResult) Cols(i int) []string {
+	exitCode := "-"
+	if r.ExitCode != nil {
+		exitCode = strconv.Itoa(int(*r.ExitCode))
+	}
+	var errMsg string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	return []string{strconv.Itoa(i + 1), r.TaskID, r.Container, exitCode, errMsg}
+}
+
+func (rs runTaskResults) OutputTable(w io.Writer) error {
+	t := tablewriter.NewWriter(w)
+	t.SetHeader(rs.Header())
+	t.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	for i, r := range rs {
+		t.Append(r.Cols(i))
+	}
+	t.Render()
+	return nil
+}
@@ -0,0 +1,184 @@
+package ecspresso_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestRunTaskRequests(t *testing.T) {
+	ov := types.TaskOverride{Cpu: aws.String("256")}
+	testCases := []struct {
+		name   string
+		ovs    []types.TaskOverride
+		count  int32
+		counts []int32
+	}{
+		{name: "single", ovs: []types.TaskOverride{ov}, count: 1, counts: []int32{1}},
+		{name: "max", ovs: []types.TaskOverride{ov}, count: 10, counts: []int32{10}},
+		{name: "batched", ovs: []types.TaskOverride{ov}, count: 25, counts: []int32{10, 10, 5}},
+		{name: "list", ovs: []types.TaskOverride{ov, ov, ov}, count: 1, counts: []int32{1, 1, 1}},
+		{name: "zero", ovs: []types.TaskOverride{ov}, count: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reqs := ecspresso.RunTaskRequests(tc.ovs, tc.count)
+			if len(reqs) != len(tc.counts) {
+				t.Fatalf("unexpected requests %d, want %d", len(reqs), len(tc.counts))
+			}
+			for i, r := range reqs {
+				if r.Count != tc.counts[i] {
+					t.Errorf("unexpected count %d at %d, want %d", r.Count, i, tc.counts[i])
+				}
+				if r.Overrides == nil || aws.ToString(r.Overrides.Cpu) != "256" {
+					t.Errorf("unexpected overrides at %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTaskResults(t *testing.T) {
+	watch := &types.ContainerDefinition{Name: aws.String("app")}
+	stopped := func(id string, exitCode int32) types.Task {
+		return types.Task{
+			TaskArn:    aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task/default/" + id),
+			LastStatus: aws.String("STOPPED"),
+			Containers: []types.Container{
+				{Name: aws.String("sidecar"), ExitCode: aws.Int32(0)},
+				{Name: aws.String("app"), ExitCode: aws.Int32(exitCode)},
+			},
+		}
+	}
+	tasks := []types.Task{
+		stopped("task1", 0),
+		stopped("task2", 3),
+		{
+			TaskArn:       aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task/default/task3"),
+			LastStatus:    aws.String("STOPPED"),
+			StopCode:      types.TaskStopCodeTaskFailedToStart,
+			StoppedReason: aws.String("CannotPullContainerError"),
+		},
+		stopped("task4", 0),
+	}
+	failed, table := ecspresso.RunTaskResults(tasks, watch)
+	if failed != 2 {
+		t.Errorf("unexpected failed %d", failed)
+	}
+	for _, s := range []string{"task1", "task2", "task3", "task4", "exit code: 3", "CannotPullContainerError"} {
+		if !strings.Contains(table, s) {
+			t.Errorf("table should contain %s\n%s", s, table)
+		}
+	}
+}
+
+// runTasksTestMiddleware runs tasks until the limit, and fails RunTask API calls over the limit.
+// It records tasks stopped by StopTask API.
+type runTasksTestMiddleware struct {
+	mu      sync.Mutex
+	limit   int
+	started int
+	stopped []string
+}
+
+func (m *runTasksTestMiddleware) apply(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("test",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			switch params := in.Parameters.(type) {
+			case *ecs.RunTaskInput:
+				out := &ecs.RunTaskOutput{}
+				for i := int32(0); i < aws.ToInt32(params.Count); i++ {
+					if m.started >= m.limit {
+						out.Failures = append(out.Failures, types.Failure{Reason: aws.String("ATTRIBUTE")})
+						continue
+					}
+					m.started++
+					out.Tasks = append(out.Tasks, types.Task{TaskArn: aws.String(fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task/default/task%d", m.started))})
+				}
+				return middleware.InitializeOutput{Result: out}, middleware.Metadata{}, nil
+			case *ecs.StopTaskInput:
+				m.stopped = append(m.stopped, ecspresso.ArnToName(aws.ToString(params.Task)))
+				return middleware.InitializeOutput{Result: &ecs.StopTaskOutput{}}, middleware.Metadata{}, nil
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+}
+
+func TestRunTasksStopStartedTasks(t *testing.T) {
+	ctx := context.Background()
+	m := &runTasksTestMiddleware{limit: 12}
+	ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+		config.WithRegion("ap-northeast-1"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{m.apply}),
+	})
+	defer ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-without-sv.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &ecs.RunTaskInput{Cluster: aws.String("default"), TaskDefinition: aws.String("app:1")}
+	ovs := []types.TaskOverride{{}}
+
+	tasks, err := app.RunTasksWithInput(ctx, in, ovs, 15)
+	if err == nil {
+		t.Fatalf("expected error, but %d tasks run", len(tasks))
+	}
+	if len(tasks) != 0 {
+		t.Errorf("no tasks must be returned on error: %d", len(tasks))
+	}
+	var expected []string
+	for i := 1; i <= 12; i++ {
+		expected = append(expected, fmt.Sprintf("task%d", i))
+	}
+	if diff := cmp.Diff(expected, m.stopped); diff != "" {
+		t.Errorf("tasks already started must be stopped (-want +got):\n%s", diff)
+	}
+
+	if _, err := app.RunTasksWithInput(ctx, in, ovs, 0); err == nil {
+		t.Error("expected error for no tasks to run")
+	}
+}
+
+func TestRunTask(t *testing.T) {
+	ctx := context.Background()
+	m := &runTasksTestMiddleware{limit: 10}
+	ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+		config.WithRegion("ap-northeast-1"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{m.apply}),
+	})
+	defer ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-without-sv.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := app.RunTask(ctx, "app:1", nil, &ecspresso.RunOption{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := ecspresso.ArnToName(aws.ToString(task.TaskArn)); name != "task1" {
+		t.Errorf("the first task must be returned: %s", name)
+	}
+	if m.started != 2 {
+		t.Errorf("2 tasks must be run: %d", m.started)
+	}
+
+	tasks, err := app.RunTasks(ctx, "app:1", []types.TaskOverride{{}, {}, {}}, &ecspresso.RunOption{Count: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 3 {
+		t.Errorf("a task must be run for each override: %d", len(tasks))
+	}
+}
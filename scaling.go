@@ -0,0 +1,404 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+)
+
+// serviceLinkedRoleNameForECSScaling is a name of the service-linked role which is used by default for ECS services.
+const serviceLinkedRoleNameForECSScaling = "AWSServiceRoleForApplicationAutoScaling_ECSService"
+
+// ScalingDefinition represents a declarative definition of Application Auto Scaling for the service.
+type ScalingDefinition struct {
+	ScalableTarget   *ScalableTargetDefinition    `json:"scalableTarget,omitempty"`
+	ScalingPolicies  []*ScalingPolicyDefinition   `json:"scalingPolicies,omitempty"`
+	ScheduledActions []*ScheduledActionDefinition `json:"scheduledActions,omitempty"`
+}
+
+// ScalableTargetDefinition represents a scalable target of the service.
+type ScalableTargetDefinition struct {
+	MinCapacity    *int32                   `json:"minCapacity,omitempty"`
+	MaxCapacity    *int32                   `json:"maxCapacity,omitempty"`
+	RoleARN        *string                  `json:"roleARN,omitempty"`
+	SuspendedState *aasTypes.SuspendedState `json:"suspendedState,omitempty"`
+}
+
+// ScalingPolicyDefinition represents a target tracking or step scaling policy.
+type ScalingPolicyDefinition struct {
+	PolicyName                               *string                                            `json:"policyName,omitempty"`
+	PolicyType                               aasTypes.PolicyType                                `json:"policyType,omitempty"`
+	StepScalingPolicyConfiguration           *aasTypes.StepScalingPolicyConfiguration           `json:"stepScalingPolicyConfiguration,omitempty"`
+	TargetTrackingScalingPolicyConfiguration *aasTypes.TargetTrackingScalingPolicyConfiguration `json:"targetTrackingScalingPolicyConfiguration,omitempty"`
+}
+
+// ScheduledActionDefinition represents a scheduled action.
+type ScheduledActionDefinition struct {
+	ScheduledActionName  *string                        `json:"scheduledActionName,omitempty"`
+	Schedule             *string                        `json:"schedule,omitempty"`
+	Timezone             *string                        `json:"timezone,omitempty"`
+	StartTime            *time.Time                     `json:"startTime,omitempty"`
+	EndTime              *time.Time                     `json:"endTime,omitempty"`
+	ScalableTargetAction *aasTypes.ScalableTargetAction `json:"scalableTargetAction,omitempty"`
+}
+
+// scalingPlan represents API calls to apply a scaling definition.
+type scalingPlan struct {
+	RegisterScalableTarget *ScalableTargetDefinition
+	PutScalingPolicies     []*ScalingPolicyDefinition
+	DeleteScalingPolicies  []string
+	PutScheduledActions    []*ScheduledActionDefinition
+	DeleteScheduledActions []string
+}
+
+func (p *scalingPlan) isEmpty() bool {
+	return p.RegisterScalableTarget == nil &&
+		len(p.PutScalingPolicies) == 0 && len(p.DeleteScalingPolicies) == 0 &&
+		len(p.PutScheduledActions) == 0 && len(p.DeleteScheduledActions) == 0
+}
+
+// newScalingPlan computes API calls to make the remote scaling definition match the local one.
+// Policies and scheduled actions which are not defined in local are deleted.
+func newScalingPlan(local, remote *ScalingDefinition) *scalingPlan {
+	local, remote = copyScalingDefinition(local), copyScalingDefinition(remote)
+	normalizeScalingDefinitionForDiff(local)
+	normalizeScalingDefinitionForDiff(remote)
+
+	p := &scalingPlan{}
+	if remote.ScalableTarget == nil || jsonStr(local.ScalableTarget) != jsonStr(remote.ScalableTarget) {
+		p.RegisterScalableTarget = local.ScalableTarget
+	}
+
+	remotePolicies := map[string]*ScalingPolicyDefinition{}
+	for _, sp := range remote.ScalingPolicies {
+		remotePolicies[aws.ToString(sp.PolicyName)] = sp
+	}
+	for _, sp := range local.ScalingPolicies {
+		name := aws.ToString(sp.PolicyName)
+		if r, ok := remotePolicies[name]; !ok || jsonStr(sp) != jsonStr(r) {
+			p.PutScalingPolicies = append(p.PutScalingPolicies, sp)
+		}
+		delete(remotePolicies, name)
+	}
+	for name := range remotePolicies {
+		p.DeleteScalingPolicies = append(p.DeleteScalingPolicies, name)
+	}
+	sort.Strings(p.DeleteScalingPolicies)
+
+	remoteActions := map[string]*ScheduledActionDefinition{}
+	for _, sa := range remote.ScheduledActions {
+		remoteActions[aws.ToString(sa.ScheduledActionName)] = sa
+	}
+	for _, sa := range local.ScheduledActions {
+		name := aws.ToString(sa.ScheduledActionName)
+		if r, ok := remoteActions[name]; !ok || jsonStr(sa) != jsonStr(r) {
+			p.PutScheduledActions = append(p.PutScheduledActions, sa)
+		}
+		delete(remoteActions, name)
+	}
+	for name := range remoteActions {
+		p.DeleteScheduledActions = append(p.DeleteScheduledActions, name)
+	}
+	sort.Strings(p.DeleteScheduledActions)
+	return p
+}
+
+// copyScalingDefinition returns a deep copy of sd to normalize without side effects.
+func copyScalingDefinition(sd *ScalingDefinition) *ScalingDefinition {
+	var c ScalingDefinition
+	if sd == nil {
+		return &c
+	}
+	b, _ := json.Marshal(sd)
+	json.Unmarshal(b, &c)
+	return &c
+}
+
+// normalizeScalingDefinitionForDiff removes values filled by Application Auto Scaling as defaults and sorts lists by name.
+func normalizeScalingDefinitionForDiff(sd *ScalingDefinition) {
+	if t := sd.ScalableTarget; t != nil {
+		if strings.HasSuffix(aws.ToString(t.RoleARN), "/"+serviceLinkedRoleNameForECSScaling) {
+			t.RoleARN = nil
+		}
+		if s := t.SuspendedState; s != nil &&
+			!aws.ToBool(s.DynamicScalingInSuspended) &&
+			!aws.ToBool(s.DynamicScalingOutSuspended) &&
+			!aws.ToBool(s.ScheduledScalingSuspended) {
+			t.SuspendedState = nil
+		}
+	}
+	for _, sp := range sd.ScalingPolicies {
+		if c := sp.TargetTrackingScalingPolicyConfiguration; c != nil {
+			if c.DisableScaleIn != nil && !*c.DisableScaleIn {
+				c.DisableScaleIn = nil
+			}
+		}
+	}
+	for _, sa := range sd.ScheduledActions {
+		if aws.ToString(sa.Timezone) == "UTC" {
+			sa.Timezone = nil
+		}
+	}
+	sort.SliceStable(sd.ScalingPolicies, func(i, j int) bool {
+		return aws.ToString(sd.ScalingPolicies[i].PolicyName) < aws.ToString(sd.ScalingPolicies[j].PolicyName)
+	})
+	sort.SliceStable(sd.ScheduledActions, func(i, j int) bool {
+		return aws.ToString(sd.ScheduledActions[i].ScheduledActionName) < aws.ToString(sd.ScheduledActions[j].ScheduledActionName)
+	})
+}
+
+func scalingDefinitionsJSONForDiff(local, remote *ScalingDefinition) (localJSON, remoteJSON []byte, err error) {
+	local, remote = copyScalingDefinition(local), copyScalingDefinition(remote)
+	normalizeScalingDefinitionForDiff(local)
+	normalizeScalingDefinitionForDiff(remote)
+
+	localJSON, err = MarshalJSONForAPI(local)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal new scaling definition: %w", err)
+	}
+	remoteJSON, err = MarshalJSONForAPI(remote)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal remote scaling definition: %w", err)
+	}
+	return localJSON, remoteJSON, nil
+}
+
+func diffScalingDefinitions(local, remote *ScalingDefinition, remoteName string, localPath string, unified bool) (string, error) {
+	newBytes, remoteBytes, err := scalingDefinitionsJSONForDiff(local, remote)
+	if err != nil {
+		return "", err
+	}
+	return diffJSONText(string(remoteBytes), string(newBytes), remoteName, localPath, unified), nil
+}
+
+// LoadScalingDefinition loads a scaling definition file.
+func (d *App) LoadScalingDefinition(path string) (*ScalingDefinition, error) {
+	if path == "" {
+		return nil, fmt.Errorf("scaling_definition is not defined")
+	}
+	src, err := d.readDefinitionFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scaling definition %s: %w", path, err)
+	}
+	var sd ScalingDefinition
+	if err := unmarshalJSON(src, &sd, path); err != nil {
+		return nil, fmt.Errorf("failed to load scaling definition %s: %w", path, err)
+	}
+	if err := sd.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scaling definition %s: %w", path, err)
+	}
+	return &sd, nil
+}
+
+func (sd *ScalingDefinition) validate() error {
+	if sd.ScalableTarget == nil {
+		return fmt.Errorf("scalableTarget is required")
+	}
+	policies := map[string]bool{}
+	for _, sp := range sd.ScalingPolicies {
+		name := aws.ToString(sp.PolicyName)
+		if name == "" {
+			return fmt.Errorf("policyName is required")
+		}
+		if policies[name] {
+			return fmt.Errorf("policyName %s is duplicated", name)
+		}
+		policies[name] = true
+	}
+	actions := map[string]bool{}
+	for _, sa := range sd.ScheduledActions {
+		name := aws.ToString(sa.ScheduledActionName)
+		if name == "" {
+			return fmt.Errorf("scheduledActionName is required")
+		}
+		if actions[name] {
+			return fmt.Errorf("scheduledActionName %s is duplicated", name)
+		}
+		actions[name] = true
+	}
+	return nil
+}
+
+func (d *App) scalingResourceId() string {
+	return fmt.Sprintf("service/%s/%s", d.Cluster, d.Service)
+}
+
+// describeScalingDefinition describes the current scaling settings of the service.
+// ScalableTarget is nil when the service is not registered as a scalable target.
+func (d *App) describeScalingDefinition(ctx context.Context) (*ScalingDefinition, error) {
+	resourceId := d.scalingResourceId()
+	sd := &ScalingDefinition{}
+	tout, err := d.autoScaling.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+		ResourceIds:       []string{resourceId},
+		ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+		ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe scalable targets: %w", err)
+	}
+	if len(tout.ScalableTargets) == 0 {
+		return sd, nil
+	}
+	t := tout.ScalableTargets[0]
+	sd.ScalableTarget = &ScalableTargetDefinition{
+		MinCapacity:    t.MinCapacity,
+		MaxCapacity:    t.MaxCapacity,
+		RoleARN:        t.RoleARN,
+		SuspendedState: t.SuspendedState,
+	}
+
+	pp := applicationautoscaling.NewDescribeScalingPoliciesPaginator(d.autoScaling, &applicationautoscaling.DescribeScalingPoliciesInput{
+		ResourceId:        aws.String(resourceId),
+		ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+		ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	for pp.HasMorePages() {
+		out, err := pp.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe scaling policies: %w", err)
+		}
+		for _, p := range out.ScalingPolicies {
+			sd.ScalingPolicies = append(sd.ScalingPolicies, &ScalingPolicyDefinition{
+				PolicyName:                               p.PolicyName,
+				PolicyType:                               p.PolicyType,
+				StepScalingPolicyConfiguration:           p.StepScalingPolicyConfiguration,
+				TargetTrackingScalingPolicyConfiguration: p.TargetTrackingScalingPolicyConfiguration,
+			})
+		}
+	}
+
+	ap := applicationautoscaling.NewDescribeScheduledActionsPaginator(d.autoScaling, &applicationautoscaling.DescribeScheduledActionsInput{
+		ResourceId:        aws.String(resourceId),
+		ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+		ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	for ap.HasMorePages() {
+		out, err := ap.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe scheduled actions: %w", err)
+		}
+		for _, a := range out.ScheduledActions {
+			sd.ScheduledActions = append(sd.ScheduledActions, &ScheduledActionDefinition{
+				ScheduledActionName:  a.ScheduledActionName,
+				Schedule:             a.Schedule,
+				Timezone:             a.Timezone,
+				StartTime:            a.StartTime,
+				EndTime:              a.EndTime,
+				ScalableTargetAction: a.ScalableTargetAction,
+			})
+		}
+	}
+	return sd, nil
+}
+
+// applyScalingDefinition creates, updates and deletes the scalable target, scaling policies and scheduled actions
+// to match the scaling definition file.
+func (d *App) applyScalingDefinition(ctx context.Context, opt DeployOption) error {
+	local, err := d.LoadScalingDefinition(d.config.ScalingDefinitionPath)
+	if err != nil {
+		return err
+	}
+	remote, err := d.describeScalingDefinition(ctx)
+	if err != nil {
+		return err
+	}
+	plan := newScalingPlan(local, remote)
+	if plan.isEmpty() {
+		d.Log("scaling definition will not change")
+		return nil
+	}
+	if ds, err := diffScalingDefinitions(local, remote, d.scalingResourceId(), d.config.ScalingDefinitionPath, true); err != nil {
+		return err
+	} else if ds != "" {
+		d.Log("[INFO] scaling definition:")
+		fmt.Print(coloredDiff(ds))
+	}
+	if opt.DryRun {
+		return nil
+	}
+
+	resourceId := aws.String(d.scalingResourceId())
+	if t := plan.RegisterScalableTarget; t != nil {
+		d.Log("Register scalable target %s", *resourceId)
+		suspended := t.SuspendedState
+		if suspended == nil {
+			// resume scaling explicitly. RegisterScalableTarget keeps the current state when omitted
+			suspended = &aasTypes.SuspendedState{
+				DynamicScalingInSuspended:  aws.Bool(false),
+				DynamicScalingOutSuspended: aws.Bool(false),
+				ScheduledScalingSuspended:  aws.Bool(false),
+			}
+		}
+		if _, err := d.autoScaling.RegisterScalableTarget(ctx, &applicationautoscaling.RegisterScalableTargetInput{
+			ResourceId:        resourceId,
+			ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+			ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+			MinCapacity:       t.MinCapacity,
+			MaxCapacity:       t.MaxCapacity,
+			RoleARN:           t.RoleARN,
+			SuspendedState:    suspended,
+		}); err != nil {
+			return fmt.Errorf("failed to register scalable target %s: %w", *resourceId, err)
+		}
+	}
+	for _, name := range plan.DeleteScalingPolicies {
+		d.Log("Delete scaling policy %s", name)
+		if _, err := d.autoScaling.DeleteScalingPolicy(ctx, &applicationautoscaling.DeleteScalingPolicyInput{
+			PolicyName:        aws.String(name),
+			ResourceId:        resourceId,
+			ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+			ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+		}); err != nil {
+			return fmt.Errorf("failed to delete scaling policy %s: %w", name, err)
+		}
+	}
+	for _, sp := range plan.PutScalingPolicies {
+		d.Log("Put scaling policy %s", aws.ToString(sp.PolicyName))
+		if _, err := d.autoScaling.PutScalingPolicy(ctx, &applicationautoscaling.PutScalingPolicyInput{
+			PolicyName:                               sp.PolicyName,
+			PolicyType:                               sp.PolicyType,
+			StepScalingPolicyConfiguration:           sp.StepScalingPolicyConfiguration,
+			TargetTrackingScalingPolicyConfiguration: sp.TargetTrackingScalingPolicyConfiguration,
+			ResourceId:                               resourceId,
+			ServiceNamespace:                         aasTypes.ServiceNamespaceEcs,
+			ScalableDimension:                        aasTypes.ScalableDimensionECSServiceDesiredCount,
+		}); err != nil {
+			return fmt.Errorf("failed to put scaling policy %s: %w", aws.ToString(sp.PolicyName), err)
+		}
+	}
+	for _, name := range plan.DeleteScheduledActions {
+		d.Log("Delete scheduled action %s", name)
+		if _, err := d.autoScaling.DeleteScheduledAction(ctx, &applicationautoscaling.DeleteScheduledActionInput{
+			ScheduledActionName: aws.String(name),
+			ResourceId:          resourceId,
+			ServiceNamespace:    aasTypes.ServiceNamespaceEcs,
+			ScalableDimension:   aasTypes.ScalableDimensionECSServiceDesiredCount,
+		}); err != nil {
+			return fmt.Errorf("failed to delete scheduled action %s: %w", name, err)
+		}
+	}
+	for _, sa := range plan.PutScheduledActions {
+		d.Log("Put scheduled action %s", aws.ToString(sa.ScheduledActionName))
+		if _, err := d.autoScaling.PutScheduledAction(ctx, &applicationautoscaling.PutScheduledActionInput{
+			ScheduledActionName:  sa.ScheduledActionName,
+			Schedule:             sa.Schedule,
+			Timezone:             sa.Timezone,
+			StartTime:            sa.StartTime,
+			EndTime:              sa.EndTime,
+			ScalableTargetAction: sa.ScalableTargetAction,
+			ResourceId:           resourceId,
+			ServiceNamespace:     aasTypes.ServiceNamespaceEcs,
+			ScalableDimension:    aasTypes.ScalableDimensionECSServiceDesiredCount,
+		}); err != nil {
+			return fmt.Errorf("failed to put scheduled action %s: %w", aws.ToString(sa.ScheduledActionName), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package ecspresso_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func loadScalingDefinition(t *testing.T) *ecspresso.ScalingDefinition {
+	t.Helper()
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/scaling-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := app.LoadScalingDefinition(app.Config().ScalingDefinitionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sd
+}
+
+// remoteScalingDefinition returns a scaling definition as described by Application Auto Scaling,
+// which is equivalent to tests/scaling-def.json.
+func remoteScalingDefinition(t *testing.T) *ecspresso.ScalingDefinition {
+	sd := loadScalingDefinition(t)
+	sd.ScalableTarget.RoleARN = aws.String("arn:aws:iam::123456789012:role/aws-service-role/ecs.application-autoscaling.amazonaws.com/AWSServiceRoleForApplicationAutoScaling_ECSService")
+	sd.ScalableTarget.SuspendedState = &aasTypes.SuspendedState{
+		DynamicScalingInSuspended:  aws.Bool(false),
+		DynamicScalingOutSuspended: aws.Bool(false),
+		ScheduledScalingSuspended:  aws.Bool(false),
+	}
+	sd.ScalingPolicies[0].TargetTrackingScalingPolicyConfiguration.DisableScaleIn = aws.Bool(false)
+	// reverse order
+	sd.ScalingPolicies[0], sd.ScalingPolicies[1] = sd.ScalingPolicies[1], sd.ScalingPolicies[0]
+	return sd
+}
+
+func TestLoadScalingDefinition(t *testing.T) {
+	sd := loadScalingDefinition(t)
+	if aws.ToInt32(sd.ScalableTarget.MinCapacity) != 1 || aws.ToInt32(sd.ScalableTarget.MaxCapacity) != 10 {
+		t.Errorf("unexpected scalable target %#v", sd.ScalableTarget)
+	}
+	if len(sd.ScalingPolicies) != 2 {
+		t.Fatalf("unexpected scaling policies %d", len(sd.ScalingPolicies))
+	}
+	if sd.ScalingPolicies[1].PolicyType != aasTypes.PolicyTypeStepScaling {
+		t.Errorf("unexpected policy type %s", sd.ScalingPolicies[1].PolicyType)
+	}
+	if len(sd.ScheduledActions) != 1 || aws.ToString(sd.ScheduledActions[0].Timezone) != "Asia/Tokyo" {
+		t.Errorf("unexpected scheduled actions %#v", sd.ScheduledActions)
+	}
+}
+
+func TestNewScalingPlan(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(local, remote *ecspresso.ScalingDefinition)
+		expect func(local *ecspresso.ScalingDefinition) *ecspresso.ScalingPlan
+	}{
+		{
+			name:   "no changes",
+			modify: func(local, remote *ecspresso.ScalingDefinition) {},
+			expect: func(local *ecspresso.ScalingDefinition) *ecspresso.ScalingPlan {
+				return &ecspresso.ScalingPlan{}
+			},
+		},
+		{
+			name: "not registered",
+			modify: func(local, remote *ecspresso.ScalingDefinition) {
+				*remote = ecspresso.ScalingDefinition{}
+			},
+			expect: func(local *ecspresso.ScalingDefinition) *ecspresso.ScalingPlan {
+				return &ecspresso.ScalingPlan{
+					RegisterScalableTarget: local.ScalableTarget,
+					PutScalingPolicies:     local.ScalingPolicies,
+					PutScheduledActions:    local.ScheduledActions,
+				}
+			},
+		},
+		{
+			name: "update capacity and policy",
+			modify: func(local, remote *ecspresso.ScalingDefinition) {
+				local.ScalableTarget.MaxCapacity = aws.Int32(20)
+				local.ScalingPolicies[0].TargetTrackingScalingPolicyConfiguration.TargetValue = aws.Float64(50)
+			},
+			expect: func(local *ecspresso.ScalingDefinition) *ecspresso.ScalingPlan {
+				return &ecspresso.ScalingPlan{
+					RegisterScalableTarget: local.ScalableTarget,
+					PutScalingPolicies:     local.ScalingPolicies[:1],
+				}
+			},
+		},
+		{
+			name: "delete policy and scheduled action",
+			modify: func(local, remote *ecspresso.ScalingDefinition) {
+				local.ScalingPolicies = local.ScalingPolicies[:1]
+				local.ScheduledActions = nil
+			},
+			expect: func(local *ecspresso.ScalingDefinition) *ecspresso.ScalingPlan {
+				return &ecspresso.ScalingPlan{
+					DeleteScalingPolicies:  []string{"step-out"},
+					DeleteScheduledActions: []string{"night"},
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			local, remote := loadScalingDefinition(t), remoteScalingDefinition(t)
+			tc.modify(local, remote)
+			plan := ecspresso.NewScalingPlan(local, remote)
+			if diff := cmp.Diff(tc.expect(local), plan, cmp.AllowUnexported(aasTypes.SuspendedState{}, aasTypes.TargetTrackingScalingPolicyConfiguration{}, aasTypes.PredefinedMetricSpecification{}, aasTypes.StepScalingPolicyConfiguration{}, aasTypes.StepAdjustment{}, aasTypes.ScalableTargetAction{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
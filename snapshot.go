@@ -0,0 +1,182 @@
+package ecspresso
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	// serviceSnapshotTagKeyPrefix is a prefix of tag keys which hold a snapshot of the service definition.
+	// The snapshot is compressed and split into tags ecspresso:service-snapshot:0, ecspresso:service-snapshot:1, ...
+	serviceSnapshotTagKeyPrefix = reservedTagPrefix + "service-snapshot:"
+
+	maxServiceSnapshotTags = 10
+)
+
+// serviceSnapshot represents a service definition before a deployment.
+type serviceSnapshot struct {
+	TaskDefinition string          `json:"taskDefinition"`
+	Service        json.RawMessage `json:"service"`
+}
+
+func newServiceSnapshot(sv *Service) (*serviceSnapshot, error) {
+	svForDiff := ServiceDefinitionForDiff(sv)
+	// desired count and tags are not restored by rollback
+	svForDiff.DesiredCount = nil
+	svForDiff.Tags = nil
+	b, err := MarshalJSONForAPI(svForDiff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal service definition: %w", err)
+	}
+	return &serviceSnapshot{
+		TaskDefinition: arnToName(aws.ToString(sv.TaskDefinition)),
+		Service:        b,
+	}, nil
+}
+
+// ServiceDefinition returns a service definition in the snapshot.
+func (s *serviceSnapshot) ServiceDefinition() (*Service, error) {
+	var sv Service
+	if err := json.Unmarshal(s.Service, &sv); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal service snapshot: %w", err)
+	}
+	return &sv, nil
+}
+
+func encodeServiceSnapshot(s *serviceSnapshot) ([]types.Tag, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var tags []types.Tag
+	for i := 0; len(encoded) > 0; i++ {
+		n := maxTagValueLength
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		tags = append(tags, types.Tag{
+			Key:   aws.String(serviceSnapshotTagKeyPrefix + strconv.Itoa(i)),
+			Value: aws.String(encoded[:n]),
+		})
+		encoded = encoded[n:]
+	}
+	if len(tags) > maxServiceSnapshotTags {
+		return nil, fmt.Errorf("service snapshot is too large: requires %d tags (max %d)", len(tags), maxServiceSnapshotTags)
+	}
+	return tags, nil
+}
+
+func decodeServiceSnapshot(tags []types.Tag) (*serviceSnapshot, error) {
+	chunks := map[int]string{}
+	for _, t := range tags {
+		key := aws.ToString(t.Key)
+		if !strings.HasPrefix(key, serviceSnapshotTagKeyPrefix) {
+			continue
+		}
+		i, err := strconv.Atoi(strings.TrimPrefix(key, serviceSnapshotTagKeyPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid service snapshot tag key: %s", key)
+		}
+		chunks[i] = aws.ToString(t.Value)
+	}
+	if len(chunks) == 0 {
+		return nil, ErrNotFound("service snapshot is not found")
+	}
+	var b strings.Builder
+	for i := 0; i < len(chunks); i++ {
+		c, ok := chunks[i]
+		if !ok {
+			return nil, fmt.Errorf("service snapshot is broken: %s%d is missing", serviceSnapshotTagKeyPrefix, i)
+		}
+		b.WriteString(c)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode service snapshot: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress service snapshot: %w", err)
+	}
+	src, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress service snapshot: %w", err)
+	}
+	var s serviceSnapshot
+	if err := json.Unmarshal(src, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal service snapshot: %w", err)
+	}
+	return &s, nil
+}
+
+// saveServiceSnapshot saves the service definition before a deployment into the tags of the service.
+func (d *App) saveServiceSnapshot(ctx context.Context, sv *Service) error {
+	if long, _ := isLongArnFormat(aws.ToString(sv.ServiceArn)); !long {
+		d.Log("[WARNING] service %s is not a long arn format. service snapshot can not be saved", aws.ToString(sv.ServiceArn))
+		return nil
+	}
+	// tags of the previous snapshot must be copied before building a new snapshot
+	currentTags := make([]types.Tag, len(sv.Tags))
+	copy(currentTags, sv.Tags)
+	snapshot, err := newServiceSnapshot(sv)
+	if err != nil {
+		return err
+	}
+	tags, err := encodeServiceSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	d.Log("[DEBUG] saving service snapshot for %s in %d tags", snapshot.TaskDefinition, len(tags))
+	if _, err := d.ecs.TagResource(ctx, &ecs.TagResourceInput{
+		ResourceArn: sv.ServiceArn,
+		Tags:        tags,
+	}); err != nil {
+		return fmt.Errorf("failed to save service snapshot: %w", err)
+	}
+
+	// remove stale chunks of the previous snapshot
+	if staleKeys := staleServiceSnapshotKeys(currentTags, len(tags)); len(staleKeys) > 0 {
+		d.Log("[DEBUG] removing %d stale tags of the previous service snapshot", len(staleKeys))
+		if _, err := d.ecs.UntagResource(ctx, &ecs.UntagResourceInput{
+			ResourceArn: sv.ServiceArn,
+			TagKeys:     staleKeys,
+		}); err != nil {
+			return fmt.Errorf("failed to remove stale service snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// staleServiceSnapshotKeys returns keys of the chunks in tags which are not overwritten by a new snapshot of n chunks.
+func staleServiceSnapshotKeys(tags []types.Tag, n int) []string {
+	var keys []string
+	for _, t := range tags {
+		key := aws.ToString(t.Key)
+		if !strings.HasPrefix(key, serviceSnapshotTagKeyPrefix) {
+			continue
+		}
+		if i, err := strconv.Atoi(strings.TrimPrefix(key, serviceSnapshotTagKeyPrefix)); err != nil || i >= n {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,170 @@
+package ecspresso_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestServiceSnapshot(t *testing.T) {
+	sv := &ecspresso.Service{
+		Service: types.Service{
+			ServiceArn:                    aws.String("arn:aws:ecs:ap-northeast-1:123456789012:service/default/test"),
+			TaskDefinition:                aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:12"),
+			HealthCheckGracePeriodSeconds: aws.Int32(120),
+			LaunchType:                    types.LaunchTypeFargate,
+			NetworkConfiguration: &types.NetworkConfiguration{
+				AwsvpcConfiguration: &types.AwsVpcConfiguration{
+					Subnets:        []string{"subnet-2", "subnet-1"},
+					SecurityGroups: []string{"sg-1"},
+				},
+			},
+			Tags: []types.Tag{
+				{Key: aws.String("Env"), Value: aws.String("prod")},
+			},
+		},
+		DesiredCount: aws.Int32(3),
+	}
+	snapshot, err := ecspresso.NewServiceSnapshot(sv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := ecspresso.EncodeServiceSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tag := range tags {
+		if !strings.HasPrefix(aws.ToString(tag.Key), "ecspresso:service-snapshot:") {
+			t.Errorf("unexpected tag key: %s", aws.ToString(tag.Key))
+		}
+		if len(aws.ToString(tag.Value)) > 256 {
+			t.Errorf("tag value [%d] is too long: %d", i, len(aws.ToString(tag.Value)))
+		}
+	}
+
+	// tags are returned in random order
+	for i, j := 0, len(tags)-1; i < j; i, j = i+1, j-1 {
+		tags[i], tags[j] = tags[j], tags[i]
+	}
+	tags = append(tags, types.Tag{Key: aws.String("Env"), Value: aws.String("prod")})
+
+	decoded, err := ecspresso.DecodeServiceSnapshot(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TaskDefinition != "app:12" {
+		t.Errorf("unexpected task definition: %s", decoded.TaskDefinition)
+	}
+	restored, err := decoded.ServiceDefinition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DesiredCount != nil {
+		t.Errorf("desired count must not be restored: %d", *restored.DesiredCount)
+	}
+	if aws.ToInt32(restored.HealthCheckGracePeriodSeconds) != 120 {
+		t.Errorf("unexpected healthCheckGracePeriodSeconds: %v", restored.HealthCheckGracePeriodSeconds)
+	}
+	if diff := cmp.Diff(sv.NetworkConfiguration, restored.NetworkConfiguration, cmp.AllowUnexported(types.NetworkConfiguration{}, types.AwsVpcConfiguration{})); diff != "" {
+		t.Errorf("unexpected network configuration (-want +got):\n%s", diff)
+	}
+}
+
+func TestDecodeServiceSnapshotNotFound(t *testing.T) {
+	_, err := ecspresso.DecodeServiceSnapshot([]types.Tag{
+		{Key: aws.String("Env"), Value: aws.String("prod")},
+	})
+	var notFound ecspresso.ErrNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestServiceSnapshotOverwrite(t *testing.T) {
+	newService := func(subnets int) *ecspresso.Service {
+		sv := &ecspresso.Service{
+			Service: types.Service{
+				ServiceArn:     aws.String("arn:aws:ecs:ap-northeast-1:123456789012:service/default/test"),
+				TaskDefinition: aws.String(fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:%d", subnets)),
+				LaunchType:     types.LaunchTypeFargate,
+				NetworkConfiguration: &types.NetworkConfiguration{
+					AwsvpcConfiguration: &types.AwsVpcConfiguration{},
+				},
+			},
+		}
+		for i := 0; i < subnets; i++ {
+			h := sha256.Sum256([]byte(fmt.Sprint(i)))
+			sv.NetworkConfiguration.AwsvpcConfiguration.Subnets = append(
+				sv.NetworkConfiguration.AwsvpcConfiguration.Subnets,
+				"subnet-"+hex.EncodeToString(h[:])[:17],
+			)
+		}
+		return sv
+	}
+	// tags of the service on ECS
+	stored := map[string]string{"Env": "prod"}
+	save := func(sv *ecspresso.Service) int {
+		sv.Tags = nil
+		for k, v := range stored {
+			sv.Tags = append(sv.Tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		// as saveServiceSnapshot, copy the current tags before building a snapshot
+		current := append([]types.Tag{}, sv.Tags...)
+		snapshot, err := ecspresso.NewServiceSnapshot(sv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags, err := ecspresso.EncodeServiceSnapshot(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range tags {
+			stored[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		for _, key := range ecspresso.StaleServiceSnapshotKeys(current, len(tags)) {
+			delete(stored, key)
+		}
+		if len(sv.Tags) != len(current) {
+			t.Errorf("tags of the service must not be modified: %d != %d", len(sv.Tags), len(current))
+		}
+		return len(tags)
+	}
+
+	large := save(newService(60))
+	small := save(newService(1))
+	if large <= small {
+		t.Fatalf("the first snapshot must be larger than the second: %d <= %d", large, small)
+	}
+
+	var tags []types.Tag
+	for k, v := range stored {
+		tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return aws.ToString(tags[i].Key) < aws.ToString(tags[j].Key) })
+	if len(tags) != small+1 {
+		t.Errorf("stale chunks are not removed: %d tags", len(tags))
+	}
+	decoded, err := ecspresso.DecodeServiceSnapshot(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TaskDefinition != "app:1" {
+		t.Errorf("unexpected task definition: %s", decoded.TaskDefinition)
+	}
+	restored, err := decoded.ServiceDefinition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(restored.NetworkConfiguration.AwsvpcConfiguration.Subnets); n != 1 {
+		t.Errorf("unexpected subnets: %d", n)
+	}
+}
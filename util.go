@@ -32,6 +32,8 @@ func isLongArnFormat(a string) (bool, error) {
 }
 
 func (d *App) readDefinitionFile(path string) ([]byte, error) {
+	d.loader.mu.Lock()
+	defer d.loader.mu.Unlock()
 	switch filepath.Ext(path) {
 	case jsonnetExt:
 		jsonStr, err := d.loader.VM.EvaluateFile(path)
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,7 +19,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	cloudwatchlogsTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecrpublic"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
@@ -31,10 +36,14 @@ import (
 
 type verifier struct {
 	cwl            *cloudwatchlogs.Client
+	ec2            *ec2.Client
 	ssm            *ssm.Client
 	secretsmanager *secretsmanager.Client
 	ecr            *ecr.Client
+	ecrpublic      *ecrpublic.Client
+	ecrCredentials *ecrCredentials
 	s3             *s3.Client
+	registryCache  *registry.Cache
 	opt            *VerifyOption
 	isAssumed      bool
 }
@@ -46,15 +55,37 @@ func (v *verifier) IsAssumed() bool {
 func newVerifier(execCfg, appCfg *aws.Config, opt *VerifyOption) *verifier {
 	return &verifier{
 		cwl:            cloudwatchlogs.NewFromConfig(*execCfg),
+		ec2:            ec2.NewFromConfig(*appCfg),
 		ssm:            ssm.NewFromConfig(*execCfg),
 		secretsmanager: secretsmanager.NewFromConfig(*execCfg),
 		ecr:            ecr.NewFromConfig(*execCfg),
+		ecrpublic:      ecrpublic.NewFromConfig(*execCfg),
+		ecrCredentials: newECRCredentials(),
 		s3:             s3.NewFromConfig(*appCfg),
+		registryCache:  registry.NewCache(),
 		opt:            opt,
 		isAssumed:      execCfg != appCfg,
 	}
 }
 
+// repositoryCredentials gets credentials for a private registry from the secret of repositoryCredentials.
+func (v *verifier) repositoryCredentials(ctx context.Context, secretArn string) (user, password string, err error) {
+	res, err := v.secretsmanager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &secretArn,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get secret value of repositoryCredentials %s: %w", secretArn, err)
+	}
+	var cred struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(res.SecretString)), &cred); err != nil {
+		return "", "", fmt.Errorf("failed to parse secret string of repositoryCredentials %s: %w", secretArn, err)
+	}
+	return cred.Username, cred.Password, nil
+}
+
 func (v *verifier) existsSecretValue(ctx context.Context, from string) error {
 	if !v.opt.GetSecrets {
 		return ErrSkipVerify(fmt.Sprintf("get a secret value for %s", from))
@@ -164,113 +195,368 @@ func (d *App) newAssumedVerifier(ctx context.Context, cfg aws.Config, executionR
 
 // VerifyOption represents options for Verify()
 type VerifyOption struct {
-	GetSecrets bool `help:"get secrets from ParameterStore or SecretsManager" default:"true" negatable:""`
-	PutLogs    bool `help:"put logs to CloudWatchLogs" default:"true" negatable:""`
-	Cache      bool `help:"use cache" default:"true" negatable:""`
+	GetSecrets  bool   `help:"get secrets from ParameterStore or SecretsManager" default:"true" negatable:""`
+	PutLogs     bool   `help:"put logs to CloudWatchLogs" default:"true" negatable:""`
+	Cache       bool   `help:"use cache" default:"true" negatable:""`
+	Concurrency int    `help:"number of checks to run concurrently" default:"8"`
+	Output      string `help:"output format (text, json, junit)" default:"text" enum:"text,json,junit"`
+	FailFast    bool   `help:"skip remaining checks after a failure" default:"false"`
+	Offline     bool   `help:"run only static checks of the lint rules without AWS API calls" default:"false"`
 }
 
 type verifyResourceFunc func(context.Context) error
 
 // Verify verifies service / task definitions related resources are valid.
 func (d *App) Verify(ctx context.Context, opt VerifyOption) error {
-	initVerifyState(opt.Cache)
-
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 	if err != nil {
 		return err
 	}
-	d.verifier, err = d.newAssumedVerifier(ctx, d.config.awsv2Config, td.ExecutionRoleArn, &opt)
-	if err != nil {
-		return err
+	checks := []verifyCheck{
+		{name: "Lint", fn: d.verifyLint},
+	}
+	if !opt.Offline {
+		d.verifier, err = d.newAssumedVerifier(ctx, d.config.awsv2Config, td.ExecutionRoleArn, &opt)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, []verifyCheck{
+			{name: "TaskDefinition", fn: d.verifyTaskDefinition},
+			{name: "ServiceDefinition", fn: d.verifyServiceDefinition},
+			{name: "Cluster", fn: d.verifyCluster},
+		}...)
 	}
 
 	ctx, cancel := d.Start(ctx)
 	defer cancel()
 
 	d.Log("Starting verify")
-	resources := []struct {
-		name string
-		fn   verifyResourceFunc
-	}{
-		{name: "TaskDefinition", fn: d.verifyTaskDefinition},
-		{name: "ServiceDefinition", fn: d.verifyServiceDefinition},
-		{name: "Cluster", fn: d.verifyCluster},
-	}
-	for _, r := range resources {
-		if err := verifyResource(ctx, r.name, r.fn); err != nil {
-			return err
-		}
+	runner := newVerifyRunner(opt.Cache, opt.Concurrency)
+	runner.output = opt.Output
+	runner.failFast = opt.FailFast
+	if err := runner.Run(ctx, checks); err != nil {
+		return err
 	}
 	d.Log("Verify OK!")
 	return nil
 }
 
-var verifyState = struct {
-	cache verifyCache
-	level int
-}{
-	cache: nil,
-	level: 0,
+// verifyCheck is a named check of verification.
+type verifyCheck struct {
+	name string
+	fn   verifyResourceFunc
+}
+
+// verifyRunner runs a tree of checks concurrently with a bounded worker pool.
+// Checks added by verifyResource in a check become children of the check.
+type verifyRunner struct {
+	cache    *verifyCache
+	sem      chan struct{}
+	output   string
+	failFast bool
+	failed   int32 // set to 1 by atomic when a check failed
 }
 
-func initVerifyState(cache bool) {
+func newVerifyRunner(cache bool, concurrency int) *verifyRunner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	r := &verifyRunner{
+		sem: make(chan struct{}, concurrency),
+	}
 	if cache {
-		verifyState.cache = make(verifyCache, 100)
-	} else {
-		verifyState.cache = verifyCache(nil)
+		r.cache = newVerifyCache()
 	}
-	verifyState.level = 0
+	return r
 }
 
-type verifyCache map[string]error
+// Run runs checks and renders the results as an ordered tree.
+// All the checks run even if some of them fail. It returns the first error in the order of the tree.
+func (r *verifyRunner) Run(ctx context.Context, checks []verifyCheck) error {
+	nodes := make([]*verifyNode, 0, len(checks))
+	for _, c := range checks {
+		nodes = append(nodes, r.start(ctx, c.name, c.name, c.fn))
+	}
+	var firstErr error
+	switch r.output {
+	case "json", "junit":
+		var results []*VerifyResult
+		for _, n := range nodes {
+			rs, err := collectVerifyResults(n, nil)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			results = append(results, rs...)
+		}
+		if err := writeVerifyResults(os.Stdout, r.output, results); err != nil {
+			return err
+		}
+	default:
+		for _, n := range nodes {
+			if err := r.render(n, 1); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
 
-func (v verifyCache) Do(ctx context.Context, name string, fn verifyResourceFunc) (error, bool) {
-	if v == nil {
-		return fn(ctx), false
+// verifyNode is a check in the tree.
+type verifyNode struct {
+	name string
+	done chan struct{}
+
+	mu       sync.Mutex
+	children []*verifyNode
+
+	// err, warnings, cached and duration are available after done is closed
+	err      error
+	warnings []string
+	cached   bool
+	duration time.Duration
+}
+
+func (n *verifyNode) addWarning(w string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.warnings = append(n.warnings, w)
+}
+
+func (n *verifyNode) Warnings() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.warnings
+}
+
+func (n *verifyNode) addChild(c *verifyNode) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.children = append(n.children, c)
+}
+
+func (n *verifyNode) Children() []*verifyNode {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.children
+}
+
+// status returns the status of the node and the error to be reported.
+// A node whose children failed is also failed.
+func (n *verifyNode) status(childErr error) (string, error) {
+	verifyErr := n.err
+	if verifyErr == nil {
+		verifyErr = childErr
+	}
+	switch {
+	case verifyErr == nil:
+		return VerifyStatusOK, nil
+	case errors.As(verifyErr, &errSkipVerify) && childErr == nil:
+		return VerifyStatusSkip, verifyErr
+	default:
+		return VerifyStatusNG, verifyErr
+	}
+}
+
+// wait waits for the node and its descendants to complete.
+// It returns an error when the node or its descendants failed.
+func (n *verifyNode) wait() error {
+	<-n.done
+	var childErr error
+	for _, c := range n.Children() {
+		if err := c.wait(); err != nil && childErr == nil {
+			childErr = err
+		}
 	}
-	if err, ok := v[name]; ok {
-		return err, true
+	if status, verifyErr := n.status(childErr); status == VerifyStatusNG {
+		return fmt.Errorf("verify %s failed: %w", n.name, verifyErr)
 	}
-	err := fn(ctx)
-	v[name] = err
-	return err, false
+	return nil
 }
 
-func verifyResource(ctx context.Context, name string, verifyFunc func(context.Context) error) error {
-	verifyState.level++
-	defer func() { verifyState.level-- }()
-	indent := strings.Repeat("  ", verifyState.level)
+type verifyContextKey struct{}
+
+type verifyContext struct {
+	runner *verifyRunner
+	node   *verifyNode
+}
+
+// start starts a check. The result is cached by key, which identifies the check and may differ from the name to display.
+func (r *verifyRunner) start(ctx context.Context, name, key string, fn verifyResourceFunc) *verifyNode {
+	n := &verifyNode{
+		name: name,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(n.done)
+		ctx := context.WithValue(ctx, verifyContextKey{}, &verifyContext{runner: r, node: n})
+		// a slot of the pool is acquired in the cached function not to hold it while waiting for the same check
+		var ownErr error
+		run := func(ctx context.Context) error {
+			select {
+			case r.sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-r.sem }()
+			if r.failFast && atomic.LoadInt32(&r.failed) != 0 {
+				return ErrSkipVerify("skipped after a failure (--fail-fast)")
+			}
+			started := time.Now()
+			defer func() { n.duration = time.Since(started) }()
+			return fn(ctx)
+		}
+		// the result of the subtree is cached, because a cached node has no children
+		err, warnings, cached := r.cache.Do(ctx, key, func(ctx context.Context) error {
+			ownErr = run(ctx)
+			for _, c := range n.Children() {
+				if err := c.wait(); err != nil {
+					return err
+				}
+			}
+			return ownErr
+		}, n.Warnings)
+		n.cached = cached
+		if cached {
+			n.err = err
+			// warnings of the cached check are reported again
+			for _, w := range warnings {
+				n.addWarning(w)
+			}
+		} else {
+			n.err = ownErr
+		}
+		if n.err != nil && !errors.As(n.err, &errSkipVerify) {
+			atomic.StoreInt32(&r.failed, 1)
+		}
+	}()
+	return n
+}
+
+// render prints the node and its children in order, waiting for them to complete.
+func (r *verifyRunner) render(n *verifyNode, level int) error {
+	indent := strings.Repeat("  ", level)
 	print := func(f string, args ...interface{}) {
 		fmt.Printf(indent+f+"\n", args...)
 	}
-	print("%s", name)
+	print("%s", n.name)
+	<-n.done
+	var childErr error
+	for _, c := range n.Children() {
+		if err := r.render(c, level+1); err != nil && childErr == nil {
+			childErr = err
+		}
+	}
+
 	var cached string
-	verifyErr, hit := verifyState.cache.Do(ctx, name, verifyFunc)
-	if hit {
+	if n.cached {
 		cached = color.CyanString("(cached)")
 	}
-	if verifyErr != nil {
-		if errors.As(verifyErr, &errSkipVerify) {
-			print("--> [%s]%s %s", color.CyanString("SKIP"), cached, color.CyanString(verifyErr.Error()))
-			return nil
-		}
+	for _, w := range n.Warnings() {
+		print("--> [%s] %s", color.YellowString("WARNING"), color.YellowString(w))
+	}
+	switch status, verifyErr := n.status(childErr); status {
+	case VerifyStatusSkip:
+		print("--> [%s]%s %s", color.CyanString("SKIP"), cached, color.CyanString(verifyErr.Error()))
+		return nil
+	case VerifyStatusNG:
 		print("--> [%s]%s %s", color.RedString("NG"), cached, color.RedString(verifyErr.Error()))
-		return fmt.Errorf("verify %s failed: %w", name, verifyErr)
+		return fmt.Errorf("verify %s failed: %w", n.name, verifyErr)
+	default:
+		print("--> [%s]%s", color.GreenString("OK"), cached)
+		return nil
 	}
-	print("--> [%s]%s", color.GreenString("OK"), cached)
-	return nil
+}
+
+// verifyCache is a concurrency-safe cache of results of checks.
+// A nil cache runs checks without caching.
+type verifyCache struct {
+	mu      sync.Mutex
+	entries map[string]*verifyCacheEntry
+}
+
+type verifyCacheEntry struct {
+	done     chan struct{}
+	err      error
+	warnings []string
+}
+
+func newVerifyCache() *verifyCache {
+	return &verifyCache{entries: make(map[string]*verifyCacheEntry, 100)}
+}
+
+// Do runs fn once for the key. Concurrent calls for the same key wait for the first call.
+// warnings returns the warnings reported by fn, which are cached with the error.
+func (v *verifyCache) Do(ctx context.Context, key string, fn verifyResourceFunc, warnings func() []string) (error, []string, bool) {
+	if v == nil {
+		err := fn(ctx)
+		return err, warnings(), false
+	}
+	v.mu.Lock()
+	if e, ok := v.entries[key]; ok {
+		v.mu.Unlock()
+		select {
+		case <-e.done:
+			return e.err, e.warnings, true
+		case <-ctx.Done():
+			return ctx.Err(), nil, false
+		}
+	}
+	e := &verifyCacheEntry{done: make(chan struct{})}
+	v.entries[key] = e
+	v.mu.Unlock()
+
+	e.err = fn(ctx)
+	e.warnings = warnings()
+	close(e.done)
+	return e.err, e.warnings, false
+}
+
+// verifyWarning reports a warning of the running check in ctx.
+// Warnings do not fail the check, but are shown in the results.
+func verifyWarning(ctx context.Context, format string, args ...interface{}) {
+	vc, ok := ctx.Value(verifyContextKey{}).(*verifyContext)
+	if !ok {
+		panic("verifyWarning must be called in a check run by verifyRunner")
+	}
+	vc.node.addWarning(fmt.Sprintf(format, args...))
+}
+
+// verifyResource adds a check as a child of the running check in ctx.
+// The check runs concurrently and the result is rendered by the runner.
+func verifyResource(ctx context.Context, name string, verifyFunc verifyResourceFunc) {
+	verifyResourceWithKey(ctx, name, name, verifyFunc)
+}
+
+// verifyResourceWithKey adds a check as same as verifyResource, caching the result by key instead of name.
+// key must identify all the inputs of the check which are not shown in the name, e.g. credentials or a role.
+func verifyResourceWithKey(ctx context.Context, name, key string, verifyFunc verifyResourceFunc) {
+	vc, ok := ctx.Value(verifyContextKey{}).(*verifyContext)
+	if !ok {
+		panic("verifyResource must be called in a check run by verifyRunner")
+	}
+	vc.node.addChild(vc.runner.start(ctx, name, key, verifyFunc))
 }
 
 func (d *App) verifyCluster(ctx context.Context) error {
 	cluster := d.config.Cluster
 	out, err := d.ecs.DescribeClusters(ctx, &ecs.DescribeClustersInput{
 		Clusters: []string{cluster},
+		Include: []types.ClusterField{
+			types.ClusterFieldAttachments,
+			types.ClusterFieldSettings,
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to describe cluster %s: %w", cluster, err)
 	} else if len(out.Clusters) == 0 {
 		return ErrNotFound(fmt.Sprintf("cluster %s is not found", cluster))
 	}
+	if d.config.ServiceDefinitionPath != "" {
+		c := out.Clusters[0]
+		verifyResource(ctx, "Capacity", func(ctx context.Context) error {
+			return d.verifyCapacity(ctx, &c)
+		})
+	}
 	return nil
 }
 
@@ -295,11 +581,17 @@ func (d *App) verifyServiceDefinition(ctx context.Context) error {
 			)
 		}
 	}
+	if sv.NetworkConfiguration != nil && sv.NetworkConfiguration.AwsvpcConfiguration != nil {
+		verifyResource(ctx, "NetworkConfiguration", func(ctx context.Context) error {
+			return d.verifyNetworkConfiguration(ctx, sv, td)
+		})
+	}
 
 	// LB
 	for i, lb := range sv.LoadBalancers {
+		lb := lb
 		name := fmt.Sprintf("LoadBalancer[%d]", i)
-		err := verifyResource(ctx, name, func(context.Context) error {
+		verifyResource(ctx, name, func(ctx context.Context) error {
 			out, err := d.elbv2.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
 				TargetGroupArns: []string{*lb.TargetGroupArn},
 			})
@@ -329,10 +621,23 @@ func (d *App) verifyServiceDefinition(ctx context.Context) error {
 			}
 			return nil
 		})
-		if err != nil {
-			return err
-		}
 	}
+	// Service Connect
+	if scc := sv.ServiceConnectConfiguration; scc != nil && scc.Enabled {
+		verifyResource(ctx, "ServiceConnect", func(ctx context.Context) error {
+			return d.verifyServiceConnect(ctx, scc, td)
+		})
+	}
+
+	// Service discovery
+	for i, sr := range sv.ServiceRegistries {
+		sr := sr
+		name := fmt.Sprintf("ServiceRegistry[%d]", i)
+		verifyResource(ctx, name, func(ctx context.Context) error {
+			return d.verifyServiceRegistry(ctx, sr, td)
+		})
+	}
+
 	if len(sv.LoadBalancers) == 0 && sv.HealthCheckGracePeriodSeconds != nil {
 		return fmt.Errorf("service has no load balancers, but healthCheckGracePeriodSeconds is defined.")
 	}
@@ -348,70 +653,66 @@ func (d *App) verifyTaskDefinition(ctx context.Context) error {
 
 	if execRole := td.ExecutionRoleArn; execRole != nil {
 		name := fmt.Sprintf("ExecutionRole[%s]", *execRole)
-		err := verifyResource(ctx, name, func(ctx context.Context) error {
-			return d.verifyRole(ctx, *execRole)
+		verifyResource(ctx, name, func(ctx context.Context) error {
+			if err := d.verifyRole(ctx, *execRole); err != nil {
+				return err
+			}
+			role, err := arn.Parse(*execRole)
+			if err != nil {
+				return err
+			}
+			ps := executionRolePermissions(td, d.config.Region, role)
+			ps = append(ps, d.kmsKeyPermissions(ctx, ps, role)...)
+			d.verifyRolePermissions(ctx, *execRole, ps)
+			return nil
 		})
-		if err != nil {
-			return err
-		}
 	}
 	if taskRole := td.TaskRoleArn; taskRole != nil {
 		name := fmt.Sprintf("TaskRole[%s]", *taskRole)
-		err := verifyResource(ctx, name, func(ctx context.Context) error {
-			return d.verifyRole(ctx, *taskRole)
+		verifyResource(ctx, name, func(ctx context.Context) error {
+			if err := d.verifyRole(ctx, *taskRole); err != nil {
+				return err
+			}
+			if d.config.ServiceDefinitionPath == "" {
+				return nil
+			}
+			sv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+			if err != nil {
+				return err
+			}
+			d.verifyRolePermissions(ctx, *taskRole, taskRolePermissions(sv))
+			return nil
 		})
-		if err != nil {
-			return err
-		}
 	}
 
 	for _, c := range td.ContainerDefinitions {
+		c := c
 		name := fmt.Sprintf("ContainerDefinition[%s]", aws.ToString(c.Name))
-		err := verifyResource(ctx, name, func(ctx context.Context) error {
+		verifyResource(ctx, name, func(ctx context.Context) error {
 			return d.verifyContainer(ctx, &c, td)
 		})
-		if err != nil {
-			return err
-		}
 	}
 	return nil
 }
 
 var (
-	ecrImageURLRegex = regexp.MustCompile(`dkr\.ecr\..+.amazonaws\.com/.*`)
+	ecrImageURLRegex = regexp.MustCompile(`dkr\.ecr(?:-fips)?\..+.amazonaws\.com/.*`)
 )
 
-func (d *App) verifyECRImage(ctx context.Context, image string) error {
-	d.Log("[DEBUG] VERIFY ECR Image")
-	out, err := d.verifier.ecr.GetAuthorizationToken(
-		ctx,
-		&ecr.GetAuthorizationTokenInput{},
-	)
-	if err != nil {
-		return err
-	}
-	token := out.AuthorizationData[0].AuthorizationToken
-	return d.verifyRegistryImage(ctx, image, "AWS", aws.ToString(token))
-}
-
 func (d *App) verifyRegistryImage(ctx context.Context, image, user, password string) error {
-	rr := strings.SplitN(image, ":", 2)
-	image = rr[0]
-	var tag string
-	if len(rr) == 1 {
-		tag = "latest"
-	} else {
-		tag = rr[1]
-	}
-	d.Log("[DEBUG] image=%s tag=%s", image, tag)
+	name, tag := registry.ParseReference(image)
+	d.Log("[DEBUG] image=%s tag=%s", name, tag)
 
-	repo := registry.New(image, user, password)
+	repo := registry.New(name, user, password, registry.WithCache(d.verifier.registryCache))
 	ok, err := repo.HasImage(ctx, tag)
 	if err != nil {
 		return err
 	}
 	if !ok {
-		return fmt.Errorf("%s:%s is not found in Registry", image, tag)
+		return fmt.Errorf("%s is not found in Registry", image)
+	}
+	if dgst, err := repo.ResolveDigest(ctx, tag); err == nil {
+		d.Log("[DEBUG] image=%s digest=%s", image, dgst)
 	}
 
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
@@ -430,7 +731,7 @@ func (d *App) verifyRegistryImage(ctx context.Context, image, user, password str
 	}
 	ok, err = repo.HasPlatformImage(ctx, tag, arch, os)
 	if err != nil {
-		if errors.Is(err, registry.ErrDeprecatedManifest) || errors.Is(err, registry.ErrPullRateLimitExceeded) {
+		if errors.Is(err, registry.ErrPullRateLimitExceeded) {
 			return ErrSkipVerify(err.Error())
 		}
 		return err
@@ -438,7 +739,7 @@ func (d *App) verifyRegistryImage(ctx context.Context, image, user, password str
 	if ok {
 		return nil
 	}
-	return fmt.Errorf("%s:%s for arch=%s os=%s is not found in Registry", image, tag, arch, os)
+	return fmt.Errorf("%s for arch=%s os=%s is not found in Registry", image, arch, os)
 }
 
 func (d *App) isFargateService() (bool, error) {
@@ -490,50 +791,87 @@ func NormalizePlatform(p *types.RuntimePlatform, isFargate bool) (arch, os strin
 	return
 }
 
-func (d *App) verifyImage(ctx context.Context, image string) error {
+func (d *App) verifyImage(ctx context.Context, image string, rc *types.RepositoryCredentials) error {
 	if image == "" {
 		return errors.New("image is not defined")
 	}
 	if ecrImageURLRegex.MatchString(image) {
 		return d.verifyECRImage(ctx, image)
 	}
-	return d.verifyRegistryImage(ctx, image, "", "")
+	if isECRPublicImage(image) {
+		return d.verifyECRPublicImage(ctx, image)
+	}
+	user, password, err := d.registryCredentials(ctx, image, rc)
+	if err != nil {
+		return err
+	}
+	return d.verifyRegistryImage(ctx, image, user, password)
+}
+
+// registryCredentials returns credentials for the registry of the image.
+// repositoryCredentials of the container definition takes precedence over the config file of docker CLI.
+func (d *App) registryCredentials(ctx context.Context, image string, rc *types.RepositoryCredentials) (user, password string, err error) {
+	if rc != nil && aws.ToString(rc.CredentialsParameter) != "" {
+		if d.verifier.opt.GetSecrets {
+			return d.verifier.repositoryCredentials(ctx, *rc.CredentialsParameter)
+		}
+		d.Log("[DEBUG] skip getting repositoryCredentials %s", *rc.CredentialsParameter)
+	}
+	path := registry.DefaultDockerConfigPath()
+	dc, err := registry.LoadDockerConfig(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.Log("[WARNING] failed to load docker config: %s", err)
+		}
+		return "", "", nil
+	}
+	host := registry.New(image, "", "").Host()
+	user, password, err = dc.Credentials(host)
+	if err != nil {
+		d.Log("[WARNING] failed to get credentials for %s from docker config: %s", host, err)
+		return "", "", nil
+	}
+	if user != "" {
+		d.Log("[DEBUG] use credentials for %s from docker config", host)
+	}
+	return user, password, nil
 }
 
 func (d *App) verifyContainer(ctx context.Context, c *types.ContainerDefinition, td *ecs.RegisterTaskDefinitionInput) error {
 	image := aws.ToString(c.Image)
 	name := fmt.Sprintf("Image[%s]", image)
-	err := verifyResource(ctx, name, func(ctx context.Context) error {
-		return d.verifyImage(ctx, image)
+	var credentials string
+	if rc := c.RepositoryCredentials; rc != nil {
+		credentials = aws.ToString(rc.CredentialsParameter)
+	}
+	verifyResourceWithKey(ctx, name, name+" "+credentials, func(ctx context.Context) error {
+		return d.verifyImage(ctx, image, c.RepositoryCredentials)
 	})
-	if err != nil {
-		return err
+	if d.config.ImageScan != nil && ecrImageURLRegex.MatchString(image) {
+		name := fmt.Sprintf("ImageScan[%s]", image)
+		verifyResourceWithKey(ctx, name, name+" "+aws.ToString(c.Name), func(ctx context.Context) error {
+			return d.verifyImageScan(ctx, d.ecr, aws.ToString(c.Name), image)
+		})
 	}
 	for _, secret := range c.Secrets {
+		secret := secret
 		name := fmt.Sprintf("Secret %s[%s]", *secret.Name, *secret.ValueFrom)
-		err := verifyResource(ctx, name, func(ctx context.Context) error {
+		verifyResource(ctx, name, func(ctx context.Context) error {
 			return d.verifier.existsSecretValue(ctx, *secret.ValueFrom)
 		})
-		if err != nil {
-			return err
-		}
 	}
 	if c.LogConfiguration != nil && c.LogConfiguration.LogDriver == types.LogDriverAwslogs {
 		name := fmt.Sprintf("LogConfiguration[%s]", map2str(c.LogConfiguration.Options))
-		err := verifyResource(ctx, name, func(ctx context.Context) error {
+		verifyResource(ctx, name, func(ctx context.Context) error {
 			return d.verifyLogConfiguration(ctx, c)
 		})
-		if err != nil {
-			return err
-		}
 	}
 	for _, envFile := range c.EnvironmentFiles {
+		envFile := envFile
 		name := fmt.Sprintf("EnvironmentFile[%s %s]", envFile.Type, aws.ToString(envFile.Value))
-		if err := verifyResource(ctx, name, func(ctx context.Context) error {
+		verifyResource(ctx, name, func(ctx context.Context) error {
 			return d.verifier.existsEnvironmentFile(ctx, envFile)
-		}); err != nil {
-			return err
-		}
+		})
 	}
 
 	if td.NetworkMode == types.NetworkModeAwsvpc {
@@ -543,7 +881,6 @@ func (d *App) verifyContainer(ctx context.Context, c *types.ContainerDefinition,
 			}
 		}
 	}
-
 	return nil
 }
 
@@ -0,0 +1,155 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// validateLaunchConfiguration validates the launch type and the capacity provider strategy of the service
+// against the requiresCompatibilities of the task definition.
+func validateLaunchConfiguration(sv *Service, td *TaskDefinitionInput, defaultStrategy []types.CapacityProviderStrategyItem) error {
+	if sv.LaunchType != "" && len(sv.CapacityProviderStrategy) > 0 {
+		return fmt.Errorf("launchType and capacityProviderStrategy can not be specified at the same time")
+	}
+	if len(td.RequiresCompatibilities) == 0 {
+		return nil
+	}
+REQUIRED:
+	for _, r := range launchCompatibilities(sv, defaultStrategy) {
+		for _, c := range td.RequiresCompatibilities {
+			if c == r {
+				continue REQUIRED
+			}
+		}
+		return fmt.Errorf("requiresCompatibilities %v of the task definition does not contain %s", td.RequiresCompatibilities, r)
+	}
+	return nil
+}
+
+// launchCompatibilities returns the compatibilities required by the launch type or the capacity providers of the service.
+// The default capacity provider strategy of the cluster is used when neither of them is specified.
+// It returns nil when the launch configuration is not specified at all.
+func launchCompatibilities(sv *Service, defaultStrategy []types.CapacityProviderStrategyItem) []types.Compatibility {
+	if sv.LaunchType != "" {
+		return []types.Compatibility{types.Compatibility(sv.LaunchType)}
+	}
+	strategy := sv.CapacityProviderStrategy
+	if len(strategy) == 0 {
+		strategy = defaultStrategy
+	}
+	var compat []types.Compatibility
+	for _, s := range strategy {
+		switch aws.ToString(s.CapacityProvider) {
+		case "FARGATE", "FARGATE_SPOT":
+			compat = append(compat, types.CompatibilityFargate)
+		default:
+			compat = append(compat, types.CompatibilityEc2)
+		}
+	}
+	return compat
+}
+
+// usesContainerInstances reports whether tasks of the service may be placed on container instances of the cluster.
+// It is true unless all the tasks run on Fargate, because ECS launches tasks on EC2 when the launch configuration is not specified.
+func usesContainerInstances(sv *Service, defaultStrategy []types.CapacityProviderStrategyItem) bool {
+	compat := launchCompatibilities(sv, defaultStrategy)
+	if len(compat) == 0 {
+		return true
+	}
+	for _, c := range compat {
+		if c != types.CompatibilityFargate {
+			return true
+		}
+	}
+	return false
+}
+
+// missingCapacityProviders returns capacity providers in the strategy which are not associated with the cluster.
+func missingCapacityProviders(strategy []types.CapacityProviderStrategyItem, associated []string) []string {
+	var missing []string
+STRATEGY:
+	for _, s := range strategy {
+		name := aws.ToString(s.CapacityProvider)
+		for _, a := range associated {
+			if a == name {
+				continue STRATEGY
+			}
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// placementConstraintsFilter builds a cluster query language expression from memberOf placement constraints.
+func placementConstraintsFilter(sv *Service, td *TaskDefinitionInput) string {
+	var exprs []string
+	for _, c := range sv.PlacementConstraints {
+		if c.Type == types.PlacementConstraintTypeMemberOf && aws.ToString(c.Expression) != "" {
+			exprs = append(exprs, aws.ToString(c.Expression))
+		}
+	}
+	for _, c := range td.PlacementConstraints {
+		if c.Type == types.TaskDefinitionPlacementConstraintTypeMemberOf && aws.ToString(c.Expression) != "" {
+			exprs = append(exprs, aws.ToString(c.Expression))
+		}
+	}
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	for i, e := range exprs {
+		exprs[i] = "(" + e + ")"
+	}
+	return strings.Join(exprs, " and ")
+}
+
+func (d *App) verifyCapacity(ctx context.Context, cluster *types.Cluster) error {
+	sv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+	if err != nil {
+		return err
+	}
+	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+	if err != nil {
+		return err
+	}
+	if err := validateLaunchConfiguration(sv, td, cluster.DefaultCapacityProviderStrategy); err != nil {
+		return err
+	}
+	if missing := missingCapacityProviders(sv.CapacityProviderStrategy, cluster.CapacityProviders); len(missing) > 0 {
+		return fmt.Errorf(
+			"capacity providers %s are not associated with cluster %s",
+			strings.Join(missing, ","), aws.ToString(cluster.ClusterName),
+		)
+	}
+	if !usesContainerInstances(sv, cluster.DefaultCapacityProviderStrategy) {
+		return nil
+	}
+	filter := placementConstraintsFilter(sv, td)
+	verifyResource(ctx, "ContainerInstances", func(ctx context.Context) error {
+		in := &ecs.ListContainerInstancesInput{
+			Cluster: cluster.ClusterArn,
+			Status:  types.ContainerInstanceStatusActive,
+		}
+		if filter != "" {
+			in.Filter = aws.String(filter)
+		}
+		out, err := d.ecs.ListContainerInstances(ctx, in)
+		if err != nil {
+			return fmt.Errorf("failed to list container instances: %w", err)
+		}
+		// no container instances may be fine, e.g. managed scaling of capacity providers scales out from zero
+		if len(out.ContainerInstanceArns) == 0 {
+			if filter != "" {
+				verifyWarning(ctx, "no active container instances match the placement constraints: %s", filter)
+			} else {
+				verifyWarning(ctx, "no active container instances are registered in cluster %s", aws.ToString(cluster.ClusterName))
+			}
+		}
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,140 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func testStrategy(names ...string) []types.CapacityProviderStrategyItem {
+	var s []types.CapacityProviderStrategyItem
+	for _, n := range names {
+		s = append(s, types.CapacityProviderStrategyItem{CapacityProvider: aws.String(n), Weight: 1})
+	}
+	return s
+}
+
+func testService(launchType types.LaunchType, s []types.CapacityProviderStrategyItem) *ecspresso.Service {
+	return &ecspresso.Service{
+		Service: types.Service{LaunchType: launchType, CapacityProviderStrategy: s},
+	}
+}
+
+func TestValidateLaunchConfiguration(t *testing.T) {
+	fargate := []types.Compatibility{types.CompatibilityFargate}
+	ec2 := []types.Compatibility{types.CompatibilityEc2}
+	testCases := []struct {
+		name            string
+		sv              *ecspresso.Service
+		compat          []types.Compatibility
+		defaultStrategy []types.CapacityProviderStrategyItem
+		isErr           bool
+	}{
+		{name: "fargate", sv: &ecspresso.Service{}, compat: fargate},
+		{name: "no compatibilities", sv: &ecspresso.Service{}},
+		{
+			name:   "both launch type and strategy",
+			sv:     testService(types.LaunchTypeFargate, testStrategy("FARGATE")),
+			compat: fargate,
+			isErr:  true,
+		},
+		{
+			name:   "fargate launch type",
+			sv:     testService(types.LaunchTypeFargate, nil),
+			compat: fargate,
+		},
+		{
+			name:   "ec2 launch type with fargate task definition",
+			sv:     testService(types.LaunchTypeEc2, nil),
+			compat: fargate,
+			isErr:  true,
+		},
+		{
+			name:   "fargate spot strategy",
+			sv:     testService("", testStrategy("FARGATE", "FARGATE_SPOT")),
+			compat: fargate,
+		},
+		{
+			name:   "ec2 capacity provider with fargate task definition",
+			sv:     testService("", testStrategy("FARGATE", "my-asg")),
+			compat: fargate,
+			isErr:  true,
+		},
+		{
+			name:            "cluster default strategy",
+			sv:              &ecspresso.Service{},
+			compat:          ec2,
+			defaultStrategy: testStrategy("FARGATE"),
+			isErr:           true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			td := &ecspresso.TaskDefinitionInput{RequiresCompatibilities: tc.compat}
+			err := ecspresso.ValidateLaunchConfiguration(tc.sv, td, tc.defaultStrategy)
+			if tc.isErr && err == nil {
+				t.Error("expected error but got nil")
+			} else if !tc.isErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestMissingCapacityProviders(t *testing.T) {
+	missing := ecspresso.MissingCapacityProviders(
+		testStrategy("FARGATE", "FARGATE_SPOT", "my-asg"),
+		[]string{"FARGATE", "my-asg"},
+	)
+	if diff := cmp.Diff([]string{"FARGATE_SPOT"}, missing); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestUsesContainerInstances(t *testing.T) {
+	testCases := []struct {
+		name            string
+		sv              *ecspresso.Service
+		defaultStrategy []types.CapacityProviderStrategyItem
+		expected        bool
+	}{
+		{name: "ec2 launch type", sv: testService(types.LaunchTypeEc2, nil), expected: true},
+		{name: "external launch type", sv: testService(types.LaunchTypeExternal, nil), expected: true},
+		{name: "fargate launch type", sv: testService(types.LaunchTypeFargate, nil), expected: false},
+		{name: "fargate strategy", sv: testService("", testStrategy("FARGATE", "FARGATE_SPOT")), expected: false},
+		{name: "ec2 capacity provider", sv: testService("", testStrategy("FARGATE", "my-asg")), expected: true},
+		{name: "not specified", sv: &ecspresso.Service{}, expected: true},
+		{name: "cluster default fargate", sv: &ecspresso.Service{}, defaultStrategy: testStrategy("FARGATE"), expected: false},
+		{name: "cluster default ec2", sv: &ecspresso.Service{}, defaultStrategy: testStrategy("my-asg"), expected: true},
+	}
+	for _, tc := range testCases {
+		if got := ecspresso.UsesContainerInstances(tc.sv, tc.defaultStrategy); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestPlacementConstraintsFilter(t *testing.T) {
+	sv := testService(types.LaunchTypeEc2, nil)
+	td := &ecspresso.TaskDefinitionInput{}
+	if f := ecspresso.PlacementConstraintsFilter(sv, td); f != "" {
+		t.Errorf("unexpected filter %q", f)
+	}
+	sv.PlacementConstraints = []types.PlacementConstraint{
+		{Type: types.PlacementConstraintTypeDistinctInstance},
+		{Type: types.PlacementConstraintTypeMemberOf, Expression: aws.String("attribute:ecs.instance-type =~ t3.*")},
+	}
+	if f := ecspresso.PlacementConstraintsFilter(sv, td); f != "attribute:ecs.instance-type =~ t3.*" {
+		t.Errorf("unexpected filter %q", f)
+	}
+	td.PlacementConstraints = []types.TaskDefinitionPlacementConstraint{
+		{Type: types.TaskDefinitionPlacementConstraintTypeMemberOf, Expression: aws.String("attribute:ecs.availability-zone in [ap-northeast-1a]")},
+	}
+	expected := "(attribute:ecs.instance-type =~ t3.*) and (attribute:ecs.availability-zone in [ap-northeast-1a])"
+	if f := ecspresso.PlacementConstraintsFilter(sv, td); f != expected {
+		t.Errorf("unexpected filter %q", f)
+	}
+}
@@ -0,0 +1,221 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecrpublic"
+	"github.com/kayac/ecspresso/v2/registry"
+)
+
+const (
+	ecrPublicHost = "public.ecr.aws"
+	// ecrPublicRegion is the only region that provides the API of ECR Public.
+	ecrPublicRegion = "us-east-1"
+)
+
+var ecrRegistryHostRegex = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// ecrRegistry represents a registry of ECR private repositories.
+type ecrRegistry struct {
+	ID     string
+	Region string
+}
+
+func (r ecrRegistry) String() string {
+	return r.ID + "@" + r.Region
+}
+
+// parseECRRegistry parses an account ID and a region from a host of ECR private registry.
+func parseECRRegistry(host string) (ecrRegistry, bool) {
+	m := ecrRegistryHostRegex.FindStringSubmatch(host)
+	if m == nil {
+		return ecrRegistry{}, false
+	}
+	return ecrRegistry{ID: m[1], Region: m[2]}, true
+}
+
+func isECRPublicImage(image string) bool {
+	return strings.HasPrefix(image, ecrPublicHost+"/")
+}
+
+// ecrCredentials caches authorization tokens and pull through cache rules of ECR in a run of verify.
+// The lock is not held while calling APIs, so calls for different keys don't wait for each other.
+type ecrCredentials struct {
+	mu      sync.Mutex
+	entries map[string]*ecrCredentialsEntry
+}
+
+type ecrCredentialsEntry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newECRCredentials() *ecrCredentials {
+	return &ecrCredentials{
+		entries: map[string]*ecrCredentialsEntry{},
+	}
+}
+
+// do calls fn once for the key. Concurrent calls for the same key wait for the first call.
+// Errors are not cached, so the next call after a failure calls fn again.
+func (c *ecrCredentials) do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-e.done:
+			return e.value, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	e := &ecrCredentialsEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.value, e.err = fn()
+	if e.err != nil {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.value, e.err
+}
+
+// ecrAuthorizationToken returns an authorization token for the ECR private registry.
+// A token is valid for all registries which the credentials can access in the region, so tokens are cached by region.
+func (v *verifier) ecrAuthorizationToken(ctx context.Context, reg ecrRegistry) (string, error) {
+	token, err := v.ecrCredentials.do(ctx, "token/"+reg.Region, func() (interface{}, error) {
+		out, err := v.ecr.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{}, func(o *ecr.Options) {
+			o.Region = reg.Region
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get authorization token of ECR in %s: %w", reg.Region, err)
+		}
+		if len(out.AuthorizationData) == 0 {
+			return nil, fmt.Errorf("no authorization data of ECR in %s", reg.Region)
+		}
+		return aws.ToString(out.AuthorizationData[0].AuthorizationToken), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token.(string), nil
+}
+
+// ecrPublicAuthorizationToken returns an authorization token for ECR Public.
+func (v *verifier) ecrPublicAuthorizationToken(ctx context.Context) (string, error) {
+	token, err := v.ecrCredentials.do(ctx, "token/"+ecrPublicHost, func() (interface{}, error) {
+		out, err := v.ecrpublic.GetAuthorizationToken(ctx, &ecrpublic.GetAuthorizationTokenInput{}, func(o *ecrpublic.Options) {
+			o.Region = ecrPublicRegion
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get authorization token of ECR Public: %w", err)
+		}
+		if out.AuthorizationData == nil {
+			return nil, errors.New("no authorization data of ECR Public")
+		}
+		return aws.ToString(out.AuthorizationData.AuthorizationToken), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token.(string), nil
+}
+
+// pullThroughCacheRules returns pull through cache rules of the ECR private registry.
+func (v *verifier) pullThroughCacheRules(ctx context.Context, reg ecrRegistry) ([]ecrTypes.PullThroughCacheRule, error) {
+	rules, err := v.ecrCredentials.do(ctx, "rules/"+reg.String(), func() (interface{}, error) {
+		var rules []ecrTypes.PullThroughCacheRule
+		p := ecr.NewDescribePullThroughCacheRulesPaginator(v.ecr, &ecr.DescribePullThroughCacheRulesInput{
+			RegistryId: aws.String(reg.ID),
+		})
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx, func(o *ecr.Options) {
+				o.Region = reg.Region
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe pull through cache rules of %s: %w", reg, err)
+			}
+			rules = append(rules, out.PullThroughCacheRules...)
+		}
+		return rules, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rules.([]ecrTypes.PullThroughCacheRule), nil
+}
+
+// pullThroughCacheUpstreamImage returns the upstream image of the image in a pull through cache repository.
+func pullThroughCacheUpstreamImage(rules []ecrTypes.PullThroughCacheRule, image string) (string, bool) {
+	name, ref := registry.ParseReference(image)
+	_, repo, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", false
+	}
+	for _, rule := range rules {
+		prefix := aws.ToString(rule.EcrRepositoryPrefix) + "/"
+		if !strings.HasPrefix(repo, prefix) {
+			continue
+		}
+		upstream := strings.TrimSuffix(aws.ToString(rule.UpstreamRegistryUrl), "/") + "/" + strings.TrimPrefix(repo, prefix)
+		if strings.HasPrefix(ref, "sha256:") {
+			return upstream + "@" + ref, true
+		}
+		return upstream + ":" + ref, true
+	}
+	return "", false
+}
+
+func (d *App) verifyECRImage(ctx context.Context, image string) error {
+	d.Log("[DEBUG] VERIFY ECR Image")
+	name, _ := registry.ParseReference(image)
+	reg, ok := parseECRRegistry(registry.New(name, "", "").Host())
+	if !ok {
+		return fmt.Errorf("%s is not an image of ECR private registry", image)
+	}
+	token, err := d.verifier.ecrAuthorizationToken(ctx, reg)
+	if err != nil {
+		return err
+	}
+	err = d.verifyRegistryImage(ctx, image, "AWS", token)
+	if !errors.Is(err, registry.ErrNotFound) {
+		return err
+	}
+
+	// the image may not be pulled through the cache yet
+	rules, rerr := d.verifier.pullThroughCacheRules(ctx, reg)
+	if rerr != nil {
+		d.Log("[DEBUG] %s", rerr)
+		return err
+	}
+	upstream, ok := pullThroughCacheUpstreamImage(rules, image)
+	if !ok {
+		return err
+	}
+	d.Log("[INFO] %s is not cached in the pull through cache repository yet. verifying the upstream image %s", image, upstream)
+	return d.verifyImage(ctx, upstream, nil)
+}
+
+func (d *App) verifyECRPublicImage(ctx context.Context, image string) error {
+	d.Log("[DEBUG] VERIFY ECR Public Image")
+	token, err := d.verifier.ecrPublicAuthorizationToken(ctx)
+	if err != nil {
+		// ECR Public allows anonymous pulls with lower rate limits
+		d.Log("[DEBUG] %s. verifying the image anonymously", err)
+		return d.verifyRegistryImage(ctx, image, "", "")
+	}
+	// the token is encoded as "AWS:password" like ECR private registries
+	return d.verifyRegistryImage(ctx, image, "AWS", token)
+}
@@ -0,0 +1,105 @@
+package ecspresso_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParseECRRegistry(t *testing.T) {
+	testCases := []struct {
+		host string
+		reg  ecspresso.ECRRegistry
+		ok   bool
+	}{
+		{host: "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com", reg: ecspresso.ECRRegistry{ID: "123456789012", Region: "ap-northeast-1"}, ok: true},
+		{host: "210987654321.dkr.ecr.us-east-1.amazonaws.com", reg: ecspresso.ECRRegistry{ID: "210987654321", Region: "us-east-1"}, ok: true},
+		{host: "123456789012.dkr.ecr-fips.us-west-2.amazonaws.com", reg: ecspresso.ECRRegistry{ID: "123456789012", Region: "us-west-2"}, ok: true},
+		{host: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", reg: ecspresso.ECRRegistry{ID: "123456789012", Region: "cn-north-1"}, ok: true},
+		{host: "public.ecr.aws"},
+		{host: "ghcr.io"},
+	}
+	for _, tc := range testCases {
+		reg, ok := ecspresso.ParseECRRegistry(tc.host)
+		if ok != tc.ok || reg != tc.reg {
+			t.Errorf("%s: unexpected %#v %v", tc.host, reg, ok)
+		}
+	}
+}
+
+func TestPullThroughCacheUpstreamImage(t *testing.T) {
+	rules := []ecrTypes.PullThroughCacheRule{
+		{EcrRepositoryPrefix: aws.String("ecr-public"), UpstreamRegistryUrl: aws.String("public.ecr.aws")},
+		{EcrRepositoryPrefix: aws.String("docker-hub"), UpstreamRegistryUrl: aws.String("registry-1.docker.io")},
+	}
+	const host = "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com"
+	testCases := []struct {
+		image    string
+		upstream string
+		ok       bool
+	}{
+		{image: host + "/ecr-public/nginx/nginx:1.25", upstream: "public.ecr.aws/nginx/nginx:1.25", ok: true},
+		{image: host + "/docker-hub/library/debian", upstream: "registry-1.docker.io/library/debian:latest", ok: true},
+		{image: host + "/docker-hub/library/debian@sha256:abcd", upstream: "registry-1.docker.io/library/debian@sha256:abcd", ok: true},
+		{image: host + "/docker-hubx/app:v1"},
+		{image: host + "/app:v1"},
+	}
+	for _, tc := range testCases {
+		upstream, ok := ecspresso.PullThroughCacheUpstreamImage(rules, tc.image)
+		if ok != tc.ok || upstream != tc.upstream {
+			t.Errorf("%s: unexpected %s %v", tc.image, upstream, ok)
+		}
+	}
+}
+
+func TestECRCredentials(t *testing.T) {
+	ctx := context.Background()
+	c := ecspresso.NewECRCredentials()
+
+	// a slow call for a key does not block calls for the other keys
+	blocked := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Do(ctx, "token/us-east-1", func() (interface{}, error) {
+			<-blocked
+			return "slow", nil
+		})
+	}()
+	var calls int32
+	for i := 0; i < 3; i++ {
+		v, err := c.Do(ctx, "token/ap-northeast-1", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return "token", nil
+		})
+		if err != nil || v.(string) != "token" {
+			t.Errorf("unexpected result %v %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("a call for the same key must be cached, but called %d times", calls)
+	}
+	close(blocked)
+	wg.Wait()
+
+	// errors are not cached
+	calls = 0
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do(ctx, "rules/error", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, errors.New("denied")
+		}); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("a failed call must not be cached, but called %d times", calls)
+	}
+}
@@ -0,0 +1,199 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/smithy-go"
+)
+
+// rolePermission represents actions which a role must be allowed on a resource.
+type rolePermission struct {
+	Resource string
+	Actions  []string
+}
+
+// rolePermissions is an ordered set of permissions grouped by resource.
+type rolePermissions []*rolePermission
+
+func (ps rolePermissions) add(resource string, actions ...string) rolePermissions {
+	for _, p := range ps {
+		if p.Resource != resource {
+			continue
+		}
+	ACTIONS:
+		for _, a := range actions {
+			for _, pa := range p.Actions {
+				if pa == a {
+					continue ACTIONS
+				}
+			}
+			p.Actions = append(p.Actions, a)
+		}
+		return ps
+	}
+	return append(ps, &rolePermission{Resource: resource, Actions: actions})
+}
+
+var ecrImageRepositoryRegex = regexp.MustCompile(`^(\d+)\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?/([^:@]+)`)
+
+// executionRolePermissions returns permissions which the execution role requires to start tasks of the task definition.
+// Resources whose account is not determined by the definition are regarded as in the same account of the role.
+func executionRolePermissions(td *TaskDefinitionInput, region string, role arn.ARN) rolePermissions {
+	var ps rolePermissions
+	for _, c := range td.ContainerDefinitions {
+		if m := ecrImageRepositoryRegex.FindStringSubmatch(aws.ToString(c.Image)); m != nil {
+			account, repoRegion, repo := m[1], m[2], m[3]
+			ps = ps.add("*", "ecr:GetAuthorizationToken")
+			ps = ps.add(
+				fmt.Sprintf("arn:%s:ecr:%s:%s:repository/%s", role.Partition, repoRegion, account, repo),
+				"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer",
+			)
+		}
+		for _, s := range c.Secrets {
+			from := aws.ToString(s.ValueFrom)
+			switch {
+			case strings.HasPrefix(from, "arn:") && strings.Contains(from, ":secretsmanager:"):
+				// truncate json-key, version-stage and version-id
+				parts := strings.Split(from, ":")
+				if len(parts) > 7 {
+					parts = parts[:7]
+				}
+				ps = ps.add(strings.Join(parts, ":"), "secretsmanager:GetSecretValue")
+			case strings.HasPrefix(from, "arn:"):
+				ps = ps.add(from, "ssm:GetParameters")
+			default:
+				ps = ps.add(
+					fmt.Sprintf("arn:%s:ssm:%s:%s:parameter/%s", role.Partition, region, role.AccountID, strings.TrimPrefix(from, "/")),
+					"ssm:GetParameters",
+				)
+			}
+		}
+		if lc := c.LogConfiguration; lc != nil && lc.LogDriver == types.LogDriverAwslogs {
+			group := lc.Options["awslogs-group"]
+			logRegion := lc.Options["awslogs-region"]
+			if logRegion == "" {
+				logRegion = region
+			}
+			if group != "" {
+				groupArn := fmt.Sprintf("arn:%s:logs:%s:%s:log-group:%s", role.Partition, logRegion, role.AccountID, group)
+				if lc.Options["awslogs-create-group"] == "true" {
+					ps = ps.add(groupArn+":*", "logs:CreateLogGroup")
+				}
+				ps = ps.add(groupArn+":log-stream:*", "logs:CreateLogStream", "logs:PutLogEvents")
+			}
+		}
+		for _, f := range c.EnvironmentFiles {
+			if f.Type != types.EnvironmentFileTypeS3 {
+				continue
+			}
+			a, err := arn.Parse(aws.ToString(f.Value))
+			if err != nil {
+				continue
+			}
+			bucket, _, _ := strings.Cut(a.Resource, "/")
+			ps = ps.add(aws.ToString(f.Value), "s3:GetObject")
+			ps = ps.add(fmt.Sprintf("arn:%s:s3:::%s", a.Partition, bucket), "s3:GetBucketLocation")
+		}
+	}
+	return ps
+}
+
+// taskRolePermissions returns permissions which the task role requires by the service definition.
+func taskRolePermissions(sv *Service) rolePermissions {
+	var ps rolePermissions
+	if sv != nil && sv.EnableExecuteCommand {
+		ps = ps.add("*",
+			"ssmmessages:CreateControlChannel",
+			"ssmmessages:CreateDataChannel",
+			"ssmmessages:OpenControlChannel",
+			"ssmmessages:OpenDataChannel",
+		)
+	}
+	return ps
+}
+
+// kmsKeyPermissions returns permissions to decrypt the secrets encrypted by customer managed keys.
+// Without getting secrets, the keys of the secrets are not described and the checks of them are skipped.
+func (d *App) kmsKeyPermissions(ctx context.Context, ps rolePermissions, role arn.ARN) rolePermissions {
+	var kps rolePermissions
+	for _, p := range ps {
+		if !strings.Contains(p.Resource, ":secretsmanager:") {
+			continue
+		}
+		if !d.verifier.opt.GetSecrets {
+			resource := p.Resource
+			verifyResource(ctx, fmt.Sprintf("KMSKey[%s]", resource), func(_ context.Context) error {
+				return ErrSkipVerify(fmt.Sprintf("describe a secret for the KMS key of %s", resource))
+			})
+			continue
+		}
+		out, err := d.verifier.secretsmanager.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+			SecretId: aws.String(p.Resource),
+		})
+		if err != nil {
+			d.Log("[DEBUG] failed to describe secret %s: %s", p.Resource, err)
+			continue
+		}
+		keyID := aws.ToString(out.KmsKeyId)
+		switch {
+		case keyID == "", strings.HasPrefix(keyID, "alias/"), strings.Contains(keyID, ":alias/"):
+			// AWS managed key or an alias which can not be evaluated by simulation
+			continue
+		case strings.HasPrefix(keyID, "arn:"):
+			kps = kps.add(keyID, "kms:Decrypt")
+		default:
+			a, _ := arn.Parse(p.Resource)
+			kps = kps.add(fmt.Sprintf("arn:%s:kms:%s:%s:key/%s", a.Partition, a.Region, a.AccountID, keyID), "kms:Decrypt")
+		}
+	}
+	return kps
+}
+
+// verifyRolePermissions adds checks of the permissions of the role by IAM policy simulation.
+func (d *App) verifyRolePermissions(ctx context.Context, roleArn string, ps rolePermissions) {
+	for _, p := range ps {
+		p := p
+		name := fmt.Sprintf("Permission[%s %s]", strings.Join(p.Actions, ","), p.Resource)
+		verifyResourceWithKey(ctx, name, name+" "+roleArn, func(ctx context.Context) error {
+			return d.simulateRolePermission(ctx, roleArn, p)
+		})
+	}
+}
+
+func (d *App) simulateRolePermission(ctx context.Context, roleArn string, p *rolePermission) error {
+	in := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(roleArn),
+		ActionNames:     p.Actions,
+	}
+	if p.Resource != "*" {
+		in.ResourceArns = []string{p.Resource}
+	}
+	out, err := d.iam.SimulatePrincipalPolicy(ctx, in)
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) && ae.ErrorCode() == "AccessDenied" {
+			return ErrSkipVerify(fmt.Sprintf("simulating policies is not allowed: %s", ae.ErrorMessage()))
+		}
+		return fmt.Errorf("failed to simulate policies of %s: %w", roleArn, err)
+	}
+	var denied []string
+	for _, r := range out.EvaluationResults {
+		if r.EvalDecision != iamTypes.PolicyEvaluationDecisionTypeAllowed {
+			denied = append(denied, fmt.Sprintf("%s(%s)", aws.ToString(r.EvalActionName), r.EvalDecision))
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("%s is not allowed to %s on %s", roleArn, strings.Join(denied, ", "), p.Resource)
+	}
+	return nil
+}
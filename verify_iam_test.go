@@ -0,0 +1,152 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestExecutionRolePermissions(t *testing.T) {
+	role, _ := arn.Parse("arn:aws:iam::123456789012:role/ecsTaskExecutionRole")
+	td := &ecspresso.TaskDefinitionInput{
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:  aws.String("app"),
+				Image: aws.String("123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app:v1"),
+				Secrets: []types.Secret{
+					{Name: aws.String("A"), ValueFrom: aws.String("/app/a")},
+					{Name: aws.String("B"), ValueFrom: aws.String("arn:aws:ssm:us-east-1:999999999999:parameter/app/b")},
+					{Name: aws.String("C"), ValueFrom: aws.String("arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:app-AbCdEf:password::")},
+					{Name: aws.String("D"), ValueFrom: aws.String("arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:app-AbCdEf:username::")},
+				},
+				LogConfiguration: &types.LogConfiguration{
+					LogDriver: types.LogDriverAwslogs,
+					Options: map[string]string{
+						"awslogs-group":        "/ecs/app",
+						"awslogs-region":       "ap-northeast-1",
+						"awslogs-create-group": "true",
+					},
+				},
+				EnvironmentFiles: []types.EnvironmentFile{
+					{Type: types.EnvironmentFileTypeS3, Value: aws.String("arn:aws:s3:::mybucket/app.env")},
+				},
+			},
+			{
+				Name:  aws.String("sidecar"),
+				Image: aws.String("123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/sidecar@sha256:0123"),
+			},
+			{
+				Name:  aws.String("nginx"),
+				Image: aws.String("nginx:latest"),
+			},
+		},
+	}
+	expected := ecspresso.RolePermissions{
+		{Resource: "*", Actions: []string{"ecr:GetAuthorizationToken"}},
+		{Resource: "arn:aws:ecr:ap-northeast-1:123456789012:repository/app", Actions: []string{"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}},
+		{Resource: "arn:aws:ssm:ap-northeast-1:123456789012:parameter/app/a", Actions: []string{"ssm:GetParameters"}},
+		{Resource: "arn:aws:ssm:us-east-1:999999999999:parameter/app/b", Actions: []string{"ssm:GetParameters"}},
+		{Resource: "arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:app-AbCdEf", Actions: []string{"secretsmanager:GetSecretValue"}},
+		{Resource: "arn:aws:logs:ap-northeast-1:123456789012:log-group:/ecs/app:*", Actions: []string{"logs:CreateLogGroup"}},
+		{Resource: "arn:aws:logs:ap-northeast-1:123456789012:log-group:/ecs/app:log-stream:*", Actions: []string{"logs:CreateLogStream", "logs:PutLogEvents"}},
+		{Resource: "arn:aws:s3:::mybucket/app.env", Actions: []string{"s3:GetObject"}},
+		{Resource: "arn:aws:s3:::mybucket", Actions: []string{"s3:GetBucketLocation"}},
+		{Resource: "arn:aws:ecr:ap-northeast-1:123456789012:repository/sidecar", Actions: []string{"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}},
+	}
+	ps := ecspresso.ExecutionRolePermissions(td, "ap-northeast-1", role)
+	if diff := cmp.Diff(expected, ps); diff != "" {
+		t.Errorf("unexpected permissions (-want +got):\n%s", diff)
+	}
+}
+
+func TestTaskRolePermissions(t *testing.T) {
+	sv := &ecspresso.Service{}
+	if ps := ecspresso.TaskRolePermissions(sv); len(ps) != 0 {
+		t.Errorf("unexpected permissions %v", ps)
+	}
+	sv.EnableExecuteCommand = true
+	ps := ecspresso.TaskRolePermissions(sv)
+	if len(ps) != 1 || ps[0].Resource != "*" || len(ps[0].Actions) != 4 {
+		t.Errorf("unexpected permissions for execute command %v", ps)
+	}
+}
+
+// describeSecretTestMiddleware describes secrets as encrypted by a customer managed key.
+type describeSecretTestMiddleware struct {
+	calls int32
+}
+
+func (m *describeSecretTestMiddleware) apply(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("test",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			if _, ok := in.Parameters.(*secretsmanager.DescribeSecretInput); ok {
+				atomic.AddInt32(&m.calls, 1)
+				out := &secretsmanager.DescribeSecretOutput{KmsKeyId: aws.String("arn:aws:kms:ap-northeast-1:123456789012:key/abcd")}
+				return middleware.InitializeOutput{Result: out}, middleware.Metadata{}, nil
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+}
+
+func TestKMSKeyPermissions(t *testing.T) {
+	ctx := context.Background()
+	role, _ := arn.Parse("arn:aws:iam::123456789012:role/ecsTaskExecutionRole")
+	secret := "arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:app-AbCdEf"
+	ps := ecspresso.RolePermissions{
+		{Resource: secret, Actions: []string{"secretsmanager:GetSecretValue"}},
+	}
+	for _, getSecrets := range []bool{true, false} {
+		m := &describeSecretTestMiddleware{}
+		ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+			config.WithRegion("ap-northeast-1"),
+			config.WithAPIOptions([]func(*middleware.Stack) error{m.apply}),
+		})
+		app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/run-without-sv.yaml"})
+		ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kps ecspresso.RolePermissions
+		runner := ecspresso.NewVerifyRunner(false, 1)
+		runner.SetOutput("json")
+		out := extractStdout(t, func() {
+			runner.RunCheck(ctx, "ExecutionRole", func(ctx context.Context) error {
+				kps = app.KMSKeyPermissions(ctx, getSecrets, ps, role)
+				return nil
+			})
+		})
+		var results []*ecspresso.VerifyResult
+		if err := json.Unmarshal(out, &results); err != nil {
+			t.Fatal(err, string(out))
+		}
+		if getSecrets {
+			expected := ecspresso.RolePermissions{
+				{Resource: "arn:aws:kms:ap-northeast-1:123456789012:key/abcd", Actions: []string{"kms:Decrypt"}},
+			}
+			if diff := cmp.Diff(expected, kps); diff != "" {
+				t.Errorf("unexpected permissions (-want +got):\n%s", diff)
+			}
+			continue
+		}
+		// secrets are not described by --no-get-secrets
+		if m.calls != 0 {
+			t.Errorf("DescribeSecret must not be called, but called %d times", m.calls)
+		}
+		if len(kps) != 0 {
+			t.Errorf("unexpected permissions %v", kps)
+		}
+		if len(results) != 2 || results[1].Status != "SKIP" {
+			t.Errorf("the check of the KMS key must be skipped: %s", string(out))
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+const (
+	DefaultImageScanSeverity    = "HIGH"
+	DefaultImageScanWaitTimeout = 5 * time.Minute
+
+	imageScanPollInterval = 5 * time.Second
+)
+
+// imageScanSeverities are severities of findings in descending order.
+// UNTRIAGED is a severity of enhanced scanning.
+var imageScanSeverities = []string{
+	string(ecrTypes.FindingSeverityCritical),
+	string(ecrTypes.FindingSeverityHigh),
+	string(ecrTypes.FindingSeverityMedium),
+	string(ecrTypes.FindingSeverityLow),
+	string(ecrTypes.FindingSeverityInformational),
+	string(ecrTypes.FindingSeverityUndefined),
+	"UNTRIAGED",
+}
+
+func imageScanSeverityRank(s string) int {
+	for i, v := range imageScanSeverities {
+		if v == s {
+			return i
+		}
+	}
+	return len(imageScanSeverities)
+}
+
+var errImageScanNotFound = errors.New("image scan is not found")
+
+// ConfigImageScan represents a configuration of gating on ECR image scan findings.
+type ConfigImageScan struct {
+	// Severity is the lowest severity of findings which fail. Default is HIGH.
+	Severity string `yaml:"severity,omitempty" json:"severity,omitempty"`
+	// WaitTimeout is a duration to wait for in-progress scans. Default is 5m.
+	WaitTimeout *Duration `yaml:"wait_timeout,omitempty" json:"wait_timeout,omitempty"`
+	// FailOnDeploy fails deploy before registering the task definition when findings exist.
+	FailOnDeploy bool `yaml:"fail_on_deploy,omitempty" json:"fail_on_deploy,omitempty"`
+	// Ignore is a list of vulnerability IDs to be ignored.
+	Ignore []*ConfigImageScanIgnore `yaml:"ignore,omitempty" json:"ignore,omitempty"`
+}
+
+// ConfigImageScanIgnore represents a vulnerability ID to be ignored until the expiry date.
+type ConfigImageScanIgnore struct {
+	ID      string `yaml:"id" json:"id"`
+	Expires string `yaml:"expires,omitempty" json:"expires,omitempty"` // YYYY-MM-DD
+	Reason  string `yaml:"reason,omitempty" json:"reason,omitempty"`
+
+	expires time.Time
+}
+
+func (c *ConfigImageScan) validate() error {
+	if c.Severity == "" {
+		c.Severity = DefaultImageScanSeverity
+	}
+	c.Severity = strings.ToUpper(c.Severity)
+	if imageScanSeverityRank(c.Severity) == len(imageScanSeverities) {
+		return fmt.Errorf("invalid severity %s", c.Severity)
+	}
+	if c.WaitTimeout == nil {
+		c.WaitTimeout = &Duration{Duration: DefaultImageScanWaitTimeout}
+	}
+	for _, ig := range c.Ignore {
+		if ig.ID == "" {
+			return fmt.Errorf("id is required for ignore")
+		}
+		if ig.Expires == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", ig.Expires)
+		if err != nil {
+			return fmt.Errorf("invalid expires %s of %s: %w", ig.Expires, ig.ID, err)
+		}
+		// expires at the end of the day
+		ig.expires = t.Add(24 * time.Hour)
+	}
+	return nil
+}
+
+// ignored reports whether the vulnerability ID is ignored at now.
+func (c *ConfigImageScan) ignored(id string, now time.Time) bool {
+	for _, ig := range c.Ignore {
+		if ig.ID != id {
+			continue
+		}
+		if ig.expires.IsZero() || now.Before(ig.expires) {
+			return true
+		}
+	}
+	return false
+}
+
+// ecrImage represents an image in an ECR private repository.
+type ecrImage struct {
+	RegistryID string
+	Region     string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func parseECRImage(image string) (*ecrImage, bool) {
+	m := ecrImageRepositoryRegex.FindStringSubmatch(image)
+	if m == nil {
+		return nil, false
+	}
+	img := &ecrImage{RegistryID: m[1], Region: m[2], Repository: m[3]}
+	rest := image[len(m[0]):]
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		img.Digest = rest[1:]
+	case strings.HasPrefix(rest, ":"):
+		img.Tag = rest[1:]
+	default:
+		img.Tag = "latest"
+	}
+	return img, true
+}
+
+func (img *ecrImage) describeImageScanFindingsInput() *ecr.DescribeImageScanFindingsInput {
+	in := &ecr.DescribeImageScanFindingsInput{
+		RegistryId:     aws.String(img.RegistryID),
+		RepositoryName: aws.String(img.Repository),
+		ImageId:        &ecrTypes.ImageIdentifier{},
+	}
+	if img.Digest != "" {
+		in.ImageId.ImageDigest = aws.String(img.Digest)
+	} else {
+		in.ImageId.ImageTag = aws.String(img.Tag)
+	}
+	return in
+}
+
+// imageScanFinding is a finding of basic or enhanced scanning.
+type imageScanFinding struct {
+	ID       string
+	Severity string
+}
+
+func imageScanFindingsOf(out *ecr.DescribeImageScanFindingsOutput) []imageScanFinding {
+	var findings []imageScanFinding
+	if out.ImageScanFindings == nil {
+		return findings
+	}
+	for _, f := range out.ImageScanFindings.Findings {
+		findings = append(findings, imageScanFinding{ID: aws.ToString(f.Name), Severity: string(f.Severity)})
+	}
+	for _, f := range out.ImageScanFindings.EnhancedFindings {
+		id := aws.ToString(f.Title)
+		if f.PackageVulnerabilityDetails != nil && f.PackageVulnerabilityDetails.VulnerabilityId != nil {
+			id = *f.PackageVulnerabilityDetails.VulnerabilityId
+		}
+		findings = append(findings, imageScanFinding{ID: id, Severity: aws.ToString(f.Severity)})
+	}
+	return findings
+}
+
+// fetchImageScanFindings fetches all findings of the image, waiting for in-progress scans until the timeout.
+func fetchImageScanFindings(ctx context.Context, client ecr.DescribeImageScanFindingsAPIClient, in *ecr.DescribeImageScanFindingsInput, timeout, interval time.Duration, optFns ...func(*ecr.Options)) ([]imageScanFinding, error) {
+	deadline := time.Now().Add(timeout)
+	var out *ecr.DescribeImageScanFindingsOutput
+	for {
+		var err error
+		out, err = client.DescribeImageScanFindings(ctx, in, optFns...)
+		if err != nil {
+			var nf *ecrTypes.ScanNotFoundException
+			if errors.As(err, &nf) {
+				return nil, errImageScanNotFound
+			}
+			return nil, fmt.Errorf("failed to describe image scan findings: %w", err)
+		}
+		var status ecrTypes.ScanStatus
+		var description string
+		if out.ImageScanStatus != nil {
+			status = out.ImageScanStatus.Status
+			description = aws.ToString(out.ImageScanStatus.Description)
+		}
+		switch status {
+		case ecrTypes.ScanStatusComplete, ecrTypes.ScanStatusActive:
+		case ecrTypes.ScanStatusInProgress, ecrTypes.ScanStatusPending:
+			if time.Now().Add(interval).After(deadline) {
+				return nil, fmt.Errorf("timed out waiting for the image scan to complete")
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("image scan status is %s: %s", status, description)
+		}
+		break
+	}
+
+	findings := imageScanFindingsOf(out)
+	for out.NextToken != nil {
+		next := *in
+		next.NextToken = out.NextToken
+		var err error
+		out, err = client.DescribeImageScanFindings(ctx, &next, optFns...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe image scan findings: %w", err)
+		}
+		findings = append(findings, imageScanFindingsOf(out)...)
+	}
+	return findings, nil
+}
+
+// imageScanResult is a result of evaluating findings of an image.
+type imageScanResult struct {
+	Counts   map[string]int
+	Ignored  int
+	Blocking []imageScanFinding
+	Below    []imageScanFinding
+}
+
+// evaluateImageScanFindings counts findings by severity and collects findings at or above the severity of the config.
+func evaluateImageScanFindings(findings []imageScanFinding, conf *ConfigImageScan, now time.Time) *imageScanResult {
+	r := &imageScanResult{Counts: map[string]int{}}
+	threshold := imageScanSeverityRank(conf.Severity)
+	for _, f := range findings {
+		if conf.ignored(f.ID, now) {
+			r.Ignored++
+			continue
+		}
+		r.Counts[f.Severity]++
+		if imageScanSeverityRank(f.Severity) <= threshold {
+			r.Blocking = append(r.Blocking, f)
+		} else {
+			r.Below = append(r.Below, f)
+		}
+	}
+	for _, fs := range [][]imageScanFinding{r.Blocking, r.Below} {
+		sort.SliceStable(fs, func(i, j int) bool {
+			return imageScanSeverityRank(fs[i].Severity) < imageScanSeverityRank(fs[j].Severity)
+		})
+	}
+	return r
+}
+
+func (r *imageScanResult) summary() string {
+	var s []string
+	for _, sev := range imageScanSeverities {
+		if n := r.Counts[sev]; n > 0 {
+			s = append(s, fmt.Sprintf("%s=%d", sev, n))
+		}
+	}
+	if len(s) == 0 {
+		s = append(s, "no findings")
+	}
+	if r.Ignored > 0 {
+		s = append(s, fmt.Sprintf("(ignored %d)", r.Ignored))
+	}
+	return strings.Join(s, " ")
+}
+
+func (r *imageScanResult) err(severity string) error {
+	if len(r.Blocking) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d findings at or above %s: %s", len(r.Blocking), severity, formatImageScanFindings(r.Blocking))
+}
+
+// warning returns a message of findings below the severity, which do not fail the scan. It returns "" when no findings are below.
+func (r *imageScanResult) warning(severity string) string {
+	if len(r.Below) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d findings below %s: %s", len(r.Below), severity, formatImageScanFindings(r.Below))
+}
+
+func formatImageScanFindings(fs []imageScanFinding) string {
+	const max = 10
+	var ids []string
+	for i, f := range fs {
+		if i == max {
+			ids = append(ids, fmt.Sprintf("and %d more", len(fs)-max))
+			break
+		}
+		ids = append(ids, fmt.Sprintf("%s(%s)", f.ID, f.Severity))
+	}
+	return strings.Join(ids, ", ")
+}
+
+// scanImage fetches and evaluates findings of the ECR image, and prints a summary.
+func (d *App) scanImage(ctx context.Context, client ecr.DescribeImageScanFindingsAPIClient, container, image string) (*imageScanResult, error) {
+	conf := d.config.ImageScan
+	img, ok := parseECRImage(image)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an image of ECR private repository", image)
+	}
+	findings, err := fetchImageScanFindings(
+		ctx, client, img.describeImageScanFindingsInput(), conf.WaitTimeout.Duration, imageScanPollInterval,
+		func(o *ecr.Options) { o.Region = img.Region },
+	)
+	if err != nil {
+		return nil, err
+	}
+	r := evaluateImageScanFindings(findings, conf, time.Now())
+	d.Log("[INFO] image scan findings of container %s %s: %s", container, image, r.summary())
+	return r, nil
+}
+
+func (d *App) verifyImageScan(ctx context.Context, client ecr.DescribeImageScanFindingsAPIClient, container, image string) error {
+	r, err := d.scanImage(ctx, client, container, image)
+	if errors.Is(err, errImageScanNotFound) {
+		return ErrSkipVerify(fmt.Sprintf("%s: %s", err, image))
+	} else if err != nil {
+		return err
+	}
+	// findings below the severity are recorded in the results of verify
+	if w := r.warning(d.config.ImageScan.Severity); w != "" {
+		verifyWarning(ctx, "%s", w)
+	}
+	return r.err(d.config.ImageScan.Severity)
+}
+
+// checkImageScanFindings returns an error when findings of ECR images in the task definition exist
+// and fail_on_deploy is enabled by the config.
+func (d *App) checkImageScanFindings(ctx context.Context, td *TaskDefinitionInput) error {
+	conf := d.config.ImageScan
+	if conf == nil || !conf.FailOnDeploy {
+		return nil
+	}
+	for _, c := range td.ContainerDefinitions {
+		image := aws.ToString(c.Image)
+		if !ecrImageURLRegex.MatchString(image) {
+			continue
+		}
+		r, err := d.scanImage(ctx, d.ecr, aws.ToString(c.Name), image)
+		if errors.Is(err, errImageScanNotFound) {
+			d.Log("[WARNING] %s: %s", err, image)
+			continue
+		} else if err == nil {
+			err = r.err(conf.Severity)
+		}
+		if err != nil {
+			return fmt.Errorf("image scan of container %s failed: %w", aws.ToString(c.Name), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParseECRImage(t *testing.T) {
+	testCases := []struct {
+		image    string
+		ok       bool
+		registry string
+		region   string
+		repo     string
+		tag      string
+		digest   string
+	}{
+		{image: "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app:v1", ok: true, registry: "123456789012", region: "ap-northeast-1", repo: "app", tag: "v1"},
+		{image: "123456789012.dkr.ecr.us-east-1.amazonaws.com/org/app", ok: true, registry: "123456789012", region: "us-east-1", repo: "org/app", tag: "latest"},
+		{image: "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app@sha256:0123", ok: true, registry: "123456789012", region: "ap-northeast-1", repo: "app", digest: "sha256:0123"},
+		{image: "nginx:latest"},
+		{image: "public.ecr.aws/nginx/nginx:latest"},
+	}
+	for _, tc := range testCases {
+		img, ok := ecspresso.ParseECRImage(tc.image)
+		if ok != tc.ok {
+			t.Errorf("unexpected ok %v for %s", ok, tc.image)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if img.RegistryID != tc.registry || img.Region != tc.region || img.Repository != tc.repo || img.Tag != tc.tag || img.Digest != tc.digest {
+			t.Errorf("unexpected image %#v for %s", img, tc.image)
+		}
+	}
+}
+
+func TestEvaluateImageScanFindings(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	conf := &ecspresso.ConfigImageScan{
+		Ignore: []*ecspresso.ConfigImageScanIgnore{
+			{ID: "CVE-2023-0001", Expires: "2026-06-01"},
+			{ID: "CVE-2023-0002", Expires: "2026-05-31"},
+			{ID: "CVE-2023-0003"},
+		},
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Severity != ecspresso.DefaultImageScanSeverity {
+		t.Errorf("unexpected default severity %s", conf.Severity)
+	}
+	findings := []ecspresso.ImageScanFinding{
+		{ID: "CVE-2023-0001", Severity: "CRITICAL"}, // ignored until the end of the day
+		{ID: "CVE-2023-0002", Severity: "HIGH"},     // ignore expired
+		{ID: "CVE-2023-0003", Severity: "CRITICAL"}, // ignored forever
+		{ID: "CVE-2023-0004", Severity: "MEDIUM"},
+		{ID: "CVE-2023-0005", Severity: "CRITICAL"},
+		{ID: "CVE-2023-0006", Severity: "UNTRIAGED"},
+	}
+	r := ecspresso.EvaluateImageScanFindings(findings, conf, now)
+	expected := []ecspresso.ImageScanFinding{
+		{ID: "CVE-2023-0005", Severity: "CRITICAL"},
+		{ID: "CVE-2023-0002", Severity: "HIGH"},
+	}
+	if diff := cmp.Diff(expected, r.Blocking); diff != "" {
+		t.Error(diff)
+	}
+	if s := r.Summary(); s != "CRITICAL=1 HIGH=1 MEDIUM=1 UNTRIAGED=1 (ignored 2)" {
+		t.Errorf("unexpected summary %s", s)
+	}
+	if w := r.Warning(conf.Severity); w != "2 findings below HIGH: CVE-2023-0004(MEDIUM), CVE-2023-0006(UNTRIAGED)" {
+		t.Errorf("unexpected warning %s", w)
+	}
+
+	for _, c := range []*ecspresso.ConfigImageScan{
+		{Severity: "SEVERE"},
+		{Ignore: []*ecspresso.ConfigImageScanIgnore{{ID: "CVE-2023-0001", Expires: "2026/06/01"}}},
+		{Ignore: []*ecspresso.ConfigImageScanIgnore{{Expires: "2026-06-01"}}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error for %#v", c)
+		}
+	}
+}
+
+type mockImageScanClient struct {
+	outputs []*ecr.DescribeImageScanFindingsOutput
+	err     error
+	calls   int
+}
+
+func (m *mockImageScanClient) DescribeImageScanFindings(ctx context.Context, in *ecr.DescribeImageScanFindingsInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImageScanFindingsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	out := m.outputs[m.calls]
+	m.calls++
+	return out, nil
+}
+
+func scanOutput(status ecrTypes.ScanStatus, next *string, findings ...string) *ecr.DescribeImageScanFindingsOutput {
+	out := &ecr.DescribeImageScanFindingsOutput{
+		ImageScanStatus:   &ecrTypes.ImageScanStatus{Status: status},
+		ImageScanFindings: &ecrTypes.ImageScanFindings{},
+		NextToken:         next,
+	}
+	for _, f := range findings {
+		out.ImageScanFindings.Findings = append(out.ImageScanFindings.Findings, ecrTypes.ImageScanFinding{
+			Name:     aws.String(f),
+			Severity: ecrTypes.FindingSeverityHigh,
+		})
+	}
+	return out
+}
+
+func TestFetchImageScanFindings(t *testing.T) {
+	ctx := context.Background()
+	image := "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app:v1"
+
+	client := &mockImageScanClient{
+		outputs: []*ecr.DescribeImageScanFindingsOutput{
+			scanOutput(ecrTypes.ScanStatusInProgress, nil),
+			scanOutput(ecrTypes.ScanStatusComplete, aws.String("next"), "CVE-2023-0001"),
+			scanOutput(ecrTypes.ScanStatusComplete, nil, "CVE-2023-0002"),
+		},
+	}
+	findings, err := ecspresso.FetchImageScanFindings(ctx, client, image, time.Second, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || client.calls != 3 {
+		t.Errorf("unexpected findings %v calls %d", findings, client.calls)
+	}
+
+	enhanced := &ecr.DescribeImageScanFindingsOutput{
+		ImageScanStatus: &ecrTypes.ImageScanStatus{Status: ecrTypes.ScanStatusActive},
+		ImageScanFindings: &ecrTypes.ImageScanFindings{
+			EnhancedFindings: []ecrTypes.EnhancedImageScanFinding{
+				{
+					Severity:                    aws.String("CRITICAL"),
+					PackageVulnerabilityDetails: &ecrTypes.PackageVulnerabilityDetails{VulnerabilityId: aws.String("CVE-2023-0003")},
+				},
+			},
+		},
+	}
+	findings, err = ecspresso.FetchImageScanFindings(ctx, &mockImageScanClient{outputs: []*ecr.DescribeImageScanFindingsOutput{enhanced}}, image, time.Second, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]ecspresso.ImageScanFinding{{ID: "CVE-2023-0003", Severity: "CRITICAL"}}, findings); diff != "" {
+		t.Error(diff)
+	}
+
+	inProgress := &mockImageScanClient{
+		outputs: []*ecr.DescribeImageScanFindingsOutput{
+			scanOutput(ecrTypes.ScanStatusInProgress, nil),
+			scanOutput(ecrTypes.ScanStatusInProgress, nil),
+			scanOutput(ecrTypes.ScanStatusInProgress, nil),
+		},
+	}
+	if _, err := ecspresso.FetchImageScanFindings(ctx, inProgress, image, 20*time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Error("expected timeout error")
+	}
+
+	failed := &mockImageScanClient{outputs: []*ecr.DescribeImageScanFindingsOutput{scanOutput(ecrTypes.ScanStatusFailed, nil)}}
+	if _, err := ecspresso.FetchImageScanFindings(ctx, failed, image, time.Second, time.Millisecond); err == nil {
+		t.Error("expected error for failed scan")
+	}
+
+	notFound := &mockImageScanClient{err: &ecrTypes.ScanNotFoundException{Message: aws.String("not found")}}
+	if _, err := ecspresso.FetchImageScanFindings(ctx, notFound, image, time.Second, time.Millisecond); !errors.Is(err, ecspresso.ErrImageScanNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestVerifyImageScanWarning(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.Option{ConfigFilePath: "tests/lint-warning.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ecspresso.ConfigImageScan{Severity: "CRITICAL"}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	client := &mockImageScanClient{
+		outputs: []*ecr.DescribeImageScanFindingsOutput{
+			scanOutput(ecrTypes.ScanStatusComplete, nil, "CVE-2023-0001"),
+		},
+	}
+	runner := ecspresso.NewVerifyRunner(false, 1)
+	runner.SetOutput("json")
+	out := extractStdout(t, func() {
+		err := runner.RunCheck(ctx, "ImageScan", func(ctx context.Context) error {
+			return app.VerifyImageScan(ctx, client, conf, "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app:v1")
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	var results []*ecspresso.VerifyResult
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err, string(out))
+	}
+	if len(results) != 1 {
+		t.Fatalf("unexpected results %s", string(out))
+	}
+	// findings below the severity are reported in the result
+	expected := []string{"1 findings below CRITICAL: CVE-2023-0001(HIGH)"}
+	if diff := cmp.Diff(expected, results[0].Warnings); diff != "" {
+		t.Errorf("unexpected warnings (-want +got):\n%s", diff)
+	}
+}
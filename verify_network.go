@@ -0,0 +1,196 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+)
+
+// subnetEgress represents routes to the internet of a subnet.
+type subnetEgress struct {
+	InternetGateway bool
+	NAT             bool
+}
+
+// routeTableOfSubnet returns the route table associated with the subnet explicitly,
+// or the main route table of the VPC.
+func routeTableOfSubnet(tables []ec2Types.RouteTable, subnetID string) *ec2Types.RouteTable {
+	var main *ec2Types.RouteTable
+	for i, t := range tables {
+		for _, a := range t.Associations {
+			if aws.ToString(a.SubnetId) == subnetID {
+				return &tables[i]
+			}
+			if aws.ToBool(a.Main) {
+				main = &tables[i]
+			}
+		}
+	}
+	return main
+}
+
+// subnetEgressOf returns routes to the internet in the route table.
+func subnetEgressOf(rt *ec2Types.RouteTable) subnetEgress {
+	var e subnetEgress
+	if rt == nil {
+		return e
+	}
+	for _, r := range rt.Routes {
+		if r.State == ec2Types.RouteStateBlackhole {
+			continue
+		}
+		if aws.ToString(r.DestinationCidrBlock) != "0.0.0.0/0" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(aws.ToString(r.GatewayId), "igw-"):
+			e.InternetGateway = true
+		case r.NatGatewayId != nil, r.InstanceId != nil, r.TransitGatewayId != nil, r.NetworkInterfaceId != nil:
+			// NAT instances and transit gateways are regarded as NAT
+			e.NAT = true
+		}
+	}
+	return e
+}
+
+// publicRegistryImages returns images which are not hosted on private ECR repositories.
+func publicRegistryImages(td *TaskDefinitionInput) []string {
+	var images []string
+	for _, c := range td.ContainerDefinitions {
+		image := aws.ToString(c.Image)
+		if image == "" || ecrImageURLRegex.MatchString(image) {
+			continue
+		}
+		images = append(images, image)
+	}
+	return images
+}
+
+// subnetReachabilityWarnings returns warnings about reachability to the internet of tasks in the subnet.
+func subnetReachabilityWarnings(subnetID string, e subnetEgress, assignPublicIp types.AssignPublicIp, isFargate bool, publicImages []string) []string {
+	var warnings []string
+	publicIp := assignPublicIp == types.AssignPublicIpEnabled
+	if publicIp && !e.InternetGateway {
+		warnings = append(warnings, fmt.Sprintf(
+			"assignPublicIp is ENABLED, but subnet %s has no route to an internet gateway", subnetID,
+		))
+	}
+	if isFargate && len(publicImages) > 0 && !e.NAT && !(publicIp && e.InternetGateway) {
+		warnings = append(warnings, fmt.Sprintf(
+			"Fargate tasks in subnet %s can not reach public registries to pull %s. subnet has no route to a NAT gateway and assignPublicIp is not ENABLED with an internet gateway",
+			subnetID, strings.Join(publicImages, ", "),
+		))
+	}
+	return warnings
+}
+
+func (d *App) verifyNetworkConfiguration(ctx context.Context, sv *Service, td *TaskDefinitionInput) error {
+	vc := sv.NetworkConfiguration.AwsvpcConfiguration
+	if len(vc.Subnets) == 0 {
+		return fmt.Errorf("networkConfiguration.awsvpcConfiguration.subnets is empty")
+	}
+	if vc.AssignPublicIp == types.AssignPublicIpEnabled && sv.LaunchType == types.LaunchTypeEc2 {
+		return fmt.Errorf("assignPublicIp ENABLED is not supported for launchType EC2")
+	}
+
+	out, err := d.verifier.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: vc.Subnets,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe subnets %s: %w", strings.Join(vc.Subnets, ","), err)
+	}
+	vpcs := map[string][]string{}
+	for _, s := range out.Subnets {
+		vpcID := aws.ToString(s.VpcId)
+		vpcs[vpcID] = append(vpcs[vpcID], aws.ToString(s.SubnetId))
+	}
+	if len(out.Subnets) != len(vc.Subnets) {
+		return ErrNotFound(fmt.Sprintf("some of subnets %s are not found", strings.Join(vc.Subnets, ",")))
+	}
+	if len(vpcs) > 1 {
+		var s []string
+		for vpcID, subnets := range vpcs {
+			s = append(s, fmt.Sprintf("%s(%s)", vpcID, strings.Join(subnets, ",")))
+		}
+		sort.Strings(s)
+		return fmt.Errorf("subnets belong to different VPCs: %s", strings.Join(s, " "))
+	}
+	vpcID := aws.ToString(out.Subnets[0].VpcId)
+
+	for _, sg := range vc.SecurityGroups {
+		sg := sg
+		name := fmt.Sprintf("SecurityGroup[%s]", sg)
+		verifyResource(ctx, name, func(ctx context.Context) error {
+			out, err := d.verifier.ec2.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+				GroupIds: []string{sg},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to describe security group %s: %w", sg, err)
+			} else if len(out.SecurityGroups) == 0 {
+				return ErrNotFound(fmt.Sprintf("security group %s is not found", sg))
+			}
+			if v := aws.ToString(out.SecurityGroups[0].VpcId); v != vpcID {
+				return fmt.Errorf("security group %s belongs to %s, but subnets belong to %s", sg, v, vpcID)
+			}
+			return nil
+		})
+	}
+
+	for _, lb := range sv.LoadBalancers {
+		tgArn := aws.ToString(lb.TargetGroupArn)
+		if tgArn == "" {
+			continue
+		}
+		name := fmt.Sprintf("TargetGroupVPC[%s]", tgArn)
+		verifyResource(ctx, name, func(ctx context.Context) error {
+			out, err := d.elbv2.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+				TargetGroupArns: []string{tgArn},
+			})
+			if err != nil {
+				return err
+			} else if len(out.TargetGroups) == 0 {
+				return ErrNotFound(fmt.Sprintf("target group %s is not found", tgArn))
+			}
+			if v := aws.ToString(out.TargetGroups[0].VpcId); v != vpcID {
+				return fmt.Errorf("target group %s belongs to %s, but subnets belong to %s", tgArn, v, vpcID)
+			}
+			return nil
+		})
+	}
+
+	verifyResource(ctx, "Routes", func(ctx context.Context) error {
+		var tables []ec2Types.RouteTable
+		p := ec2.NewDescribeRouteTablesPaginator(d.verifier.ec2, &ec2.DescribeRouteTablesInput{
+			Filters: []ec2Types.Filter{
+				{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			},
+		})
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to describe route tables of %s: %w", vpcID, err)
+			}
+			tables = append(tables, out.RouteTables...)
+		}
+		isFargate, err := d.isFargateService()
+		if err != nil {
+			return err
+		}
+		images := publicRegistryImages(td)
+		for _, subnetID := range vc.Subnets {
+			e := subnetEgressOf(routeTableOfSubnet(tables, subnetID))
+			for _, w := range subnetReachabilityWarnings(subnetID, e, vc.AssignPublicIp, isFargate, images) {
+				verifyWarning(ctx, "%s", w)
+			}
+		}
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,121 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var testRouteTables = []ec2Types.RouteTable{
+	{
+		RouteTableId: aws.String("rtb-main"),
+		Associations: []ec2Types.RouteTableAssociation{
+			{Main: aws.Bool(true)},
+		},
+		Routes: []ec2Types.Route{
+			{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local")},
+		},
+	},
+	{
+		RouteTableId: aws.String("rtb-public"),
+		Associations: []ec2Types.RouteTableAssociation{
+			{SubnetId: aws.String("subnet-public")},
+		},
+		Routes: []ec2Types.Route{
+			{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local")},
+			{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-0123")},
+		},
+	},
+	{
+		RouteTableId: aws.String("rtb-private"),
+		Associations: []ec2Types.RouteTableAssociation{
+			{SubnetId: aws.String("subnet-private")},
+		},
+		Routes: []ec2Types.Route{
+			{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local")},
+			{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-0123")},
+		},
+	},
+	{
+		RouteTableId: aws.String("rtb-blackhole"),
+		Associations: []ec2Types.RouteTableAssociation{
+			{SubnetId: aws.String("subnet-blackhole")},
+		},
+		Routes: []ec2Types.Route{
+			{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-4567"), State: ec2Types.RouteStateBlackhole},
+		},
+	},
+}
+
+func TestSubnetEgress(t *testing.T) {
+	testCases := []struct {
+		subnetID   string
+		routeTable string
+		egress     ecspresso.SubnetEgress
+	}{
+		{subnetID: "subnet-public", routeTable: "rtb-public", egress: ecspresso.SubnetEgress{InternetGateway: true}},
+		{subnetID: "subnet-private", routeTable: "rtb-private", egress: ecspresso.SubnetEgress{NAT: true}},
+		{subnetID: "subnet-blackhole", routeTable: "rtb-blackhole", egress: ecspresso.SubnetEgress{}},
+		{subnetID: "subnet-isolated", routeTable: "rtb-main", egress: ecspresso.SubnetEgress{}},
+	}
+	for _, tc := range testCases {
+		rt := ecspresso.RouteTableOfSubnet(testRouteTables, tc.subnetID)
+		if rt == nil {
+			t.Errorf("route table of %s is not found", tc.subnetID)
+			continue
+		}
+		if id := aws.ToString(rt.RouteTableId); id != tc.routeTable {
+			t.Errorf("unexpected route table of %s: %s expected %s", tc.subnetID, id, tc.routeTable)
+		}
+		if e := ecspresso.SubnetEgressOf(rt); e != tc.egress {
+			t.Errorf("unexpected egress of %s: %#v expected %#v", tc.subnetID, e, tc.egress)
+		}
+	}
+}
+
+func TestPublicRegistryImages(t *testing.T) {
+	td := &ecspresso.TaskDefinitionInput{
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("app"), Image: aws.String("123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app:v1")},
+			{Name: aws.String("nginx"), Image: aws.String("nginx:latest")},
+			{Name: aws.String("agent"), Image: aws.String("public.ecr.aws/datadog/agent:latest")},
+		},
+	}
+	expected := []string{"nginx:latest", "public.ecr.aws/datadog/agent:latest"}
+	if diff := cmp.Diff(expected, ecspresso.PublicRegistryImages(td)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSubnetReachabilityWarnings(t *testing.T) {
+	images := []string{"nginx:latest"}
+	testCases := []struct {
+		name      string
+		egress    ecspresso.SubnetEgress
+		publicIp  types.AssignPublicIp
+		isFargate bool
+		images    []string
+		warnings  int
+	}{
+		{name: "public subnet with public ip", egress: ecspresso.SubnetEgress{InternetGateway: true}, publicIp: types.AssignPublicIpEnabled, isFargate: true, images: images},
+		{name: "public subnet without public ip", egress: ecspresso.SubnetEgress{InternetGateway: true}, publicIp: types.AssignPublicIpDisabled, isFargate: true, images: images, warnings: 1},
+		{name: "private subnet with nat", egress: ecspresso.SubnetEgress{NAT: true}, publicIp: types.AssignPublicIpDisabled, isFargate: true, images: images},
+		{name: "private subnet with public ip", egress: ecspresso.SubnetEgress{NAT: true}, publicIp: types.AssignPublicIpEnabled, isFargate: true, images: images, warnings: 1},
+		{name: "isolated subnet", egress: ecspresso.SubnetEgress{}, isFargate: true, images: images, warnings: 1},
+		{name: "isolated subnet with public ip", egress: ecspresso.SubnetEgress{}, publicIp: types.AssignPublicIpEnabled, isFargate: true, images: images, warnings: 2},
+		{name: "isolated subnet with ECR images only", egress: ecspresso.SubnetEgress{}, isFargate: true},
+		{name: "isolated subnet on EC2", egress: ecspresso.SubnetEgress{}, isFargate: false, images: images},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ws := ecspresso.SubnetReachabilityWarnings("subnet-0123", tc.egress, tc.publicIp, tc.isFargate, tc.images)
+			if len(ws) != tc.warnings {
+				t.Errorf("unexpected warnings %d expected %d: %v", len(ws), tc.warnings, ws)
+			}
+		})
+	}
+}
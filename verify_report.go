@@ -0,0 +1,163 @@
+package ecspresso
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Statuses of verify checks.
+const (
+	VerifyStatusOK   = "OK"
+	VerifyStatusNG   = "NG"
+	VerifyStatusSkip = "SKIP"
+)
+
+// VerifyResult represents a result of a verify check.
+type VerifyResult struct {
+	Name     string   `json:"name"`
+	Path     []string `json:"path"`
+	Status   string   `json:"status"`
+	Cached   bool     `json:"cached"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Duration float64  `json:"duration"` // seconds
+}
+
+// collectVerifyResults collects results of the node and its descendants in the order of the tree, waiting for them to complete.
+// It returns an error when the node failed.
+func collectVerifyResults(n *verifyNode, parent []string) ([]*VerifyResult, error) {
+	<-n.done
+	path := append(append([]string{}, parent...), n.name)
+	result := &VerifyResult{
+		Name:     n.name,
+		Path:     path,
+		Cached:   n.cached,
+		Warnings: n.Warnings(),
+		Duration: n.duration.Seconds(),
+	}
+	results := []*VerifyResult{result}
+	var childErr error
+	for _, c := range n.Children() {
+		rs, err := collectVerifyResults(c, path)
+		if err != nil && childErr == nil {
+			childErr = err
+		}
+		results = append(results, rs...)
+	}
+	status, verifyErr := n.status(childErr)
+	result.Status = status
+	if verifyErr != nil {
+		result.Error = verifyErr.Error()
+	}
+	if status == VerifyStatusNG {
+		return results, fmt.Errorf("verify %s failed: %w", n.name, verifyErr)
+	}
+	return results, nil
+}
+
+func writeVerifyResults(w io.Writer, format string, results []*VerifyResult) error {
+	switch format {
+	case "json":
+		if results == nil {
+			results = []*VerifyResult{}
+		}
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal verify results: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "junit":
+		b, err := xml.MarshalIndent(newJUnitTestSuites(results), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal verify results: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, b)
+		return err
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// newJUnitTestSuites converts results to JUnit test suites. A suite is made for each top level check.
+func newJUnitTestSuites(results []*VerifyResult) *junitTestSuites {
+	suites := &junitTestSuites{Name: "ecspresso verify"}
+	var total time.Duration
+	var suite *junitTestSuite
+	var suiteTime time.Duration
+	for _, r := range results {
+		if len(r.Path) == 1 || suite == nil {
+			suite = &junitTestSuite{Name: r.Path[0]}
+			suiteTime = 0
+			suites.Suites = append(suites.Suites, suite)
+		}
+		d := time.Duration(r.Duration * float64(time.Second))
+		tc := &junitTestCase{
+			Name:      strings.Join(r.Path, " > "),
+			ClassName: "ecspresso.verify." + r.Path[0],
+			Time:      formatJUnitTime(d),
+		}
+		for _, w := range r.Warnings {
+			tc.SystemOut += "[WARNING] " + w + "\n"
+		}
+		switch r.Status {
+		case VerifyStatusNG:
+			tc.Failure = &junitMessage{Message: r.Error, Body: r.Error}
+			suite.Failures++
+			suites.Failures++
+		case VerifyStatusSkip:
+			tc.Skipped = &junitMessage{Message: r.Error}
+			suite.Skipped++
+			suites.Skipped++
+		}
+		suite.Tests++
+		suites.Tests++
+		suiteTime += d
+		total += d
+		suite.Time = formatJUnitTime(suiteTime)
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suites.Time = formatJUnitTime(total)
+	return suites
+}
+
+func formatJUnitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,185 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func verifyReportTestCheck(ctx context.Context) error {
+	ecspresso.VerifyResource(ctx, "ok", func(ctx context.Context) error {
+		return nil
+	})
+	ecspresso.VerifyResource(ctx, "ng", func(ctx context.Context) error {
+		return errors.New("XXX")
+	})
+	ecspresso.VerifyResource(ctx, "skip", func(ctx context.Context) error {
+		return ecspresso.ErrSkipVerify("hello")
+	})
+	return nil
+}
+
+func TestVerifyJSONOutput(t *testing.T) {
+	runner := ecspresso.NewVerifyRunner(false, 2)
+	runner.SetOutput("json")
+	var err error
+	out := extractStdout(t, func() {
+		err = runner.RunCheck(context.TODO(), "parent", verifyReportTestCheck)
+	})
+	if err == nil {
+		t.Error("error must be returned for ng check")
+	}
+	var results []*ecspresso.VerifyResult
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err, string(out))
+	}
+	type result struct {
+		Path   []string
+		Status string
+		Error  string
+	}
+	var got []result
+	for _, r := range results {
+		got = append(got, result{Path: r.Path, Status: r.Status, Error: r.Error})
+	}
+	expected := []result{
+		{Path: []string{"parent"}, Status: "NG", Error: "verify ng failed: XXX"},
+		{Path: []string{"parent", "ok"}, Status: "OK"},
+		{Path: []string{"parent", "ng"}, Status: "NG", Error: "XXX"},
+		{Path: []string{"parent", "skip"}, Status: "SKIP", Error: "hello"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerifyJUnitOutput(t *testing.T) {
+	runner := ecspresso.NewVerifyRunner(false, 2)
+	runner.SetOutput("junit")
+	out := extractStdout(t, func() {
+		runner.RunCheck(context.TODO(), "parent", verifyReportTestCheck)
+	})
+	var suites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Skipped  int `xml:"skipped,attr"`
+		Suites   []struct {
+			Name      string `xml:"name,attr"`
+			TestCases []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(out, &suites); err != nil {
+		t.Fatal(err, string(out))
+	}
+	if suites.Tests != 4 || suites.Failures != 2 || suites.Skipped != 1 {
+		t.Errorf("unexpected counts tests=%d failures=%d skipped=%d", suites.Tests, suites.Failures, suites.Skipped)
+	}
+	if len(suites.Suites) != 1 || suites.Suites[0].Name != "parent" {
+		t.Fatalf("unexpected suites %#v", suites.Suites)
+	}
+	tc := suites.Suites[0].TestCases[2]
+	if tc.Name != "parent > ng" || tc.Failure == nil || tc.Failure.Message != "XXX" {
+		t.Errorf("unexpected test case %#v", tc)
+	}
+}
+
+func TestVerifyFailFast(t *testing.T) {
+	runner := ecspresso.NewVerifyRunner(false, 1)
+	runner.SetOutput("json")
+	runner.SetFailFast(true)
+	out := extractStdout(t, func() {
+		runner.RunCheck(context.TODO(), "ng", func(ctx context.Context) error {
+			return errors.New("XXX")
+		})
+		runner.RunCheck(context.TODO(), "after", func(ctx context.Context) error {
+			return nil
+		})
+	})
+	dec := json.NewDecoder(bytes.NewReader(out))
+	var statuses []string
+	for dec.More() {
+		var results []*ecspresso.VerifyResult
+		if err := dec.Decode(&results); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			statuses = append(statuses, r.Name+":"+r.Status)
+		}
+	}
+	if diff := cmp.Diff([]string{"ng:NG", "after:SKIP"}, statuses); diff != "" {
+		t.Errorf("unexpected statuses (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerifyWarnings(t *testing.T) {
+	check := func(ctx context.Context) error {
+		for i := 0; i < 2; i++ {
+			ecspresso.VerifyResource(ctx, "warn", func(ctx context.Context) error {
+				ecspresso.VerifyWarning(ctx, "subnet %s has no route", "subnet-1")
+				return nil
+			})
+		}
+		return nil
+	}
+	runner := ecspresso.NewVerifyRunner(true, 1)
+	runner.SetOutput("json")
+	out := extractStdout(t, func() {
+		if err := runner.RunCheck(context.TODO(), "parent", check); err != nil {
+			t.Error(err)
+		}
+	})
+	var results []*ecspresso.VerifyResult
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err, string(out))
+	}
+	var got [][]string
+	for _, r := range results {
+		got = append(got, append([]string{r.Status}, r.Warnings...))
+	}
+	expected := [][]string{
+		{"OK"},
+		{"OK", "subnet subnet-1 has no route"},
+		// cached
+		{"OK", "subnet subnet-1 has no route"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+
+	runner = ecspresso.NewVerifyRunner(false, 1)
+	runner.SetOutput("junit")
+	out = extractStdout(t, func() {
+		runner.RunCheck(context.TODO(), "parent", check)
+	})
+	var suites struct {
+		Failures int `xml:"failures,attr"`
+		Suites   []struct {
+			TestCases []struct {
+				SystemOut string `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(out, &suites); err != nil {
+		t.Fatal(err, string(out))
+	}
+	if suites.Failures != 0 {
+		t.Errorf("warnings must not be failures: %d", suites.Failures)
+	}
+	if len(suites.Suites) != 1 || len(suites.Suites[0].TestCases) != 3 {
+		t.Fatalf("unexpected suites %s", string(out))
+	}
+	if s := suites.Suites[0].TestCases[1].SystemOut; s != "[WARNING] subnet subnet-1 has no route\n" {
+		t.Errorf("unexpected system-out %q", s)
+	}
+}
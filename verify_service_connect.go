@@ -0,0 +1,192 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicediscovery"
+)
+
+// findPortMappingByName returns the port mappings named name in the task definition.
+func findPortMappingByName(td *TaskDefinitionInput, name string) []types.PortMapping {
+	var pms []types.PortMapping
+	for _, c := range td.ContainerDefinitions {
+		for _, pm := range c.PortMappings {
+			if aws.ToString(pm.Name) == name {
+				pms = append(pms, pm)
+			}
+		}
+	}
+	return pms
+}
+
+// validateServiceConnectServices validates Service Connect services against the task definition.
+func validateServiceConnectServices(scs []types.ServiceConnectService, td *TaskDefinitionInput) error {
+	var errs []string
+	discoveryNames := map[string]string{}
+	aliases := map[string]string{}
+	for _, s := range scs {
+		portName := aws.ToString(s.PortName)
+		pms := findPortMappingByName(td, portName)
+		switch len(pms) {
+		case 0:
+			errs = append(errs, fmt.Sprintf("portName %s does not match any named portMappings in the task definition", portName))
+		case 1:
+			pm := pms[0]
+			if pm.Protocol == types.TransportProtocolUdp {
+				errs = append(errs, fmt.Sprintf("portMappings %s uses protocol udp, which is not supported by Service Connect", portName))
+			}
+			if !isValidAppProtocol(pm.AppProtocol) {
+				errs = append(errs, fmt.Sprintf("portMappings %s has unsupported appProtocol %s", portName, pm.AppProtocol))
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("portName %s matches %d portMappings in the task definition", portName, len(pms)))
+		}
+
+		discoveryName := aws.ToString(s.DiscoveryName)
+		if discoveryName == "" {
+			discoveryName = portName
+		}
+		if p, ok := discoveryNames[discoveryName]; ok {
+			errs = append(errs, fmt.Sprintf("discoveryName %s of portName %s collides with portName %s", discoveryName, portName, p))
+		} else {
+			discoveryNames[discoveryName] = portName
+		}
+
+		for _, a := range s.ClientAliases {
+			dnsName := aws.ToString(a.DnsName)
+			if dnsName == "" {
+				dnsName = discoveryName
+			}
+			alias := fmt.Sprintf("%s:%d", dnsName, aws.ToInt32(a.Port))
+			if p, ok := aliases[alias]; ok {
+				errs = append(errs, fmt.Sprintf("clientAlias %s of portName %s collides with portName %s", alias, portName, p))
+			} else {
+				aliases[alias] = portName
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid serviceConnectConfiguration: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func isValidAppProtocol(p types.ApplicationProtocol) bool {
+	if p == "" {
+		return true
+	}
+	for _, v := range p.Values() {
+		if p == v {
+			return true
+		}
+	}
+	return false
+}
+
+// validateServiceRegistry validates container name and port of the service registry against the task definition.
+func validateServiceRegistry(sr types.ServiceRegistry, td *TaskDefinitionInput) error {
+	cname := aws.ToString(sr.ContainerName)
+	if cname == "" {
+		if sr.ContainerPort != nil {
+			return fmt.Errorf("containerPort is specified without containerName")
+		}
+		return nil
+	}
+	if sr.Port != nil {
+		return fmt.Errorf("port can not be specified with containerName")
+	}
+	for _, c := range td.ContainerDefinitions {
+		if aws.ToString(c.Name) != cname {
+			continue
+		}
+		if sr.ContainerPort == nil {
+			return nil
+		}
+		for _, pm := range c.PortMappings {
+			if aws.ToInt32(pm.ContainerPort) == aws.ToInt32(sr.ContainerPort) {
+				return nil
+			}
+		}
+		return fmt.Errorf("container %s has no portMappings for containerPort %d", cname, aws.ToInt32(sr.ContainerPort))
+	}
+	return fmt.Errorf("container %s is not defined in the task definition", cname)
+}
+
+func (d *App) verifyServiceConnect(ctx context.Context, scc *types.ServiceConnectConfiguration, td *TaskDefinitionInput) error {
+	var cluster *types.Cluster
+	if aws.ToString(scc.Namespace) == "" {
+		out, err := d.ecs.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+			Clusters: []string{d.config.Cluster},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe cluster %s: %w", d.config.Cluster, err)
+		} else if len(out.Clusters) == 0 {
+			return ErrNotFound(fmt.Sprintf("cluster %s is not found", d.config.Cluster))
+		}
+		cluster = &out.Clusters[0]
+	}
+	ns, err := serviceConnectNamespace(scc, cluster)
+	if err != nil {
+		return err
+	}
+	verifyResource(ctx, fmt.Sprintf("Namespace[%s]", ns), func(ctx context.Context) error {
+		return d.verifyNamespace(ctx, ns)
+	})
+	return validateServiceConnectServices(scc.Services, td)
+}
+
+// serviceConnectNamespace returns the namespace of Service Connect, which defaults to the default namespace of the cluster.
+func serviceConnectNamespace(scc *types.ServiceConnectConfiguration, cluster *types.Cluster) (string, error) {
+	if ns := aws.ToString(scc.Namespace); ns != "" {
+		return ns, nil
+	}
+	if cluster != nil && cluster.ServiceConnectDefaults != nil {
+		if ns := aws.ToString(cluster.ServiceConnectDefaults.Namespace); ns != "" {
+			return ns, nil
+		}
+	}
+	var name string
+	if cluster != nil {
+		name = aws.ToString(cluster.ClusterName)
+	}
+	return "", fmt.Errorf("namespace of Service Connect is not specified, and cluster %s has no default namespace", name)
+}
+
+func (d *App) verifyNamespace(ctx context.Context, ns string) error {
+	if strings.HasPrefix(ns, "arn:") {
+		if _, err := d.sd.GetNamespace(ctx, &servicediscovery.GetNamespaceInput{
+			Id: aws.String(arnToName(ns)),
+		}); err != nil {
+			return fmt.Errorf("failed to get namespace %s: %w", ns, err)
+		}
+		return nil
+	}
+	p := servicediscovery.NewListNamespacesPaginator(d.sd, &servicediscovery.ListNamespacesInput{})
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, n := range out.Namespaces {
+			if aws.ToString(n.Name) == ns {
+				return nil
+			}
+		}
+	}
+	return ErrNotFound(fmt.Sprintf("namespace %s is not found", ns))
+}
+
+func (d *App) verifyServiceRegistry(ctx context.Context, sr types.ServiceRegistry, td *TaskDefinitionInput) error {
+	registryArn := aws.ToString(sr.RegistryArn)
+	if _, err := d.sd.GetService(ctx, &servicediscovery.GetServiceInput{
+		Id: aws.String(arnToName(registryArn)),
+	}); err != nil {
+		return fmt.Errorf("failed to get service registry %s: %w", registryArn, err)
+	}
+	return validateServiceRegistry(sr, td)
+}
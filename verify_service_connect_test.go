@@ -0,0 +1,166 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var testServiceConnectTd = &ecspresso.TaskDefinitionInput{
+	ContainerDefinitions: []types.ContainerDefinition{
+		{
+			Name: aws.String("app"),
+			PortMappings: []types.PortMapping{
+				{Name: aws.String("http"), ContainerPort: aws.Int32(8080), AppProtocol: types.ApplicationProtocolHttp},
+				{Name: aws.String("grpc"), ContainerPort: aws.Int32(9090), AppProtocol: types.ApplicationProtocolGrpc},
+				{Name: aws.String("dns"), ContainerPort: aws.Int32(53), Protocol: types.TransportProtocolUdp},
+				{Name: aws.String("dup"), ContainerPort: aws.Int32(8081)},
+			},
+		},
+		{
+			Name: aws.String("sidecar"),
+			PortMappings: []types.PortMapping{
+				{Name: aws.String("dup"), ContainerPort: aws.Int32(8082)},
+				{Name: aws.String("unknown"), ContainerPort: aws.Int32(8083), AppProtocol: "websocket"},
+			},
+		},
+	},
+}
+
+func TestValidateServiceConnectServices(t *testing.T) {
+	testCases := []struct {
+		name     string
+		services []types.ServiceConnectService
+		isErr    bool
+	}{
+		{
+			name: "valid",
+			services: []types.ServiceConnectService{
+				{PortName: aws.String("http"), ClientAliases: []types.ServiceConnectClientAlias{{Port: aws.Int32(80)}}},
+				{PortName: aws.String("grpc"), DiscoveryName: aws.String("api")},
+			},
+		},
+		{
+			name:     "unknown port name",
+			services: []types.ServiceConnectService{{PortName: aws.String("admin")}},
+			isErr:    true,
+		},
+		{
+			name:     "udp",
+			services: []types.ServiceConnectService{{PortName: aws.String("dns")}},
+			isErr:    true,
+		},
+		{
+			name:     "ambiguous port name",
+			services: []types.ServiceConnectService{{PortName: aws.String("dup")}},
+			isErr:    true,
+		},
+		{
+			name:     "unsupported appProtocol",
+			services: []types.ServiceConnectService{{PortName: aws.String("unknown")}},
+			isErr:    true,
+		},
+		{
+			name: "discovery name collision",
+			services: []types.ServiceConnectService{
+				{PortName: aws.String("http")},
+				{PortName: aws.String("grpc"), DiscoveryName: aws.String("http")},
+			},
+			isErr: true,
+		},
+		{
+			name: "client alias collision",
+			services: []types.ServiceConnectService{
+				{PortName: aws.String("http"), ClientAliases: []types.ServiceConnectClientAlias{{DnsName: aws.String("app"), Port: aws.Int32(80)}}},
+				{PortName: aws.String("grpc"), ClientAliases: []types.ServiceConnectClientAlias{{DnsName: aws.String("app"), Port: aws.Int32(80)}}},
+			},
+			isErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ecspresso.ValidateServiceConnectServices(tc.services, testServiceConnectTd)
+			if tc.isErr && err == nil {
+				t.Error("expected error but got nil")
+			} else if !tc.isErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateServiceRegistry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		registry types.ServiceRegistry
+		isErr    bool
+	}{
+		{name: "port only", registry: types.ServiceRegistry{Port: aws.Int32(8080)}},
+		{name: "container name and port", registry: types.ServiceRegistry{ContainerName: aws.String("app"), ContainerPort: aws.Int32(8080)}},
+		{name: "container name only", registry: types.ServiceRegistry{ContainerName: aws.String("sidecar")}},
+		{name: "unknown container", registry: types.ServiceRegistry{ContainerName: aws.String("web"), ContainerPort: aws.Int32(8080)}, isErr: true},
+		{name: "unknown container port", registry: types.ServiceRegistry{ContainerName: aws.String("app"), ContainerPort: aws.Int32(80)}, isErr: true},
+		{name: "container port without name", registry: types.ServiceRegistry{ContainerPort: aws.Int32(8080)}, isErr: true},
+		{name: "container name with port", registry: types.ServiceRegistry{ContainerName: aws.String("app"), Port: aws.Int32(8080)}, isErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ecspresso.ValidateServiceRegistry(tc.registry, testServiceConnectTd)
+			if tc.isErr && err == nil {
+				t.Error("expected error but got nil")
+			} else if !tc.isErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestServiceConnectNamespace(t *testing.T) {
+	nsArn := "arn:aws:servicediscovery:ap-northeast-1:123456789012:namespace/ns-default"
+	testCases := []struct {
+		name     string
+		scc      *types.ServiceConnectConfiguration
+		cluster  *types.Cluster
+		expected string
+		isErr    bool
+	}{
+		{
+			name:     "namespace",
+			scc:      &types.ServiceConnectConfiguration{Enabled: true, Namespace: aws.String("app.local")},
+			expected: "app.local",
+		},
+		{
+			name: "default namespace of cluster",
+			scc:  &types.ServiceConnectConfiguration{Enabled: true},
+			cluster: &types.Cluster{
+				ClusterName:            aws.String("default"),
+				ServiceConnectDefaults: &types.ClusterServiceConnectDefaults{Namespace: aws.String(nsArn)},
+			},
+			expected: nsArn,
+		},
+		{
+			name:    "no default namespace of cluster",
+			scc:     &types.ServiceConnectConfiguration{Enabled: true},
+			cluster: &types.Cluster{ClusterName: aws.String("default")},
+			isErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns, err := ecspresso.ServiceConnectNamespace(tc.scc, tc.cluster)
+			if tc.isErr {
+				if err == nil {
+					t.Errorf("expected error but got %s", ns)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if ns != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, ns)
+			}
+		})
+	}
+}
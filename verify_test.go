@@ -3,12 +3,19 @@ package ecspresso_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/fatih/color"
+	"github.com/google/go-cmp/cmp"
 	"github.com/kayac/ecspresso/v2"
 )
 
@@ -165,10 +172,10 @@ func TestIsECRImage(t *testing.T) {
 func TestVerifyOKResource(t *testing.T) {
 	color.NoColor = true
 	for _, cache := range []bool{false, true} {
-		ecspresso.InitVerifyState(cache)
+		runner := ecspresso.NewVerifyRunner(cache, 1)
 		for i := 0; i < 3; i++ {
 			out := extractStdout(t, func() {
-				err := ecspresso.VerifyResource(context.TODO(), "ok resource", func(_ context.Context) error {
+				err := runner.RunCheck(context.TODO(), "ok resource", func(_ context.Context) error {
 					return nil
 				})
 				if err != nil {
@@ -193,10 +200,10 @@ func TestVerifyOKResource(t *testing.T) {
 func TestVerifyNGResource(t *testing.T) {
 	color.NoColor = true
 	for _, cache := range []bool{false, true} {
-		ecspresso.InitVerifyState(cache)
+		runner := ecspresso.NewVerifyRunner(cache, 1)
 		for i := 0; i < 3; i++ {
 			out := extractStdout(t, func() {
-				err := ecspresso.VerifyResource(context.TODO(), "ng resource", func(_ context.Context) error {
+				err := runner.RunCheck(context.TODO(), "ng resource", func(_ context.Context) error {
 					return errors.New("XXX")
 				})
 				if err == nil {
@@ -222,10 +229,10 @@ func TestVerifyNGResource(t *testing.T) {
 func TestVerifySkipResource(t *testing.T) {
 	color.NoColor = true
 	for _, cache := range []bool{false, true} {
-		ecspresso.InitVerifyState(cache)
+		runner := ecspresso.NewVerifyRunner(cache, 1)
 		for i := 0; i < 3; i++ {
 			out := extractStdout(t, func() {
-				err := ecspresso.VerifyResource(context.TODO(), "skip resource", func(_ context.Context) error {
+				err := runner.RunCheck(context.TODO(), "skip resource", func(_ context.Context) error {
 					return ecspresso.ErrSkipVerify("hello")
 				})
 				if err != nil {
@@ -268,3 +275,184 @@ func TestVerifierIsAssumed(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyTree(t *testing.T) {
+	color.NoColor = true
+	runner := ecspresso.NewVerifyRunner(false, 4)
+	var err error
+	out := extractStdout(t, func() {
+		err = runner.RunCheck(context.TODO(), "parent", func(ctx context.Context) error {
+			for i, d := range []time.Duration{30, 20, 10} {
+				i, d := i, d
+				ecspresso.VerifyResource(ctx, fmt.Sprintf("child%d", i), func(ctx context.Context) error {
+					time.Sleep(d * time.Millisecond)
+					if i == 1 {
+						return errors.New("XXX")
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	if err == nil {
+		t.Error("error must be returned for ng child")
+	}
+	expected := strings.Join([]string{
+		"  parent",
+		"    child0",
+		"    --> [OK]",
+		"    child1",
+		"    --> [NG] XXX",
+		"    child2",
+		"    --> [OK]",
+		"  --> [NG] verify child1 failed: XXX",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(expected, string(out)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerifyConcurrency(t *testing.T) {
+	color.NoColor = true
+	const concurrency = 3
+	runner := ecspresso.NewVerifyRunner(false, concurrency)
+	var running, maxRunning int32
+	check := func(ctx context.Context) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	extractStdout(t, func() {
+		err := runner.RunCheck(context.TODO(), "parent", func(ctx context.Context) error {
+			for i := 0; i < 10; i++ {
+				ecspresso.VerifyResource(ctx, fmt.Sprintf("child%d", i), check)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if maxRunning < 2 || maxRunning > concurrency {
+		t.Errorf("unexpected max concurrency %d", maxRunning)
+	}
+}
+
+func TestVerifyCacheConcurrent(t *testing.T) {
+	color.NoColor = true
+	runner := ecspresso.NewVerifyRunner(true, 4)
+	var calls int32
+	out := extractStdout(t, func() {
+		err := runner.RunCheck(context.TODO(), "parent", func(ctx context.Context) error {
+			for i := 0; i < 5; i++ {
+				ecspresso.VerifyResource(ctx, "same resource", func(ctx context.Context) error {
+					atomic.AddInt32(&calls, 1)
+					time.Sleep(10 * time.Millisecond)
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if calls != 1 {
+		t.Errorf("a check for the same resource must run once, but %d times", calls)
+	}
+	if n := bytes.Count(out, []byte("(cached)")); n != 4 {
+		t.Errorf("unexpected count of cached results %d", n)
+	}
+}
+
+func TestVerifyCacheKey(t *testing.T) {
+	color.NoColor = true
+	runner := ecspresso.NewVerifyRunner(true, 4)
+	calls := map[string]*int32{"role-a": new(int32), "role-b": new(int32)}
+	out := extractStdout(t, func() {
+		err := runner.RunCheck(context.TODO(), "parent", func(ctx context.Context) error {
+			for _, role := range []string{"role-a", "role-b", "role-a"} {
+				role := role
+				// the same name for the different roles
+				ecspresso.VerifyResourceWithKey(ctx, "Permission[s3:GetObject *]", "Permission[s3:GetObject *] "+role, func(ctx context.Context) error {
+					atomic.AddInt32(calls[role], 1)
+					if role == "role-b" {
+						return errors.New("denied")
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err == nil {
+			t.Error("a check of role-b must fail")
+		}
+	})
+	for role, n := range calls {
+		if *n != 1 {
+			t.Errorf("a check for %s must run once, but %d times", role, *n)
+		}
+	}
+	if n := bytes.Count(out, []byte("(cached)")); n != 1 {
+		t.Errorf("unexpected count of cached results %d", n)
+	}
+	if n := bytes.Count(out, []byte("[NG] denied")); n != 1 {
+		t.Errorf("unexpected count of failed results %d", n)
+	}
+}
+
+func TestVerifyCacheChildFailure(t *testing.T) {
+	runner := ecspresso.NewVerifyRunner(true, 1)
+	runner.SetOutput("json")
+	var calls int32
+	var err error
+	out := extractStdout(t, func() {
+		err = runner.RunCheck(context.TODO(), "parent", func(ctx context.Context) error {
+			for i := 0; i < 2; i++ {
+				ecspresso.VerifyResource(ctx, "role", func(ctx context.Context) error {
+					atomic.AddInt32(&calls, 1)
+					ecspresso.VerifyResource(ctx, "policy", func(ctx context.Context) error {
+						return errors.New("denied")
+					})
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	if err == nil {
+		t.Error("error must be returned for the failed child")
+	}
+	if calls != 1 {
+		t.Errorf("a check for the same resource must run once, but %d times", calls)
+	}
+	var results []*ecspresso.VerifyResult
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatal(err, string(out))
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, fmt.Sprintf("%s:%s:%v", strings.Join(r.Path, " > "), r.Status, r.Cached))
+	}
+	// the order of the checks for the same resource is not determined
+	sort.Strings(got)
+	expected := []string{
+		"parent > role > policy:NG:false",
+		"parent > role:NG:false",
+		// the failure of the child is cached
+		"parent > role:NG:true",
+		"parent:NG:false",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}